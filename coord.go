@@ -0,0 +1,437 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// -------------------------------------------------------------------------
+// Coordinate Systems
+
+// Coord is a coordinate system. It places the positions of the x and y
+// scales in the panel and determines where the axes are drawn.
+//
+// Geoms produce their grobs in scale space: The positions are the values
+// of the Pos functions of the x and y scale. These grobs are transformed
+// to the panel by the coordinate system of the plot.
+type Coord interface {
+	// Transform maps the position (x,y) in scale space to the unit
+	// square of the panel.
+	Transform(x, y float64) (float64, float64)
+
+	// Flipped reports whether the x scale is drawn vertically and the
+	// y scale horizontally.
+	Flipped() bool
+
+	// Linear reports whether Transform maps straight lines to straight
+	// lines. Lines and polygons are interpolated before transforming
+	// them by a non-linear coordinate system.
+	Linear() bool
+}
+
+// CoordCartesian is the usual cartesian coordinate system with x running
+// horizontally and y vertically. It is the default.
+type CoordCartesian struct {
+	// XLim and YLim zoom into the range [min,max] of the x and y scale
+	// if min!=max. The limits are given in the untransformed data units
+	// of the scale. Unlike FixMin and FixMax of a Scale zooming does not
+	// touch the data: Stats see all data and geoms extending beyond the
+	// visible range are clipped at the panel border.
+	XLim, YLim [2]float64
+}
+
+var _ Coord = CoordCartesian{}
+
+func (CoordCartesian) Transform(x, y float64) (float64, float64) { return x, y }
+func (CoordCartesian) Flipped() bool                             { return false }
+func (CoordCartesian) Linear() bool                              { return true }
+
+func (c CoordCartesian) zoom(aes string) [2]float64 { return zoomLimits(aes, c.XLim, c.YLim) }
+
+// CoordFlip is a cartesian coordinate system with x and y exchanged: The
+// x scale runs vertically and the y scale horizontally. This turns e.g.
+// bar charts and boxplots into horizontal ones.
+type CoordFlip struct {
+	// XLim and YLim zoom into the x and y scale like the limits of
+	// CoordCartesian. XLim applies to the x scale drawn vertically.
+	XLim, YLim [2]float64
+}
+
+var _ Coord = CoordFlip{}
+
+func (CoordFlip) Transform(x, y float64) (float64, float64) { return y, x }
+func (CoordFlip) Flipped() bool                             { return true }
+func (CoordFlip) Linear() bool                              { return true }
+
+func (c CoordFlip) zoom(aes string) [2]float64 { return zoomLimits(aes, c.XLim, c.YLim) }
+
+// zoomer is implemented by coordinate systems which restrict the visible
+// range of the x and y scale.
+type zoomer interface {
+	// zoom returns the limits of the scale of aes. Equal limits mean
+	// no zooming.
+	zoom(aes string) [2]float64
+}
+
+func zoomLimits(aes string, xlim, ylim [2]float64) [2]float64 {
+	if aes == "x" {
+		return xlim
+	}
+	return ylim
+}
+
+// CoordFixed is a cartesian coordinate system with a fixed ratio of the
+// units on the axes: One unit on the y axis is drawn Ratio times as long
+// as one unit on the x axis. A Ratio of 0 means 1, i.e. equal units as
+// needed for maps or calibration plots.
+type CoordFixed struct {
+	Ratio float64
+}
+
+var _ Coord = CoordFixed{}
+
+func (CoordFixed) Transform(x, y float64) (float64, float64) { return x, y }
+func (CoordFixed) Flipped() bool                             { return false }
+func (CoordFixed) Linear() bool                              { return true }
+
+// aspecter is implemented by coordinate systems which determine the
+// aspect ratio (height/width) of the panels.
+type aspecter interface {
+	aspect(panel *Panel) float64
+}
+
+func (c CoordFixed) aspect(panel *Panel) float64 {
+	ratio := c.Ratio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	sx, sy := panel.Scales["x"], panel.Scales["y"]
+	if sx.Max <= sx.Min || sy.Max <= sy.Min {
+		return 0 // degenerated scales: no sensible ratio
+	}
+	return ratio * (sy.Max - sy.Min) / (sx.Max - sx.Min)
+}
+
+// CoordPolar is a polar coordinate system: One scale is mapped to the
+// angle theta, the other to the radius. Rectangles become wedges and
+// lines become arcs. A stacked GeomBar with Theta "y" is a pie chart or,
+// with a InnerRadius, a donut chart.
+//
+// Grid lines are drawn as spokes and concentric circles, the labels of
+// theta are placed around the circle and the radius is labeled on the
+// vertical axis. Theta runs over the full circle: The expansion of the
+// scale mapped to theta is ignored.
+type CoordPolar struct {
+	// Theta is the aesthetic mapped to the angle: "x" (default) or "y".
+	Theta string
+
+	// Start is the angle in radians (clockwise from 12 o'clock) of
+	// the start of the theta scale.
+	Start float64
+
+	// CounterClockwise reverses the direction of theta.
+	CounterClockwise bool
+
+	// InnerRadius is the fraction of the radius left empty in the
+	// center, e.g. 0.5 for a donut.
+	InnerRadius float64
+}
+
+var _ Coord = CoordPolar{}
+
+// polarRadius is the radius of the polar circle in the unit square of the
+// panel. The rest is left to the labels of theta.
+const polarRadius = 0.4
+
+func (c CoordPolar) Transform(x, y float64) (float64, float64) {
+	t, r := c.thetaRadius(x, y)
+	phi := c.angle(t)
+	return 0.5 + r*math.Sin(phi), 0.5 + r*math.Cos(phi)
+}
+
+func (c CoordPolar) Flipped() bool { return c.Theta == "y" }
+func (c CoordPolar) Linear() bool  { return false }
+
+// Polar panels are square to keep the circle round.
+func (c CoordPolar) aspect(*Panel) float64 { return 1 }
+
+// thetaRadius returns the position t on the theta scale and the radius
+// of the point (x,y) in scale space.
+func (c CoordPolar) thetaRadius(x, y float64) (t, r float64) {
+	t, r = x, y
+	if c.Theta == "y" {
+		t, r = y, x
+	}
+	r = math.Max(0, math.Min(1, r))
+	return t, polarRadius * (c.InnerRadius + (1-c.InnerRadius)*r)
+}
+
+// angle returns the angle (clockwise from 12 o'clock) of position t on
+// the theta scale.
+func (c CoordPolar) angle(t float64) float64 {
+	if c.CounterClockwise {
+		t = -t
+	}
+	return c.Start + 2*math.Pi*t
+}
+
+// thetaAes returns the aesthetic mapped to the angle.
+func (c CoordPolar) thetaAes() string {
+	if c.Theta == "y" {
+		return "y"
+	}
+	return "x"
+}
+
+// drawAxes draws the labels of theta around the circle and (if showR)
+// the tics and labels of the radius on the left side of the panel vp.
+func (c CoordPolar) drawAxes(panel *Panel, vp Viewport, style axisStyle, showR bool) {
+	st := panel.Scales[c.thetaAes()]
+	sr := panel.Scales["y"]
+	if c.Theta == "y" {
+		sr = panel.Scales["x"]
+	}
+
+	sep := polarRadius + 0.5*(vp.XI(style.labelSep)+vp.YI(style.labelSep))
+	for i, b := range st.Breaks {
+		t := st.Pos(b)
+		if t < -1e-9 || t > 1+1e-9 {
+			continue
+		}
+		if t > 1-1e-9 && len(st.Breaks) > 1 && st.Pos(st.Breaks[0]) < 1e-9 {
+			continue // Same place as the first break.
+		}
+		phi := c.angle(t)
+		sin, cos := math.Sin(phi), math.Cos(phi)
+		GrobText{x: 0.5 + sep*sin, y: 0.5 + sep*cos,
+			hjust: 0.5 - 0.5*sin, vjust: 0.5 - 0.5*cos,
+			text: st.Labels[i], size: style.labelSize, color: style.labelCol}.Draw(vp)
+	}
+
+	if !showR {
+		return
+	}
+	pos, labels := []float64{}, []string{}
+	for i, b := range sr.Breaks {
+		r := sr.Pos(b)
+		if r < 0 || r > 1 {
+			continue
+		}
+		x, y := 0.0, r
+		if c.Theta == "y" {
+			x, y = r, 0
+		}
+		_, radius := c.thetaRadius(x, y)
+		pos = append(pos, 0.5+radius)
+		labels = append(labels, sr.Labels[i])
+	}
+	style.drawVerticalAxis(vp, pos, labels)
+}
+
+// coord returns the coordinate system of the plot.
+func (plot *Plot) coord() Coord {
+	if plot.Coord == nil {
+		return CoordCartesian{}
+	}
+	return plot.Coord
+}
+
+// dataAspect reports whether the coordinate system of plot derives the
+// aspect ratio of the panels from the ranges of their x and y scale.
+func (plot *Plot) dataAspect() bool {
+	switch plot.coord().(type) {
+	case CoordFixed, CoordMap:
+		return true
+	}
+	return false
+}
+
+// aspect returns the aspect ratio (height/width) of the panels of plot
+// or 0 if the panels may be stretched to fill the plot. All panels share
+// their x and y scales if the aspect ratio is derived from the scales.
+func (plot *Plot) aspect() float64 {
+	if plot.Aspect > 0 {
+		return plot.Aspect
+	}
+	if a, ok := plot.coord().(aspecter); ok {
+		return a.aspect(plot.Panels[0][0])
+	}
+	return 0
+}
+
+// axes returns the aesthetics of the scales drawn on the horizontal
+// and on the vertical axis.
+func (plot *Plot) axes() (horizontal, vertical string) {
+	if plot.coord().Flipped() {
+		return "y", "x"
+	}
+	return "x", "y"
+}
+
+// transformGrobs transforms the grobs produced in scale space to the panel
+// space of the coordinate system c.
+func transformGrobs(grobs []Grob, c Coord) []Grob {
+	switch c.(type) {
+	case CoordCartesian, CoordFixed:
+		return grobs
+	}
+	transformed := make([]Grob, len(grobs))
+	for i, g := range grobs {
+		transformed[i] = transformGrob(g, c)
+	}
+	return transformed
+}
+
+// transformGrob transforms the single grob g to the coordinate system c.
+func transformGrob(g Grob, c Coord) Grob {
+	points := func(pts []struct{ x, y float64 }) []struct{ x, y float64 } {
+		t := make([]struct{ x, y float64 }, len(pts))
+		for i, p := range pts {
+			t[i].x, t[i].y = c.Transform(p.x, p.y)
+		}
+		return t
+	}
+
+	if !c.Linear() {
+		// Interpolate lines and areas in scale space.
+		switch g := g.(type) {
+		case GrobLine:
+			path := GrobPath{
+				points:   munch([]struct{ x, y float64 }{{g.x0, g.y0}, {g.x1, g.y1}}, false),
+				size:     g.size,
+				linetype: g.linetype,
+				color:    g.color,
+				cap:      g.cap,
+				arrow:    g.arrow,
+			}
+			return transformGrob(path, c)
+		case GrobPath:
+			g.points = munch(g.points, false)
+			g.points = points(g.points)
+			return g
+		case GrobPolygon:
+			g.points = points(munch(g.points, true))
+			return g
+		case GrobRect:
+			corners := []struct{ x, y float64 }{
+				{g.xmin, g.ymin}, {g.xmax, g.ymin}, {g.xmax, g.ymax}, {g.xmin, g.ymax}}
+			return transformGrob(GrobPolygon{points: corners, fill: g.fill}, c)
+		case GrobRaster:
+			return transformGrob(rasterRects(g), c)
+		}
+	}
+
+	switch g := g.(type) {
+	case GrobPoint:
+		g.x, g.y = c.Transform(g.x, g.y)
+		return g
+	case GrobLine:
+		g.x0, g.y0 = c.Transform(g.x0, g.y0)
+		g.x1, g.y1 = c.Transform(g.x1, g.y1)
+		return g
+	case GrobPath:
+		g.points = points(g.points)
+		return g
+	case GrobPolygon:
+		g.points = points(g.points)
+		return g
+	case GrobText:
+		g.x, g.y = c.Transform(g.x, g.y)
+		return g
+	case GrobLabel:
+		g.x, g.y = c.Transform(g.x, g.y)
+		return g
+	case GrobRepel:
+		labels := make([]GrobLabel, len(g.labels))
+		for i, l := range g.labels {
+			labels[i] = transformGrob(l, c).(GrobLabel)
+		}
+		g.labels = labels
+		return g
+	case GrobRect:
+		x0, y0 := c.Transform(g.xmin, g.ymin)
+		x1, y1 := c.Transform(g.xmax, g.ymax)
+		g.xmin, g.ymin, g.xmax, g.ymax = x0, y0, x1, y1
+		return g
+	case GrobRaster:
+		x0, y0 := c.Transform(g.xmin, g.ymin)
+		x1, y1 := c.Transform(g.xmax, g.ymax)
+		g.xmin, g.ymin, g.xmax, g.ymax = x0, y0, x1, y1
+		if c.Flipped() {
+			g.image = transposeImage(g.image)
+		}
+		return g
+	case GrobGroup:
+		g.elements = transformGrobs(g.elements, c)
+		return g
+	}
+	return g
+}
+
+// munchSteps is the number of pieces a line of length 1 in scale space is
+// divided into for non-linear coordinate systems.
+const munchSteps = 100
+
+// munch interpolates the path (or closed polygon) through points by
+// inserting intermediate points.
+func munch(points []struct{ x, y float64 }, closed bool) []struct{ x, y float64 } {
+	n := len(points)
+	if n < 2 {
+		return points
+	}
+	segments := n - 1
+	if closed {
+		segments = n
+	}
+	munched := []struct{ x, y float64 }{}
+	for i := 0; i < segments; i++ {
+		a, b := points[i], points[(i+1)%n]
+		dx, dy := b.x-a.x, b.y-a.y
+		steps := 1 + int(munchSteps*math.Hypot(dx, dy))
+		for j := 0; j < steps; j++ {
+			f := float64(j) / float64(steps)
+			munched = append(munched, struct{ x, y float64 }{a.x + f*dx, a.y + f*dy})
+		}
+	}
+	if !closed {
+		munched = append(munched, points[n-1])
+	}
+	return munched
+}
+
+// rasterRects converts the raster into one rectangle per cell.
+func rasterRects(raster GrobRaster) GrobGroup {
+	b := raster.image.Bounds()
+	dx := (raster.xmax - raster.xmin) / float64(b.Dx())
+	dy := (raster.ymax - raster.ymin) / float64(b.Dy())
+	group := GrobGroup{}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			fill := raster.image.At(b.Min.X+x, b.Min.Y+y)
+			if _, _, _, a := fill.RGBA(); a == 0 {
+				continue
+			}
+			group.elements = append(group.elements, GrobRect{
+				xmin: raster.xmin + float64(x)*dx, xmax: raster.xmin + float64(x+1)*dx,
+				ymin: raster.ymax - float64(y+1)*dy, ymax: raster.ymax - float64(y)*dy,
+				fill: color.NRGBAModel.Convert(fill),
+			})
+		}
+	}
+	return group
+}
+
+// transposeImage mirrors img at its anti-diagonal: This is the raster of
+// exchanged x and y as pixel (0,0) is the upper left corner.
+func transposeImage(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	t := image.NewNRGBA(image.Rect(0, 0, h, w))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			t.Set(h-1-y, w-1-x, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return t
+}
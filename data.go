@@ -255,6 +255,31 @@ func (df *DataFrame) CopyMeta() *DataFrame {
 	return result
 }
 
+// FilterRows removes all rows i from df for which keep(i) is false and
+// returns the number of removed rows.
+func (df *DataFrame) FilterRows(keep func(i int) bool) int {
+	rows := []int{}
+	for i := 0; i < df.N; i++ {
+		if keep(i) {
+			rows = append(rows, i)
+		}
+	}
+	removed := df.N - len(rows)
+	if removed == 0 {
+		return 0
+	}
+	for name, field := range df.Columns {
+		data := make([]float64, len(rows))
+		for j, i := range rows {
+			data[j] = field.Data[i]
+		}
+		field.Data = data
+		df.Columns[name] = field
+	}
+	df.N = len(rows)
+	return removed
+}
+
 func (df *DataFrame) Rename(o, n string) {
 	if o == n {
 		return
@@ -633,15 +658,27 @@ func GroupingField(data *DataFrame, names []string) Field {
 	return field
 }
 
+// Resolution returns the smallest non-zero distance between two values
+// in f. Fields with less than two distinct values have resolution 1 and
+// so have String fields as their levels are placed on consecutive
+// integers by the discrete scales.
 func (f Field) Resolution() float64 {
+	if f.Type == String {
+		return 1
+	}
+	d := make([]float64, len(f.Data))
+	copy(d, f.Data)
+	sort.Float64s(d)
 	resolution := math.Inf(+1)
-	d := f.Data
-	for i := 0; i < len(f.Data)-1; i++ {
-		r := math.Abs(d[i] - d[i+1])
-		if r < resolution {
+	for i := 0; i < len(d)-1; i++ {
+		r := d[i+1] - d[i]
+		if r > 0 && r < resolution {
 			resolution = r
 		}
 	}
+	if math.IsInf(resolution, +1) {
+		return 1
+	}
 	return resolution
 }
 
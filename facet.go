@@ -0,0 +1,128 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// -------------------------------------------------------------------------
+// Facets and their Labels
+
+// A Labeller produces the label shown in the strip for the level value
+// of the faceting field. Any func(field, value string) string can be
+// used to label facets.
+type Labeller func(field, value string) string
+
+// LabelValue labels a facet with the plain value, e.g. "Ideal". This is
+// the default.
+func LabelValue(field, value string) string { return value }
+
+// LabelBoth labels a facet with the field and the value, e.g. "Cut: Ideal".
+func LabelBoth(field, value string) string { return field + ": " + value }
+
+// LabelMap labels the facets by looking up their value in labels. Values
+// not found in labels are shown as they are.
+func LabelMap(labels map[string]string) Labeller {
+	return func(field, value string) string {
+		if label, ok := labels[value]; ok {
+			return label
+		}
+		return value
+	}
+}
+
+// facet is one combination of the levels of the faceting fields.
+type facet struct {
+	levels []float64  // the levels of the faceting fields
+	strip  string     // the label shown in the strip
+	data   *DataFrame // the data of this facet
+	total  bool       // the total facet contains all levels
+}
+
+// filter returns a copy of the rows of df belonging to the facet f of
+// fields.
+func (f facet) filter(df *DataFrame, fields []string) *DataFrame {
+	if f.total {
+		return df.Copy()
+	}
+	for i, name := range fields {
+		df = Filter(df, name, f.levels[i])
+	}
+	return df
+}
+
+// facets returns the combinations of the levels of fields present in the
+// data of plot. No fields result in one facet containing all data.
+func (plot *Plot) facets(fields []string) []facet {
+	labeller := plot.Faceting.Labeller
+	if labeller == nil {
+		labeller = LabelValue
+	}
+	sep := ", "
+	if plot.Faceting.MultiLine {
+		sep = "\n"
+	}
+
+	facets := []facet{{data: plot.Data}}
+	for _, name := range fields {
+		f, ok := plot.Data.Columns[name]
+		if !ok || !f.Discrete() {
+			panic(fmt.Sprintf("Cannot facet over %s (type %s)",
+				name, f.Type.String()))
+		}
+		combined := []facet{}
+		for _, fc := range facets {
+			for _, level := range Levels(fc.data, name).Elements() {
+				strip := labeller(name, f.String(level))
+				if len(fc.levels) > 0 {
+					strip = fc.strip + sep + strip
+				}
+				levels := append(append([]float64(nil), fc.levels...), level)
+				combined = append(combined,
+					facet{levels: levels, strip: strip, data: Filter(fc.data, name, level)})
+			}
+		}
+		facets = combined
+	}
+	return facets
+}
+
+// facetFields splits the faceting specification spec into the names of
+// the fields.
+func facetFields(spec string) []string {
+	fields := []string{}
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// stripLines returns the grobs of a strip showing the (possibly multi-line)
+// label text and the height of the strip (its width if rotated): The lines
+// are stacked vertically or, for rotated strips, horizontally.
+func stripLines(label string, rotated bool, style AesMapping) ([]Grob, vg.Length) {
+	strip := MergeStyles(style, DefaultTheme.Strip)
+	size := String2Float(strip["size"], 4, 100)
+	col := String2Color(strip["color"])
+	grobs := []Grob{GrobRect{xmin: 0, ymin: 0, xmax: 1, ymax: 1,
+		fill: String2Color(strip["fill"])}}
+	lines := strings.Split(label, "\n")
+	n := float64(len(lines))
+	extent := vg.Length(0)
+	for i, line := range lines {
+		text := GrobText{x: 0.5, y: 1 - (float64(i)+0.5)/n, vjust: 0.5, hjust: 0.5,
+			text: line, size: size, color: col}
+		_, h := text.BoundingBox() // the line height, also if rotated
+		if rotated {
+			text.x, text.y, text.angle = (float64(i)+0.5)/n, 0.5, math.Pi/2
+		}
+		grobs = append(grobs, text)
+		extent += h
+	}
+	return grobs, extent
+}
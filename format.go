@@ -0,0 +1,281 @@
+package plot
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Notation determines how a NumberFormat writes numbers.
+type Notation int
+
+const (
+	// AutoNotation uses FixedNotation for moderate values and
+	// ScientificNotation for very large or very small values.
+	AutoNotation Notation = iota
+	FixedNotation
+	ScientificNotation // like 1.5e6
+	SINotation         // like 1.5M or 20µ
+	BinaryNotation     // like 1.5Mi or 512Ki, i.e. powers of 1024
+
+	// AutoSINotation is like AutoNotation but uses SINotation instead
+	// of ScientificNotation.
+	AutoSINotation
+)
+
+// NumberFormat formats numbers, typically the breaks of a continuous scale.
+// The precision is chosen automatically: The values are printed with the
+// minimal number of decimals which shows all of them exactly (up to a tiny
+// fraction of their distance), so 0.5, 1, 1.5 are formated as "0.5", "1.0",
+// "1.5" and 1000, 2000 as "1000", "2000".
+type NumberFormat struct {
+	Notation Notation
+
+	// Multiplier is applied to all values before formating, e.g. 100
+	// to format fractions as percent. Zero means no multiplication.
+	Multiplier float64
+
+	// Prefix and Suffix are prepended and appended to each number,
+	// e.g. "$" or "%".
+	Prefix, Suffix string
+
+	// Decimal is the decimal mark (default ".") and Thousands is the
+	// separator between groups of three digits in the integer part
+	// (default none). See NumberLocales for common choices.
+	Decimal, Thousands string
+}
+
+// NumberLocale is the decimal mark and the thousands separator used in a
+// locale.
+type NumberLocale struct {
+	Decimal, Thousands string
+}
+
+// NumberLocales contains the number conventions of some locales.
+var NumberLocales = map[string]NumberLocale{
+	"en":    {".", ","},
+	"en_US": {".", ","},
+	"en_GB": {".", ","},
+	"de":    {",", "."},
+	"de_DE": {",", "."},
+	"de_CH": {".", "’"},
+	"fr":    {",", " "},
+	"fr_FR": {",", " "},
+	"it":    {",", "."},
+	"es":    {",", "."},
+	"nl":    {",", "."},
+	"ja":    {".", ","},
+	"zh":    {".", ","},
+}
+
+// Some commonly used number formats.
+var (
+	// PercentFormat formats fractions as percentages: 0.25 is "25%".
+	PercentFormat = NumberFormat{Notation: FixedNotation, Multiplier: 100, Suffix: "%"}
+
+	// BytesFormat formats byte counts with binary prefixes: 1536 is "1.5KiB".
+	BytesFormat = NumberFormat{Notation: BinaryNotation, Suffix: "B"}
+)
+
+// CurrencyFormat returns a format for amounts of money in the given
+// currency symbol with thousands separated by ",", e.g. "$1,500".
+func CurrencyFormat(symbol string) NumberFormat {
+	return NumberFormat{Notation: FixedNotation, Prefix: symbol, Thousands: ","}
+}
+
+// LocaleFormat returns a fixed format with the decimal mark and thousands
+// separator of the given locale (see NumberLocales). Unknown locales use
+// the conventions of "en".
+func LocaleFormat(locale string) NumberFormat {
+	loc, ok := NumberLocales[locale]
+	if !ok {
+		loc = NumberLocales["en"]
+	}
+	return NumberFormat{Notation: FixedNotation, Decimal: loc.Decimal, Thousands: loc.Thousands}
+}
+
+// Format formats all values. The precision is chosen so that the values
+// can be distinguished and are shown exactly.
+func (nf NumberFormat) Format(values []float64) []string {
+	m := nf.Multiplier
+	if m == 0 {
+		m = 1
+	}
+	vs := make([]float64, len(values))
+	maxAbs, minAbs := 0.0, math.Inf(+1)
+	for i, v := range values {
+		vs[i] = v * m
+		if a := math.Abs(vs[i]); a != 0 && !math.IsInf(a, 0) && !math.IsNaN(a) {
+			maxAbs = math.Max(maxAbs, a)
+			minAbs = math.Min(minAbs, a)
+		}
+	}
+	tol := formatTolerance(vs, maxAbs)
+
+	notation := nf.Notation
+	if notation == AutoNotation || notation == AutoSINotation {
+		extreme := ScientificNotation
+		if notation == AutoSINotation {
+			extreme = SINotation
+		}
+		notation = FixedNotation
+		if maxAbs >= 1e6 || (maxAbs > 0 && maxAbs < 1e-4) {
+			notation = extreme
+		}
+	}
+
+	labels := make([]string, len(vs))
+	switch notation {
+	case ScientificNotation:
+		d := 0
+		for d < 15 && !reproduces(vs, tol, func(v float64) string { return strconv.FormatFloat(v, 'e', d, 64) }) {
+			d++
+		}
+		for i, v := range vs {
+			labels[i] = nf.decorate(tidyExponent(strconv.FormatFloat(v, 'e', d, 64)), "")
+		}
+	case SINotation, BinaryNotation:
+		base, prefixes, zero := 1000.0, siPrefixes, 8
+		if notation == BinaryNotation {
+			base, prefixes, zero = 1024, binaryPrefixes, 0
+		}
+		exps := make([]int, len(vs))
+		mantissas := make([]float64, len(vs))
+		for i, v := range vs {
+			e := 0
+			if a := math.Abs(v); a != 0 && !math.IsInf(a, 0) && !math.IsNaN(a) {
+				e = int(math.Floor(math.Log(a)/math.Log(base) + 1e-9))
+			}
+			if e+zero < 0 {
+				e = -zero
+			} else if e+zero >= len(prefixes) {
+				e = len(prefixes) - 1 - zero
+			}
+			exps[i] = e
+			mantissas[i] = v / math.Pow(base, float64(e))
+		}
+		// Prefixes differ between values so each value gets its own
+		// precision: "500", "1k", "1.5k".
+		for i, v := range mantissas {
+			d := minDecimals([]float64{v}, tol/math.Pow(base, float64(exps[i])))
+			labels[i] = nf.decorate(strconv.FormatFloat(v, 'f', d, 64), prefixes[exps[i]+zero])
+		}
+	default:
+		if minAbs > 0 && maxAbs/minAbs >= 1000 {
+			// Values of very different magnitudes (e.g. on log scales)
+			// are formated individually to avoid "1000.000".
+			for i, v := range vs {
+				d := minDecimals([]float64{v}, math.Abs(v)*1e-9)
+				labels[i] = nf.decorate(strconv.FormatFloat(v, 'f', d, 64), "")
+			}
+			break
+		}
+		d := minDecimals(vs, tol)
+		for i, v := range vs {
+			labels[i] = nf.decorate(strconv.FormatFloat(v, 'f', d, 64), "")
+		}
+	}
+	return labels
+}
+
+var siPrefixes = []string{"y", "z", "a", "f", "p", "n", "µ", "m", "",
+	"k", "M", "G", "T", "P", "E", "Z", "Y"}
+
+var binaryPrefixes = []string{"", "Ki", "Mi", "Gi", "Ti", "Pi", "Ei", "Zi", "Yi"}
+
+// formatTolerance returns how exact the formated values vs must be: A tiny
+// fraction of the smallest distance between different values.
+func formatTolerance(vs []float64, maxAbs float64) float64 {
+	sorted := make([]float64, 0, len(vs))
+	for _, v := range vs {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Float64s(sorted)
+	gap := math.Inf(+1)
+	for i := 1; i < len(sorted); i++ {
+		if d := sorted[i] - sorted[i-1]; d > 0 && d < gap {
+			gap = d
+		}
+	}
+	if math.IsInf(gap, +1) {
+		gap = maxAbs
+	}
+	if gap == 0 {
+		gap = 1
+	}
+	return gap * 1e-6
+}
+
+// minDecimals returns the minimal number of decimals needed to print all
+// values up to tol in fixed notation.
+func minDecimals(values []float64, tol float64) int {
+	d := 0
+	for d < 15 && !reproduces(values, tol, func(v float64) string { return strconv.FormatFloat(v, 'f', d, 64) }) {
+		d++
+	}
+	return d
+}
+
+// reproduces reports whether format(v) parses back to v up to tol for all
+// values.
+func reproduces(values []float64, tol float64, format func(float64) string) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		x, err := strconv.ParseFloat(format(v), 64)
+		if err != nil || math.Abs(x-v) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// tidyExponent rewrites "1.5e+06" to "1.5e6" and "2e-05" to "2e-5".
+func tidyExponent(s string) string {
+	i := strings.IndexByte(s, 'e')
+	if i == -1 {
+		return s
+	}
+	mant, exp := s[:i], s[i+1:]
+	sign := ""
+	if exp[0] == '-' {
+		sign = "-"
+	}
+	exp = strings.TrimLeft(exp[1:], "0")
+	if exp == "" {
+		exp = "0"
+	}
+	return mant + "e" + sign + exp
+}
+
+// decorate applies the decimal mark, the thousands separator, the prefix
+// and the suffix to the plain number s and appends the unit prefix.
+func (nf NumberFormat) decorate(s string, unitPrefix string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+		if strings.Trim(s, "0.") == "" {
+			sign = "" // Avoid "-0.0".
+		}
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexAny(s, ".e"); i != -1 {
+		intPart, frac = s[:i], s[i:]
+	}
+	if nf.Thousands != "" && len(intPart) > 3 {
+		groups := []string{}
+		for len(intPart) > 3 {
+			groups = append([]string{intPart[len(intPart)-3:]}, groups...)
+			intPart = intPart[:len(intPart)-3]
+		}
+		intPart = intPart + nf.Thousands + strings.Join(groups, nf.Thousands)
+	}
+	if nf.Decimal != "" && strings.HasPrefix(frac, ".") {
+		frac = nf.Decimal + frac[1:]
+	}
+	return sign + nf.Prefix + intPart + frac + unitPrefix + nf.Suffix
+}
@@ -0,0 +1,49 @@
+package plot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumberFormat(t *testing.T) {
+	for i, tc := range []struct {
+		format NumberFormat
+		values []float64
+		want   string
+	}{
+		{NumberFormat{}, []float64{0, 0.5, 1, 1.5}, "0.0 0.5 1.0 1.5"},
+		{NumberFormat{}, []float64{0, 0.02, 0.04}, "0.00 0.02 0.04"},
+		{NumberFormat{}, []float64{1000, 2000, 3000}, "1000 2000 3000"},
+		{NumberFormat{}, []float64{-0.1 + 0.1, 0.1 + 0.2, 0.6}, "0.0 0.3 0.6"},
+		{NumberFormat{}, []float64{2.5e6, 5e6, 7.5e6}, "2.5e6 5.0e6 7.5e6"},
+		{NumberFormat{}, []float64{1e-5, 2e-5}, "1e-5 2e-5"},
+		{NumberFormat{}, []float64{0.001, 0.01, 0.1, 1, 10, 100, 1000}, "0.001 0.01 0.1 1 10 100 1000"},
+		{NumberFormat{Notation: SINotation}, []float64{0, 500, 1000, 1500, 2e6}, "0 500 1k 1.5k 2M"},
+		{NumberFormat{Notation: SINotation, Suffix: "s"}, []float64{2e-6, 4e-6}, "2µs 4µs"},
+		{NumberFormat{Notation: AutoSINotation}, []float64{0, 0.5, 1}, "0.0 0.5 1.0"},
+		{NumberFormat{Notation: AutoSINotation}, []float64{2.5e6, 5e6, 7.5e6}, "2.5M 5M 7.5M"},
+		{NumberFormat{Notation: AutoSINotation}, []float64{1e-5, 2e-5}, "10µ 20µ"},
+		{BytesFormat, []float64{512, 1024, 1536, 1 << 20}, "512B 1KiB 1.5KiB 1MiB"},
+		{PercentFormat, []float64{0, 0.25, 0.5}, "0% 25% 50%"},
+		{PercentFormat, []float64{0.001, 0.002}, "0.1% 0.2%"},
+		{CurrencyFormat("$"), []float64{-1500, 0, 1500, 1234567}, "-$1,500 $0 $1,500 $1,234,567"},
+		{LocaleFormat("de"), []float64{1000.5, 2000, 2500}, "1.000,5 2.000,0 2.500,0"},
+		{LocaleFormat("de_CH"), []float64{1e6, 2e6}, "1’000’000 2’000’000"},
+	} {
+		got := strings.Join(tc.format.Format(tc.values), " ")
+		if got != tc.want {
+			t.Errorf("%d: Got %q, want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestChooseFloatFormatter(t *testing.T) {
+	s := NewScale("x", "x", Float)
+	s.Breaks = []float64{0, 0.5, 1, 1.5}
+	f := s.ChooseFloatFormatter()
+	for x, want := range map[float64]string{0: "0.0", 1: "1.0", 1.5: "1.5", 0.25: "0.25", 3: "3"} {
+		if got := f(x); got != want {
+			t.Errorf("Got %q for %g, want %q", got, x, want)
+		}
+	}
+}
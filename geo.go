@@ -0,0 +1,342 @@
+package plot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// -------------------------------------------------------------------------
+// Map Projections
+
+// Projection maps longitude and latitude (in degrees) to the plane.
+// The result may be scaled and shifted arbitrarily: CoordMap fits the
+// projected area into the panel.
+type Projection interface {
+	Project(lon, lat float64) (x, y float64)
+}
+
+// fitter is implemented by projections whose parameters can be fitted to
+// the plotted range of longitudes and latitudes.
+type fitter interface {
+	fit(lonMin, lonMax, latMin, latMax float64) Projection
+}
+
+const deg = math.Pi / 180
+
+// Equirectangular is the equirectangular projection: Meridians and
+// parallels are equidistant straight lines, distances are true along
+// the parallel Lat0. Auto uses the center of the plotted latitudes as
+// Lat0.
+type Equirectangular struct {
+	Lat0 float64
+	Auto bool
+}
+
+func (p Equirectangular) Project(lon, lat float64) (float64, float64) {
+	return lon * math.Cos(p.Lat0*deg), lat
+}
+
+func (p Equirectangular) fit(lonMin, lonMax, latMin, latMax float64) Projection {
+	if p.Auto {
+		p.Lat0 = (latMin + latMax) / 2
+	}
+	return p
+}
+
+// Mercator is the conformal Mercator projection. Latitudes are limited
+// to ±85 degrees.
+type Mercator struct{}
+
+func (Mercator) Project(lon, lat float64) (float64, float64) {
+	lat = math.Max(-85, math.Min(85, lat))
+	return lon * deg, math.Log(math.Tan(math.Pi/4 + lat*deg/2))
+}
+
+// LambertConformalConic is the conformal conic projection of Lambert
+// with the standard parallels Lat1 and Lat2 and central meridian Lon0.
+// Auto uses the center of the plotted longitudes as central meridian and
+// places the standard parallels at one and five sixth of the plotted
+// latitudes.
+type LambertConformalConic struct {
+	Lon0, Lat1, Lat2 float64
+	Auto             bool
+}
+
+func (p LambertConformalConic) Project(lon, lat float64) (float64, float64) {
+	phi1, phi2 := p.Lat1*deg, p.Lat2*deg
+	t := func(phi float64) float64 { return math.Tan(math.Pi/4 + phi/2) }
+	n := math.Sin(phi1)
+	if math.Abs(phi1-phi2) > 1e-9 {
+		n = math.Log(math.Cos(phi1)/math.Cos(phi2)) / math.Log(t(phi2)/t(phi1))
+	}
+	if math.Abs(n) < 1e-9 {
+		// Cone degenerates to a cylinder.
+		return Mercator{}.Project(lon-p.Lon0, lat)
+	}
+	lat = math.Max(-89, math.Min(89, lat))
+	rho := math.Cos(phi1) * math.Pow(t(phi1), n) / n / math.Pow(t(lat*deg), n)
+	theta := n * (lon - p.Lon0) * deg
+	return rho * math.Sin(theta), -rho * math.Cos(theta)
+}
+
+func (p LambertConformalConic) fit(lonMin, lonMax, latMin, latMax float64) Projection {
+	if p.Auto {
+		p.Lon0 = (lonMin + lonMax) / 2
+		p.Lat1 = latMin + (latMax-latMin)/6
+		p.Lat2 = latMin + 5*(latMax-latMin)/6
+	}
+	return p
+}
+
+// Orthographic is the view of the globe from infinity above the point
+// (Lon0,Lat0). Points on the far side of the globe are moved to the
+// horizon. Auto looks at the center of the plotted area.
+type Orthographic struct {
+	Lon0, Lat0 float64
+	Auto       bool
+}
+
+func (p Orthographic) Project(lon, lat float64) (float64, float64) {
+	phi, phi0, dl := lat*deg, p.Lat0*deg, (lon-p.Lon0)*deg
+	x := math.Cos(phi) * math.Sin(dl)
+	y := math.Cos(phi0)*math.Sin(phi) - math.Sin(phi0)*math.Cos(phi)*math.Cos(dl)
+	if math.Sin(phi0)*math.Sin(phi)+math.Cos(phi0)*math.Cos(phi)*math.Cos(dl) < 0 {
+		if r := math.Hypot(x, y); r > 0 {
+			x, y = x/r, y/r
+		}
+	}
+	return x, y
+}
+
+func (p Orthographic) fit(lonMin, lonMax, latMin, latMax float64) Projection {
+	if p.Auto {
+		p.Lon0, p.Lat0 = (lonMin+lonMax)/2, (latMin+latMax)/2
+	}
+	return p
+}
+
+// -------------------------------------------------------------------------
+// Map Coordinates
+
+// CoordMap is the coordinate system of maps: x is the longitude and y
+// the latitude in degrees, both are projected by Projection (nil means
+// Mercator). Lines and polygons are densified before projecting so that
+// straight segments follow the projection and the grid lines become the
+// graticule. The panels keep the aspect ratio of the projected area.
+type CoordMap struct {
+	Projection Projection
+
+	// The range of longitudes and latitudes and of their projection
+	// once bound to the scales of a panel.
+	bound                          bool
+	lonMin, lonMax, latMin, latMax float64
+	xMin, xMax, yMin, yMax         float64
+}
+
+var _ Coord = CoordMap{}
+
+func (c CoordMap) Transform(x, y float64) (float64, float64) {
+	if !c.bound {
+		return x, y
+	}
+	lon := c.lonMin + x*(c.lonMax-c.lonMin)
+	lat := c.latMin + y*(c.latMax-c.latMin)
+	px, py := c.Projection.Project(lon, lat)
+	return (px - c.xMin) / (c.xMax - c.xMin), (py - c.yMin) / (c.yMax - c.yMin)
+}
+
+func (CoordMap) Flipped() bool { return false }
+func (CoordMap) Linear() bool  { return false }
+
+func (c CoordMap) aspect(panel *Panel) float64 {
+	c = c.bind(panel.Scales["x"], panel.Scales["y"])
+	if c.xMax <= c.xMin || c.yMax <= c.yMin {
+		return 0 // degenerated scales: no sensible ratio
+	}
+	return (c.yMax - c.yMin) / (c.xMax - c.xMin)
+}
+
+// bind returns c fitted to the longitudes of scale sx and the latitudes
+// of scale sy.
+func (c CoordMap) bind(sx, sy *Scale) CoordMap {
+	c.lonMin, c.lonMax, c.latMin, c.latMax = sx.Min, sx.Max, sy.Min, sy.Max
+	if c.Projection == nil {
+		c.Projection = Mercator{}
+	}
+	if f, ok := c.Projection.(fitter); ok {
+		c.Projection = f.fit(c.lonMin, c.lonMax, c.latMin, c.latMax)
+	}
+
+	// The projected area is not rectangular in general: Determine its
+	// bounding box from a grid of points.
+	const n = 32
+	c.xMin, c.yMin = math.Inf(+1), math.Inf(+1)
+	c.xMax, c.yMax = math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= n; i++ {
+		lon := c.lonMin + float64(i)/n*(c.lonMax-c.lonMin)
+		for j := 0; j <= n; j++ {
+			lat := c.latMin + float64(j)/n*(c.latMax-c.latMin)
+			x, y := c.Projection.Project(lon, lat)
+			c.xMin, c.xMax = math.Min(c.xMin, x), math.Max(c.xMax, x)
+			c.yMin, c.yMax = math.Min(c.yMin, y), math.Max(c.yMax, y)
+		}
+	}
+	c.bound = c.xMax > c.xMin && c.yMax > c.yMin
+	return c
+}
+
+// coord returns the coordinate system of panel: A CoordMap is bound to the
+// scales of the panel.
+func (panel *Panel) coord() Coord {
+	c := panel.Plot.coord()
+	if m, ok := c.(CoordMap); ok {
+		return m.bind(panel.Scales["x"], panel.Scales["y"])
+	}
+	return c
+}
+
+// -------------------------------------------------------------------------
+// GeoJSON
+
+// ReadGeoJSON reads the outlines of the features in the GeoJSON document
+// r into a data frame with the fields Lon, Lat, Feature, Group and Piece.
+// Each ring of a polygon and each line string is its own Group, Piece
+// numbers the rings and lines inside a Feature (holes of polygons are
+// separate pieces). The Feature is named by the "name" property or the
+// id of the feature. Points are returned as Groups of one point.
+//
+// Use it e.g. with a GeomPolygon and the mapping "group": "Group".
+// GeomPolygon fills each group on its own: Holes are not cut out of their
+// polygon but drawn as filled polygons on top of it.
+// Strings are stored in pool, normaly the Pool of the plot.
+func ReadGeoJSON(r io.Reader, pool *StringPool) (*DataFrame, error) {
+	var doc geoJSON
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	reader := geoJSONReader{}
+	switch doc.Type {
+	case "FeatureCollection":
+		for i, f := range doc.Features {
+			if err := reader.feature(f, i); err != nil {
+				return nil, err
+			}
+		}
+	case "Feature":
+		if err := reader.feature(doc, 0); err != nil {
+			return nil, err
+		}
+	default:
+		if err := reader.geometry(&doc, "feature 0"); err != nil {
+			return nil, err
+		}
+	}
+	df, err := NewDataFrameFrom(reader.rows, pool)
+	if err != nil {
+		return nil, err
+	}
+	df.Name = "GeoJSON"
+	return df, nil
+}
+
+// geoJSON is a GeoJSON object: A feature collection, a feature or a
+// geometry.
+type geoJSON struct {
+	Type        string                 `json:"type"`
+	Features    []geoJSON              `json:"features"`
+	Geometry    *geoJSON               `json:"geometry"`
+	Properties  map[string]interface{} `json:"properties"`
+	ID          interface{}            `json:"id"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+	Geometries  []geoJSON              `json:"geometries"`
+}
+
+// geoRow is one point of an outline.
+type geoRow struct {
+	Lon, Lat     float64
+	Feature      string
+	Group, Piece int
+}
+
+type geoJSONReader struct {
+	rows  []geoRow
+	group int
+	piece int
+}
+
+func (gr *geoJSONReader) feature(f geoJSON, i int) error {
+	name := fmt.Sprintf("feature %d", i)
+	if s, ok := f.Properties["name"].(string); ok {
+		name = s
+	} else if f.ID != nil {
+		name = fmt.Sprint(f.ID)
+	}
+	gr.piece = 0
+	if f.Geometry == nil {
+		return nil // Unlocated feature.
+	}
+	return gr.geometry(f.Geometry, name)
+}
+
+func (gr *geoJSONReader) geometry(g *geoJSON, name string) error {
+	var err error
+	switch g.Type {
+	case "Point":
+		var p [2]float64
+		if err = json.Unmarshal(g.Coordinates, &p); err == nil {
+			gr.line(name, [][2]float64{p})
+		}
+	case "MultiPoint", "LineString":
+		var line [][2]float64
+		if err = json.Unmarshal(g.Coordinates, &line); err == nil {
+			if g.Type == "LineString" {
+				gr.line(name, line)
+				break
+			}
+			for _, p := range line {
+				gr.line(name, [][2]float64{p})
+			}
+		}
+	case "MultiLineString", "Polygon":
+		var lines [][][2]float64
+		if err = json.Unmarshal(g.Coordinates, &lines); err == nil {
+			for _, line := range lines {
+				gr.line(name, line)
+			}
+		}
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err = json.Unmarshal(g.Coordinates, &polygons); err == nil {
+			for _, polygon := range polygons {
+				for _, ring := range polygon {
+					gr.line(name, ring)
+				}
+			}
+		}
+	case "GeometryCollection":
+		for i := range g.Geometries {
+			if err = gr.geometry(&g.Geometries[i], name); err != nil {
+				break
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported GeoJSON type %q", g.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("bad coordinates of %s %s: %s", g.Type, name, err)
+	}
+	return nil
+}
+
+// line adds the points of one line or ring as a new group.
+func (gr *geoJSONReader) line(name string, points [][2]float64) {
+	for _, p := range points {
+		gr.rows = append(gr.rows, geoRow{Lon: p[0], Lat: p[1],
+			Feature: name, Group: gr.group, Piece: gr.piece})
+	}
+	gr.group++
+	gr.piece++
+}
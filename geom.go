@@ -2,7 +2,12 @@ package plot
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"math"
 	"strings"
+
+	"gonum.org/v1/plot/vg"
 )
 
 var _ = fmt.Printf
@@ -55,6 +60,16 @@ func trainScales(p *Panel, data *DataFrame, spec string) {
 	}
 }
 
+// StatGeom is implemented by geoms which come with their own statistical
+// transform, e.g. GeomHistogram. The default stat is applied to layers
+// without a Stat.
+type StatGeom interface {
+	// DefaultStat returns the stat to apply to data and the stat mapping
+	// used unless the layer has its own StatMapping. Data is the prepared
+	// data of the layer in all panels; the stat is used in each panel.
+	DefaultStat(data *DataFrame) (Stat, AesMapping)
+}
+
 // -------------------------------------------------------------------------
 // Position Adjustments
 
@@ -77,6 +92,7 @@ type GeomPoint struct {
 }
 
 var _ Geom = GeomPoint{}
+var _ KeyGeom = GeomPoint{}
 
 func (p GeomPoint) Name() string            { return "GeomPoint" }
 func (p GeomPoint) NeededSlots() []string   { return []string{"x", "y"} }
@@ -122,13 +138,33 @@ func (p GeomPoint) Render(panel *Panel, data *DataFrame, style AesMapping) []Gro
 	return grobs
 }
 
+func (p GeomPoint) Key(k LegendKey, x, y, size float64) []Grob {
+	return []Grob{
+		GrobPoint{
+			x: x + size/2, y: y + size/2,
+			size:  k.Float("size", 1, 10),
+			shape: PointShape(k.Int("shape")),
+			color: SetAlpha(k.Color("color"), k.Float("alpha", 0, 1)),
+		}}
+}
+
 // -------------------------------------------------------------------------
 // Geom Line
 type GeomLine struct {
 	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+
+	// Arrow, if non nil, draws arrowheads at the ends of the lines.
+	Arrow *Arrow
+
+	// LineCap and LineJoin determine the shape of the line ends
+	// and the corners. The zero LineJoin keeps the corners drawn
+	// by the canvas.
+	LineCap  LineCap
+	LineJoin LineJoin
 }
 
 var _ Geom = GeomLine{}
+var _ KeyGeom = GeomLine{}
 
 func (p GeomLine) Name() string          { return "GeomLine" }
 func (p GeomLine) NeededSlots() []string { return []string{"x", "y"} }
@@ -183,6 +219,8 @@ func (p GeomLine) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob
 					color:    SetAlpha(colFunc(i), alphaFunc(i)),
 					size:     sizeFunc(i),
 					linetype: LineType(typeFunc(i)),
+					cap:      p.LineCap,
+					arrow:    p.Arrow.At(i == 0, i == part.N-2),
 				}
 				grobs = append(grobs, line)
 			}
@@ -198,6 +236,9 @@ func (p GeomLine) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob
 				color:    SetAlpha(colFunc(0), alphaFunc(0)),
 				size:     sizeFunc(0),
 				linetype: LineType(typeFunc(0)),
+				cap:      p.LineCap,
+				join:     p.LineJoin,
+				arrow:    p.Arrow,
 			}
 			grobs = append(grobs, path)
 		}
@@ -206,14 +247,26 @@ func (p GeomLine) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob
 	return grobs
 }
 
+func (p GeomLine) Key(k LegendKey, x, y, size float64) []Grob {
+	line := keyLine(k, x+0.1*size, y+size/2, x+0.9*size, y+size/2)
+	line.cap = p.LineCap
+	return []Grob{line}
+}
+
 // -------------------------------------------------------------------------
 // Geom ABLine
 type GeomABLine struct {
 	Intercept, Slope float64
 	Style            AesMapping // The individal fixed, aka non-mapped aesthetics
+
+	// Arrow, if non nil, draws arrowheads where the line leaves the
+	// panel. LineCap determines the shape of the line ends.
+	Arrow   *Arrow
+	LineCap LineCap
 }
 
 var _ Geom = GeomABLine{}
+var _ KeyGeom = GeomABLine{}
 
 func (p GeomABLine) Name() string            { return "GeomABLine" }
 func (p GeomABLine) NeededSlots() []string   { return []string{"intercept", "slope"} }
@@ -266,6 +319,8 @@ func (p GeomABLine) Render(panel *Panel, data *DataFrame, style AesMapping) []Gr
 			color:    SetAlpha(colFunc(i), alphaFunc(i)),
 			size:     sizeFunc(i),
 			linetype: LineType(typeFunc(i)),
+			cap:      p.LineCap,
+			arrow:    p.Arrow,
 		}
 		grobs[i] = line
 	}
@@ -273,6 +328,173 @@ func (p GeomABLine) Render(panel *Panel, data *DataFrame, style AesMapping) []Gr
 	return grobs
 }
 
+func (p GeomABLine) Key(k LegendKey, x, y, size float64) []Grob {
+	line := keyLine(k, x+0.1*size, y+0.1*size, x+0.9*size, y+0.9*size)
+	line.cap = p.LineCap
+	return []Grob{line}
+}
+
+// -------------------------------------------------------------------------
+// Geom Segment
+
+// GeomSegment draws straight lines from (x,y) to (xend,yend), e.g. the
+// arrows of flow diagrams.
+type GeomSegment struct {
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+
+	// Arrow, if non nil, draws arrowheads at the ends of the segments.
+	// LineCap determines the shape of the segment ends.
+	Arrow   *Arrow
+	LineCap LineCap
+}
+
+var _ Geom = GeomSegment{}
+var _ KeyGeom = GeomSegment{}
+
+func (s GeomSegment) Name() string          { return "GeomSegment" }
+func (s GeomSegment) NeededSlots() []string { return []string{"x", "y", "xend", "yend"} }
+func (s GeomSegment) OptionalSlots() []string {
+	return []string{"color", "size", "linetype", "alpha"}
+}
+
+func (s GeomSegment) Aes(plot *Plot) AesMapping {
+	return MergeStyles(s.Style, plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (s GeomSegment) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	trainScales(panel, df, "x:x,xend y:y,yend")
+	return []Fundamental{
+		Fundamental{
+			Geom: s,
+			Data: df,
+		}}
+}
+
+func (s GeomSegment) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x, y := data.Columns["x"].Data, data.Columns["y"].Data
+	xend, yend := data.Columns["xend"].Data, data.Columns["yend"].Data
+	grobs := renderIntervalLines(panel, data, style, 1,
+		func(i, j int) (float64, float64, float64, float64) {
+			return x[i], y[i], xend[i], yend[i]
+		})
+	for i := range grobs {
+		line := grobs[i].(GrobLine)
+		line.cap, line.arrow = s.LineCap, s.Arrow
+		grobs[i] = line
+	}
+	return grobs
+}
+
+func (s GeomSegment) Key(k LegendKey, x, y, size float64) []Grob {
+	line := keyLine(k, x+0.1*size, y+size/2, x+0.9*size, y+size/2)
+	line.cap = s.LineCap
+	return []Grob{line}
+}
+
+// -------------------------------------------------------------------------
+// Geom Rug
+
+// GeomRug draws short ticks at the x and/or y values along the edges of
+// the panel, a compact display of the marginal distributions.
+type GeomRug struct {
+	// Sides controls on which sides of the panel the ticks are drawn:
+	// Any combination of "b" (bottom) and "t" (top) for the x values and
+	// "l" (left) and "r" (right) for the y values. Empty means "bl".
+	Sides string
+
+	// Length of the ticks as a fraction of the panel width or height.
+	// Zero means 0.03.
+	Length float64
+
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomRug{}
+var _ KeyGeom = GeomRug{}
+
+func (r GeomRug) Name() string          { return "GeomRug" }
+func (r GeomRug) NeededSlots() []string { return []string{} }
+func (r GeomRug) OptionalSlots() []string {
+	return []string{"x", "y", "color", "size", "linetype", "alpha"}
+}
+
+func (r GeomRug) Aes(plot *Plot) AesMapping {
+	return MergeStyles(r.Style, plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (r GeomRug) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	if !df.Has("x") && !df.Has("y") {
+		panel.Plot.Warnf("GeomRug needs x or y, got neither in %s.", df.Name)
+		return nil
+	}
+	return []Fundamental{
+		Fundamental{
+			Geom: r,
+			Data: df,
+		}}
+}
+
+func (r GeomRug) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	sides := r.Sides
+	if sides == "" {
+		sides = "bl"
+	}
+	length := r.Length
+	if length == 0 {
+		length = 0.03
+	}
+
+	colFunc := makeColorFunc("color", data, panel, style)
+	sizeFunc := makePosFunc("size", data, panel, style, 0, 1)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+	typeFunc := makeStyleFunc("linetype", data, panel, style)
+
+	grobs := make([]Grob, 0)
+	tick := func(i int, x0, y0, x1, y1 float64) {
+		grobs = append(grobs, GrobLine{
+			x0: x0, y0: y0, x1: x1, y1: y1,
+			color:    SetAlpha(colFunc(i), alphaFunc(i)),
+			size:     sizeFunc(i),
+			linetype: LineType(typeFunc(i)),
+		})
+	}
+
+	if data.Has("x") {
+		x, xf := data.Columns["x"].Data, panel.Scales["x"].Pos
+		for i := 0; i < data.N; i++ {
+			xv := xf(x[i])
+			if strings.Contains(sides, "b") {
+				tick(i, xv, 0, xv, length)
+			}
+			if strings.Contains(sides, "t") {
+				tick(i, xv, 1, xv, 1-length)
+			}
+		}
+	}
+	if data.Has("y") {
+		y, yf := data.Columns["y"].Data, panel.Scales["y"].Pos
+		for i := 0; i < data.N; i++ {
+			yv := yf(y[i])
+			if strings.Contains(sides, "l") {
+				tick(i, 0, yv, length, yv)
+			}
+			if strings.Contains(sides, "r") {
+				tick(i, 1, yv, 1-length, yv)
+			}
+		}
+	}
+
+	return grobs
+}
+
+func (r GeomRug) Key(k LegendKey, x, y, size float64) []Grob {
+	grobs := make([]Grob, 0, 3)
+	for _, u := range []float64{0.25, 0.5, 0.75} {
+		grobs = append(grobs, keyLine(k, x+u*size, y, x+u*size, y+0.3*size))
+	}
+	return grobs
+}
+
 // -------------------------------------------------------------------------
 // Geom Text
 
@@ -281,6 +503,7 @@ type GeomText struct {
 }
 
 var _ Geom = GeomText{}
+var _ KeyGeom = GeomText{}
 
 func (t GeomText) Name() string            { return "GeomText" }
 func (t GeomText) NeededSlots() []string   { return []string{"x", "y", "text"} }
@@ -332,34 +555,199 @@ func (t GeomText) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob
 	return grobs
 }
 
+func (t GeomText) Key(k LegendKey, x, y, size float64) []Grob {
+	return []Grob{keyText(k, x+size/2, y+size/2)}
+}
+
+// keyText returns the letter "a" centered at (x,y) in the style of the
+// legend key k. Mapped text sizes are not shown: All letters are drawn
+// in the fixed size or in 10pt.
+func keyText(k LegendKey, x, y float64) GrobText {
+	fontsize := 10.0
+	if s := k.Style["size"]; s != "" && k.Aes != "size" {
+		fontsize = String2Float(s, 4, 20)
+	}
+	return GrobText{
+		x: x, y: y,
+		text:  "a",
+		color: SetAlpha(k.Color("color"), k.Float("alpha", 0, 1)),
+		size:  fontsize,
+		vjust: 0.5, hjust: 0.5,
+	}
+}
+
+// -------------------------------------------------------------------------
+// Geom Label
+
+// GeomLabel draws text in a padded box with rounded corners. Besides the
+// usual text aesthetics the style understands "fill" (box background),
+// "linetype" and "linesize" (box border and leader segments), "padding"
+// and "radius" (of the corners), e.g. "1.5 mm".
+type GeomLabel struct {
+	// Repel enables an iterative nudging of the labels away from each
+	// other and from the labeled points. Labels moved far enough from
+	// their point are connected to it by a leader segment.
+	Repel bool
+
+	// Seed is the seed of the random tie breaking while repelling.
+	// The same seed produces the same placement of the labels.
+	Seed int64
+
+	// Iterations limits the number of repel iterations. Zero means 500.
+	Iterations int
+
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomLabel{}
+var _ KeyGeom = GeomLabel{}
+
+// labelStyle contains the defaults for the box of GeomLabel.
+var labelStyle = AesMapping{
+	"size":     "10",
+	"fill":     "white",
+	"linetype": "solid",
+	"linesize": "0.5",
+	"padding":  "1 mm",
+	"radius":   "1.5 mm",
+}
+
+func (l GeomLabel) Name() string          { return "GeomLabel" }
+func (l GeomLabel) NeededSlots() []string { return []string{"x", "y", "text"} }
+func (l GeomLabel) OptionalSlots() []string {
+	return []string{"color", "fill", "size", "alpha"}
+}
+
+func (l GeomLabel) Aes(plot *Plot) AesMapping {
+	return MergeStyles(l.Style, plot.Theme.TextStyle, DefaultTheme.TextStyle, labelStyle)
+}
+
+func (l GeomLabel) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	// Only scale training
+	x, y := df.Columns["x"].Data, df.Columns["y"].Data
+	sx, sy := panel.Scales["x"], panel.Scales["y"]
+	for i := 0; i < df.N; i++ {
+		sx.TrainByValue(x[i])
+		sy.TrainByValue(y[i])
+	}
+	return []Fundamental{
+		Fundamental{
+			Geom: l,
+			Data: df,
+		}}
+}
+
+func (l GeomLabel) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x, y, s := data.Columns["x"], data.Columns["y"], data.Columns["text"]
+	xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+
+	colFunc := makeColorFunc("color", data, panel, style)
+	fillFunc := makeColorFunc("fill", data, panel, style)
+	sizeFunc := makePosFunc("size", data, panel, style, 0, 1)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+	linetype := String2LineType(style["linetype"])
+	linesize := String2Float(style["linesize"], 0, 100)
+	padding := vg.Length(String2Float(style["padding"], 0, 100))
+	radius := vg.Length(String2Float(style["radius"], 0, 100))
+
+	labels := make([]GrobLabel, data.N)
+	for i := 0; i < data.N; i++ {
+		color := SetAlpha(colFunc(i), alphaFunc(i))
+		labels[i] = GrobLabel{
+			x: xf(x.Data[i]),
+			y: yf(y.Data[i]),
+			text: GrobText{
+				text:  s.String(s.Data[i]),
+				color: color,
+				size:  sizeFunc(i),
+			},
+			fill:     SetAlpha(fillFunc(i), alphaFunc(i)),
+			color:    color,
+			linetype: linetype,
+			size:     linesize,
+			padding:  padding,
+			radius:   radius,
+		}
+	}
+
+	if l.Repel {
+		return []Grob{
+			GrobRepel{
+				labels:     labels,
+				seed:       l.Seed,
+				iterations: l.Iterations,
+			}}
+	}
+	grobs := make([]Grob, data.N)
+	for i := range labels {
+		grobs[i] = labels[i]
+	}
+	return grobs
+}
+
+func (l GeomLabel) Key(k LegendKey, x, y, size float64) []Grob {
+	alpha := k.Float("alpha", 0, 1)
+	color := SetAlpha(k.Color("color"), alpha)
+	return []Grob{
+		GrobLabel{
+			x: x + size/2, y: y + size/2,
+			text:     keyText(k, 0, 0),
+			fill:     SetAlpha(k.Color("fill"), alpha),
+			color:    color,
+			linetype: String2LineType(k.Style["linetype"]),
+			size:     String2Float(k.Style["linesize"], 0, 100),
+			padding:  vg.Length(String2Float(k.Style["padding"], 0, 100)) / 2,
+			radius:   vg.Length(String2Float(k.Style["radius"], 0, 100)) / 2,
+		}}
+}
+
 // -------------------------------------------------------------------------
 // Geom Bar
 
+// GeomBar draws bars from zero to y centered at x. Horizontal bars are
+// drawn from zero to x centered at y.
 type GeomBar struct {
 	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
 	Position PositionAdjust
+
+	// Width of the bars as a fraction of the resolution of x (or y for
+	// horizontal bars). Zero means 0.9. Ignored if width is mapped.
+	Width float64
+
+	// Horizontal bars extend along the x axis.
+	Horizontal bool
 }
 
 var _ Geom = GeomBar{}
+var _ KeyGeom = GeomBar{}
 
-func (b GeomBar) Name() string            { return "GeomBar" }
-func (b GeomBar) NeededSlots() []string   { return []string{"x", "y"} }
-func (b GeomBar) OptionalSlots() []string { return []string{"color", "size", "linetype", "alpha"} }
+func (b GeomBar) Name() string          { return "GeomBar" }
+func (b GeomBar) NeededSlots() []string { return []string{"x", "y"} }
+func (b GeomBar) OptionalSlots() []string {
+	return []string{"width", "color", "fill", "size", "linetype", "alpha"}
+}
 
 func (b GeomBar) Aes(plot *Plot) AesMapping {
 	return MergeStyles(b.Style, plot.Theme.BarStyle, DefaultTheme.BarStyle)
 }
 
-func (b GeomBar) Construct(df *DataFrame, panel *Panel) []Fundamental {
-	xf := df.Columns["x"]
-	xd := xf.Data
+// rects replaces the fields x, y and width in df by the xmin, xmax, ymin
+// and ymax of the (stacked or dodged) bars.
+func (b GeomBar) rects(df *DataFrame) {
+	pos, val := "x", "y"
+	if b.Horizontal {
+		pos, val = "y", "x"
+	}
+	pf := df.Columns[pos]
+	xd := pf.Data
 	if !df.Has("width") {
-		width := xf.Resolution() * 0.9 // TODO: read from style
-		println("GeomBar: no width in data frame, will use", width)
-		wf := xf.Const(width, df.N)
-		df.Columns["width"] = wf
+		width := b.Width
+		if width <= 0 {
+			width = 0.9
+		}
+		df.Columns["width"] = NewField(0, Float, df.Pool).Const(width*pf.Resolution(), df.N)
 	}
-	yd, wd := df.Columns["y"].Data, df.Columns["width"].Data
+	yd, wd := df.Columns[val].Data, df.Columns["width"].Data
 
 	pool := df.Pool
 	xminf, yminf := NewField(df.N, Float, pool), NewField(df.N, Float, pool)
@@ -426,6 +814,10 @@ func (b GeomBar) Construct(df *DataFrame, panel *Panel) []Fundamental {
 		}
 	}
 
+	if b.Horizontal {
+		xminf, yminf = yminf, xminf
+		xmaxf, ymaxf = ymaxf, xmaxf
+	}
 	df.Columns["xmin"] = xminf
 	df.Columns["ymin"] = yminf
 	df.Columns["xmax"] = xmaxf
@@ -433,21 +825,174 @@ func (b GeomBar) Construct(df *DataFrame, panel *Panel) []Fundamental {
 	df.Delete("width")
 	df.Delete("x")
 	df.Delete("y")
+}
 
+func (b GeomBar) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	b.rects(df)
 	trainScales(panel, df, "x:xmin,xmax y:ymin,ymax")
-	// TODO: fill, color, .. too?
 
 	return []Fundamental{
 		Fundamental{
 			Geom: GeomRect{
-				Style: b.Style.Copy(),
+				Style: b.Aes(panel.Plot),
 			},
 			Data: df,
 		}}
 }
 
 func (b GeomBar) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
-	panic("Bar has no own render") // TODO: ugly. Maybe remodel Geom inheritance
+	// Bars are constructed as rects; this is just in case somebody
+	// renders unconstructed bars.
+	if !data.Has("xmin") {
+		data = data.Copy()
+		b.rects(data)
+	}
+	return GeomRect{Style: b.Style}.Render(panel, data, style)
+}
+
+func (b GeomBar) Key(k LegendKey, x, y, size float64) []Grob {
+	return keyRect(k, x+0.1*size, y+0.1*size, x+0.9*size, y+0.9*size)
+}
+
+// keyRect returns the filled rectangle (x0,y0)-(x1,y1) and its border in
+// the style of the legend key k. The border is omitted for blank lines.
+func keyRect(k LegendKey, x0, y0, x1, y1 float64) []Grob {
+	alpha := k.Float("alpha", 0, 1)
+	grobs := []Grob{
+		GrobRect{
+			xmin: x0, ymin: y0, xmax: x1, ymax: y1,
+			fill: SetAlpha(k.Color("fill"), alpha),
+		}}
+	lt := LineType(k.Int("linetype"))
+	if lt == BlankLine {
+		return grobs
+	}
+	points := []struct{ x, y float64 }{
+		{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0},
+	}
+	return append(grobs, GrobPath{
+		points:   points,
+		linetype: lt,
+		color:    SetAlpha(k.Color("color"), alpha),
+		size:     k.Float("size", 0, 1),
+	})
+}
+
+// -------------------------------------------------------------------------
+// Geom Col
+
+// GeomCol draws bars of height y at x, i.e. the data is displayed as is.
+// It is the same as GeomBar and exists to be explicit about the identity
+// heights in contrast to GeomHistogram.
+type GeomCol GeomBar
+
+var _ Geom = GeomCol{}
+var _ KeyGeom = GeomCol{}
+
+func (c GeomCol) Name() string              { return "GeomCol" }
+func (c GeomCol) NeededSlots() []string     { return GeomBar(c).NeededSlots() }
+func (c GeomCol) OptionalSlots() []string   { return GeomBar(c).OptionalSlots() }
+func (c GeomCol) Aes(plot *Plot) AesMapping { return GeomBar(c).Aes(plot) }
+
+func (c GeomCol) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	return GeomBar(c).Construct(df, panel)
+}
+
+func (c GeomCol) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	return GeomBar(c).Render(panel, data, style)
+}
+
+func (c GeomCol) Key(k LegendKey, x, y, size float64) []Grob {
+	return GeomBar(c).Key(k, x, y, size)
+}
+
+// -------------------------------------------------------------------------
+// Geom Histogram
+
+// GeomHistogram draws a histogram of x (or y if Horizontal). If the layer
+// has no stat, the data is binned with StatBin or, for discrete values,
+// counted with StatCount and the count is mapped to y (or x).
+type GeomHistogram struct {
+	// BinWidth is passed to StatBin. Zero means 30 bins.
+	BinWidth float64
+
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+	Position PositionAdjust
+
+	// Horizontal histograms show the distribution of y.
+	Horizontal bool
+}
+
+var _ Geom = GeomHistogram{}
+var _ KeyGeom = GeomHistogram{}
+var _ StatGeom = GeomHistogram{}
+
+func (h GeomHistogram) Name() string              { return "GeomHistogram" }
+func (h GeomHistogram) NeededSlots() []string     { return h.bar(false).NeededSlots() }
+func (h GeomHistogram) OptionalSlots() []string   { return h.bar(false).OptionalSlots() }
+func (h GeomHistogram) Aes(plot *Plot) AesMapping { return h.bar(false).Aes(plot) }
+
+func (h GeomHistogram) DefaultStat(data *DataFrame) (Stat, AesMapping) {
+	pos, count := "x", "y"
+	if h.Horizontal {
+		pos, count = "y", "x"
+	}
+	f := data.Columns[pos]
+	var stat Stat
+	if f.Type == String {
+		stat = StatCount{}
+	} else {
+		// Bin all groups (e.g. of a mapped fill) on the same bins.
+		bin := StatBin{BinWidth: h.BinWidth}
+		if min, max, mini, _ := f.MinMax(); mini != -1 && max > min {
+			if bin.BinWidth == 0 {
+				bin.BinWidth = (max - min) / 30
+			}
+			origin := math.Floor(min/bin.BinWidth) * bin.BinWidth
+			bin.Origin = &origin
+		}
+		stat = bin
+	}
+	if h.Horizontal {
+		stat = flippedStat{stat}
+	}
+	return stat, AesMapping{count: "count"}
+}
+
+// bar returns the GeomBar used to draw the histogram. Bins of a continuous
+// histogram touch each other; counted discrete values don't.
+func (h GeomHistogram) bar(discrete bool) GeomBar {
+	width := 1.0
+	if discrete {
+		width = 0.9
+	}
+	return GeomBar{
+		Style:      h.Style,
+		Position:   h.Position,
+		Width:      width,
+		Horizontal: h.Horizontal,
+	}
+}
+
+func (h GeomHistogram) discrete(df *DataFrame) bool {
+	pos := "x"
+	if h.Horizontal {
+		pos = "y"
+	}
+	return df.Columns[pos].Type == String
+}
+
+func (h GeomHistogram) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	return h.bar(h.discrete(df)).Construct(df, panel)
+}
+
+func (h GeomHistogram) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	discrete := data.Has("x") && data.Has("y") && h.discrete(data)
+	return h.bar(discrete).Render(panel, data, style)
+}
+
+func (h GeomHistogram) Key(k LegendKey, x, y, size float64) []Grob {
+	return h.bar(false).Key(k, x, y, size)
 }
 
 // -------------------------------------------------------------------------
@@ -458,6 +1003,7 @@ type GeomRect struct {
 }
 
 var _ Geom = GeomRect{}
+var _ KeyGeom = GeomRect{}
 
 func (r GeomRect) Name() string          { return "GeomRect" }
 func (r GeomRect) NeededSlots() []string { return []string{"xmin", "ymin", "xmax", "ymax"} }
@@ -537,77 +1083,827 @@ func (r GeomRect) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob
 	return grobs
 }
 
+func (r GeomRect) Key(k LegendKey, x, y, size float64) []Grob {
+	return keyRect(k, x+0.1*size, y+0.1*size, x+0.9*size, y+0.9*size)
+}
+
 // -------------------------------------------------------------------------
-// Geom Boxplot
+// Geom Polygon
 
-type GeomBoxplot struct {
-	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
-	Position PositionAdjust
+// GeomPolygon draws filled polygons through the points (x,y). Polygons are
+// closed automatically; several polygons are distinguished by group.
+type GeomPolygon struct {
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
 }
 
-var _ Geom = GeomBoxplot{}
+var _ Geom = GeomPolygon{}
+var _ KeyGeom = GeomPolygon{}
 
-func (b GeomBoxplot) Name() string { return "GeomBoxplot" }
-func (b GeomBoxplot) NeededSlots() []string {
-	return []string{"x", "min", "low", "mid", "high", "max"}
+func (p GeomPolygon) Name() string          { return "GeomPolygon" }
+func (p GeomPolygon) NeededSlots() []string { return []string{"x", "y"} }
+func (p GeomPolygon) OptionalSlots() []string {
+	return []string{"group", "color", "fill", "linetype", "alpha", "size"}
 }
-func (b GeomBoxplot) OptionalSlots() []string { return []string{"fill"} }
 
-func (b GeomBoxplot) Aes(plot *Plot) AesMapping {
-	return MergeStyles(b.Style, plot.Theme.RectStyle, DefaultTheme.RectStyle)
+func (p GeomPolygon) Aes(plot *Plot) AesMapping {
+	return MergeStyles(p.Style, plot.Theme.RectStyle, DefaultTheme.RectStyle)
 }
 
-func (b GeomBoxplot) Construct(data *DataFrame, panel *Panel) []Fundamental {
-	// min, max := data.Columns["min"].Data, data.Columns["max"].Data
-	low, high := data.Columns["low"].Data, data.Columns["high"].Data
-	q1, q3 := data.Columns["q1"].Data, data.Columns["q3"].Data
-	x, mid := data.Columns["x"].Data, data.Columns["mid"].Data
-	outf := data.Columns["outliers"]
+func (p GeomPolygon) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	trainScales(panel, df, "x:x y:y")
+	return []Fundamental{
+		Fundamental{
+			Geom: p,
+			Data: df,
+		}}
+}
 
-	width := 0.9 // TODO: determine from data
+func (p GeomPolygon) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	return renderPolygons(panel, data, style)
+}
 
-	rects := NewDataFrame("Rects of Boxplot of "+data.Name, data.Pool)
-	rects.N = data.N
-	ymin := NewField(data.N, Float, data.Pool)
-	ymax := NewField(data.N, Float, data.Pool)
-	xmin := NewField(data.N, Float, data.Pool)
-	xmax := NewField(data.N, Float, data.Pool)
+func (p GeomPolygon) Key(k LegendKey, x, y, size float64) []Grob {
+	return keyRect(k, x+0.1*size, y+0.1*size, x+0.9*size, y+0.9*size)
+}
 
-	lines := NewDataFrame("Lines of Boxplot of "+data.Name, data.Pool)
-	lines.N = 6 * data.N
-	xx := NewField(6*data.N, Float, data.Pool)
-	yy := NewField(6*data.N, Float, data.Pool)
-	gg := NewField(6*data.N, Int, data.Pool)
+// -------------------------------------------------------------------------
+// Geom Tile
 
-	outliers := NewDataFrame("Outliers of Boxplot of "+data.Name, data.Pool)
-	ox := NewField(0, Float, data.Pool)
-	oy := NewField(0, Float, data.Pool)
+// GeomTile draws rectangles centered at (x,y), e.g. the cells of a heatmap.
+// Unless mapped the width and height of the tiles are the resolution of
+// the x and y values so that tiles on a regular grid touch each other.
+type GeomTile struct {
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+}
 
-	// Count how many bars are draw at each x value.
-	barsAt := make(map[float64]float64)
-	drawnAt := make(map[float64]float64)
-	for i := 0; i < data.N; i++ {
-		barsAt[x[i]]++
-	}
+var _ Geom = GeomTile{}
+var _ KeyGeom = GeomTile{}
 
-	for i := 0; i < data.N; i++ {
-		i = int(i)
+func (t GeomTile) Name() string          { return "GeomTile" }
+func (t GeomTile) NeededSlots() []string { return []string{"x", "y"} }
+func (t GeomTile) OptionalSlots() []string {
+	return []string{"width", "height", "color", "fill", "linetype", "alpha", "size"}
+}
 
-		xc := x[i]
-		wh := width / 2
+func (t GeomTile) Aes(plot *Plot) AesMapping {
+	return MergeStyles(t.Style, plot.Theme.TileStyle, DefaultTheme.TileStyle)
+}
 
-		if b.Position == PosDodge {
-			total := barsAt[xc]
-			drawn := drawnAt[xc]
-			drawnAt[xc]++
-			wh /= total
+func (t GeomTile) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	pool := df.Pool
+	xf, yf := df.Columns["x"], df.Columns["y"]
+	if !df.Has("width") {
+		df.Columns["width"] = NewField(0, Float, pool).Const(xf.Resolution(), df.N)
+	}
+	if !df.Has("height") {
+		df.Columns["height"] = NewField(0, Float, pool).Const(yf.Resolution(), df.N)
+	}
+	xd, yd := xf.Data, yf.Data
+	wd, hd := df.Columns["width"].Data, df.Columns["height"].Data
+
+	xminf, yminf := NewField(df.N, Float, pool), NewField(df.N, Float, pool)
+	xmaxf, ymaxf := NewField(df.N, Float, pool), NewField(df.N, Float, pool)
+	for i := 0; i < df.N; i++ {
+		wh, hh := wd[i]/2, hd[i]/2
+		xminf.Data[i], xmaxf.Data[i] = xd[i]-wh, xd[i]+wh
+		yminf.Data[i], ymaxf.Data[i] = yd[i]-hh, yd[i]+hh
+	}
+
+	df.Columns["xmin"] = xminf
+	df.Columns["ymin"] = yminf
+	df.Columns["xmax"] = xmaxf
+	df.Columns["ymax"] = ymaxf
+	df.Delete("width")
+	df.Delete("height")
+	df.Delete("x")
+	df.Delete("y")
+
+	trainScales(panel, df, "x:xmin,xmax y:ymin,ymax")
+
+	return []Fundamental{
+		Fundamental{
+			Geom: GeomRect{
+				Style: t.Aes(panel.Plot),
+			},
+			Data: df,
+		}}
+}
+
+func (t GeomTile) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	// Tiles are constructed as rects; this is just in case data
+	// already has the xmin, xmax, ymin and ymax slots.
+	return GeomRect{Style: t.Style}.Render(panel, data, style)
+}
+
+func (t GeomTile) Key(k LegendKey, x, y, size float64) []Grob {
+	return keyRect(k, x, y, x+size, y+size)
+}
+
+// -------------------------------------------------------------------------
+// Geom Raster
+
+// GeomRaster is a fast version of GeomTile for data on a regular grid:
+// All tiles are drawn as one image instead of individual rectangles.
+// Data which is not on a regular grid or has a discrete x or y is drawn
+// as tiles.
+type GeomRaster struct {
+	Style AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomRaster{}
+var _ KeyGeom = GeomRaster{}
+
+func (r GeomRaster) Name() string            { return "GeomRaster" }
+func (r GeomRaster) NeededSlots() []string   { return []string{"x", "y"} }
+func (r GeomRaster) OptionalSlots() []string { return []string{"fill", "alpha"} }
+
+func (r GeomRaster) Aes(plot *Plot) AesMapping {
+	return MergeStyles(r.Style, plot.Theme.TileStyle, DefaultTheme.TileStyle)
+}
+
+// maxRasterCells limits the size of the image drawn by GeomRaster.
+const maxRasterCells = 4096 * 4096
+
+// rasterCells returns the number of cells needed to display the values
+// of f on a regular grid with the resolution of f as spacing. Zero is
+// returned if the values of f do not lie on such a grid.
+func rasterCells(f Field) int {
+	if len(f.Data) == 0 || f.Type == String {
+		return 0
+	}
+	res := f.Resolution()
+	min, max, _, _ := f.MinMax()
+	for _, x := range f.Data {
+		k := (x - min) / res
+		if math.Abs(k-math.Floor(k+0.5)) > 1e-6 {
+			return 0
+		}
+	}
+	return int(math.Floor((max-min)/res+0.5)) + 1
+}
+
+func (r GeomRaster) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	xf, yf := df.Columns["x"], df.Columns["y"]
+	nx, ny := rasterCells(xf), rasterCells(yf)
+	if nx == 0 || ny == 0 || nx*ny > maxRasterCells {
+		return GeomTile{Style: r.Style}.Construct(df, panel)
+	}
+
+	dx, dy := xf.Resolution(), yf.Resolution()
+	xmin, xmax, _, _ := xf.MinMax()
+	ymin, ymax, _, _ := yf.MinMax()
+	panel.Scales["x"].TrainByValue(xmin-dx/2, xmax+dx/2)
+	panel.Scales["y"].TrainByValue(ymin-dy/2, ymax+dy/2)
+
+	return []Fundamental{
+		Fundamental{
+			Geom: r,
+			Data: df,
+		}}
+}
+
+func (r GeomRaster) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	xf, yf := data.Columns["x"], data.Columns["y"]
+	nx, ny := rasterCells(xf), rasterCells(yf)
+	dx, dy := xf.Resolution(), yf.Resolution()
+	xmin, xmax, _, _ := xf.MinMax()
+	ymin, ymax, _, _ := yf.MinMax()
+
+	fillFunc := makeColorFunc("fill", data, panel, style)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+
+	// Cells without data stay transparent. Image rows run top down.
+	img := image.NewNRGBA(image.Rect(0, 0, nx, ny))
+	for i := 0; i < data.N; i++ {
+		c := int(math.Floor((xf.Data[i]-xmin)/dx + 0.5))
+		r := ny - 1 - int(math.Floor((yf.Data[i]-ymin)/dy+0.5))
+		img.Set(c, r, SetAlpha(fillFunc(i), alphaFunc(i)))
+	}
+
+	xs, ys := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+	return []Grob{
+		GrobRaster{
+			xmin:  xs(xmin - dx/2),
+			ymin:  ys(ymin - dy/2),
+			xmax:  xs(xmax + dx/2),
+			ymax:  ys(ymax + dy/2),
+			image: img,
+		}}
+}
+
+func (r GeomRaster) Key(k LegendKey, x, y, size float64) []Grob {
+	return []Grob{
+		GrobRect{
+			xmin: x, ymin: y, xmax: x + size, ymax: y + size,
+			fill: SetAlpha(k.Color("fill"), k.Float("alpha", 0, 1)),
+		}}
+}
+
+// -------------------------------------------------------------------------
+// Interval Geoms: Errorbar, ErrorbarH, Linerange, Pointrange and Crossbar
+
+// dodgeIntervals is a helper for the construction of interval geoms:
+// Unless mapped, a field ext (width or height) of frac times the resolution
+// of the position field pos (x or y) is added to df. With PosDodge the
+// intervals sharing the same pos are placed side by side: pos is replaced
+// by the dodged centers and ext by the dodged extents.
+func dodgeIntervals(df *DataFrame, pos, ext string, frac float64, position PositionAdjust) {
+	pf := df.Columns[pos]
+	if frac <= 0 {
+		frac = 0.9
+	}
+	if !df.Has(ext) {
+		df.Columns[ext] = NewField(0, Float, df.Pool).Const(frac*pf.Resolution(), df.N)
+	}
+	if position != PosDodge {
+		return
+	}
+
+	// Same as in GeomBoxplot.
+	center, extent := NewField(df.N, Float, df.Pool), NewField(df.N, Float, df.Pool)
+	ed := df.Columns[ext].Data
+	total := make(map[float64]float64)
+	drawn := make(map[float64]float64)
+	for _, p := range pf.Data {
+		total[p]++
+	}
+	for i, p := range pf.Data {
+		n, d := total[p], drawn[p]
+		drawn[p]++
+		wh := ed[i] / 2 / n
+		center.Data[i] = p + (2*d-(n-1))*wh
+		extent.Data[i] = 2 * wh
+	}
+	df.Columns[pos] = center
+	df.Columns[ext] = extent
+}
+
+// trainIntervals trains the scale of pos (x or y) on the extent ext of
+// the intervals centered at pos.
+func trainIntervals(panel *Panel, df *DataFrame, pos, ext string) {
+	pd, ed := df.Columns[pos].Data, df.Columns[ext].Data
+	lo, hi := NewField(df.N, Float, df.Pool), NewField(df.N, Float, df.Pool)
+	for i := range pd {
+		lo.Data[i], hi.Data[i] = pd[i]-ed[i]/2, pd[i]+ed[i]/2
+	}
+	if scale, ok := panel.Scales[pos]; ok {
+		scale.Train(lo)
+		scale.Train(hi)
+	}
+}
+
+// renderIntervalLines produces the n line segments for each row i of
+// data returned by seg(i, j), j=0..n-1, in data coordinates. The line
+// style is taken from the color, size, linetype and alpha of row i.
+func renderIntervalLines(panel *Panel, data *DataFrame, style AesMapping, n int,
+	seg func(i, j int) (x0, y0, x1, y1 float64)) []Grob {
+
+	xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+	colFunc := makeColorFunc("color", data, panel, style)
+	sizeFunc := makePosFunc("size", data, panel, style, 0, 1)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+	typeFunc := makeStyleFunc("linetype", data, panel, style)
+
+	grobs := make([]Grob, 0, n*data.N)
+	for i := 0; i < data.N; i++ {
+		color := SetAlpha(colFunc(i), alphaFunc(i))
+		size, lt := sizeFunc(i), LineType(typeFunc(i))
+		for j := 0; j < n; j++ {
+			x0, y0, x1, y1 := seg(i, j)
+			grobs = append(grobs, GrobLine{
+				x0: xf(x0), y0: yf(y0), x1: xf(x1), y1: yf(y1),
+				size: size, linetype: lt, color: color,
+			})
+		}
+	}
+	return grobs
+}
+
+// keyLine returns the line from (x0,y0) to (x1,y1) in the style of the
+// legend key k.
+func keyLine(k LegendKey, x0, y0, x1, y1 float64) GrobLine {
+	return GrobLine{
+		x0: x0, y0: y0, x1: x1, y1: y1,
+		size:     k.Float("size", 0, 1),
+		linetype: LineType(k.Int("linetype")),
+		color:    SetAlpha(k.Color("color"), k.Float("alpha", 0, 1)),
+	}
+}
+
+// GeomErrorbar draws vertical intervals from ymin to ymax with whiskers
+// at both ends.
+type GeomErrorbar struct {
+	// Width of the whiskers as a fraction of the resolution of x.
+	// Zero means 0.9. Ignored if width is mapped.
+	Width float64
+
+	Position PositionAdjust
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomErrorbar{}
+var _ KeyGeom = GeomErrorbar{}
+
+func (e GeomErrorbar) Name() string          { return "GeomErrorbar" }
+func (e GeomErrorbar) NeededSlots() []string { return []string{"x", "ymin", "ymax"} }
+func (e GeomErrorbar) OptionalSlots() []string {
+	return []string{"width", "color", "size", "linetype", "alpha"}
+}
+
+func (e GeomErrorbar) Aes(plot *Plot) AesMapping {
+	return MergeStyles(e.Style, plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (e GeomErrorbar) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	dodgeIntervals(df, "x", "width", e.Width, e.Position)
+	trainIntervals(panel, df, "x", "width")
+	trainScales(panel, df, "y:ymin,ymax")
+	return []Fundamental{
+		Fundamental{
+			Geom: e,
+			Data: df,
+		}}
+}
+
+func (e GeomErrorbar) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x, w := data.Columns["x"].Data, data.Columns["width"].Data
+	ymin, ymax := data.Columns["ymin"].Data, data.Columns["ymax"].Data
+	return renderIntervalLines(panel, data, style, 3,
+		func(i, j int) (float64, float64, float64, float64) {
+			wh := w[i] / 2
+			switch j {
+			case 0:
+				return x[i], ymin[i], x[i], ymax[i]
+			case 1:
+				return x[i] - wh, ymin[i], x[i] + wh, ymin[i]
+			}
+			return x[i] - wh, ymax[i], x[i] + wh, ymax[i]
+		})
+}
+
+func (e GeomErrorbar) Key(k LegendKey, x, y, size float64) []Grob {
+	xc, y0, y1 := x+size/2, y+0.15*size, y+0.85*size
+	return []Grob{
+		keyLine(k, xc, y0, xc, y1),
+		keyLine(k, x+0.25*size, y0, x+0.75*size, y0),
+		keyLine(k, x+0.25*size, y1, x+0.75*size, y1),
+	}
+}
+
+// GeomErrorbarH draws horizontal intervals from xmin to xmax with whiskers
+// at both ends.
+type GeomErrorbarH struct {
+	// Height of the whiskers as a fraction of the resolution of y.
+	// Zero means 0.9. Ignored if height is mapped.
+	Height float64
+
+	Position PositionAdjust
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomErrorbarH{}
+var _ KeyGeom = GeomErrorbarH{}
+
+func (e GeomErrorbarH) Name() string          { return "GeomErrorbarH" }
+func (e GeomErrorbarH) NeededSlots() []string { return []string{"y", "xmin", "xmax"} }
+func (e GeomErrorbarH) OptionalSlots() []string {
+	return []string{"height", "color", "size", "linetype", "alpha"}
+}
+
+func (e GeomErrorbarH) Aes(plot *Plot) AesMapping {
+	return MergeStyles(e.Style, plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (e GeomErrorbarH) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	dodgeIntervals(df, "y", "height", e.Height, e.Position)
+	trainIntervals(panel, df, "y", "height")
+	trainScales(panel, df, "x:xmin,xmax")
+	return []Fundamental{
+		Fundamental{
+			Geom: e,
+			Data: df,
+		}}
+}
+
+func (e GeomErrorbarH) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	y, h := data.Columns["y"].Data, data.Columns["height"].Data
+	xmin, xmax := data.Columns["xmin"].Data, data.Columns["xmax"].Data
+	return renderIntervalLines(panel, data, style, 3,
+		func(i, j int) (float64, float64, float64, float64) {
+			hh := h[i] / 2
+			switch j {
+			case 0:
+				return xmin[i], y[i], xmax[i], y[i]
+			case 1:
+				return xmin[i], y[i] - hh, xmin[i], y[i] + hh
+			}
+			return xmax[i], y[i] - hh, xmax[i], y[i] + hh
+		})
+}
+
+func (e GeomErrorbarH) Key(k LegendKey, x, y, size float64) []Grob {
+	yc, x0, x1 := y+size/2, x+0.15*size, x+0.85*size
+	return []Grob{
+		keyLine(k, x0, yc, x1, yc),
+		keyLine(k, x0, y+0.25*size, x0, y+0.75*size),
+		keyLine(k, x1, y+0.25*size, x1, y+0.75*size),
+	}
+}
+
+// GeomLinerange draws vertical lines from ymin to ymax.
+type GeomLinerange struct {
+	// Width used to dodge the lines as a fraction of the resolution
+	// of x. Zero means 0.9. Ignored if width is mapped.
+	Width float64
+
+	Position PositionAdjust
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomLinerange{}
+var _ KeyGeom = GeomLinerange{}
+
+func (l GeomLinerange) Name() string          { return "GeomLinerange" }
+func (l GeomLinerange) NeededSlots() []string { return []string{"x", "ymin", "ymax"} }
+func (l GeomLinerange) OptionalSlots() []string {
+	return []string{"width", "color", "size", "linetype", "alpha"}
+}
+
+func (l GeomLinerange) Aes(plot *Plot) AesMapping {
+	return MergeStyles(l.Style, plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (l GeomLinerange) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	dodgeIntervals(df, "x", "width", l.Width, l.Position)
+	trainScales(panel, df, "x:x y:ymin,ymax")
+	return []Fundamental{
+		Fundamental{
+			Geom: l,
+			Data: df,
+		}}
+}
+
+func (l GeomLinerange) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x := data.Columns["x"].Data
+	ymin, ymax := data.Columns["ymin"].Data, data.Columns["ymax"].Data
+	return renderIntervalLines(panel, data, style, 1,
+		func(i, j int) (float64, float64, float64, float64) {
+			return x[i], ymin[i], x[i], ymax[i]
+		})
+}
+
+func (l GeomLinerange) Key(k LegendKey, x, y, size float64) []Grob {
+	xc := x + size/2
+	return []Grob{keyLine(k, xc, y+0.1*size, xc, y+0.9*size)}
+}
+
+// GeomPointrange draws vertical lines from ymin to ymax with a point at y.
+// The size aesthetic determines the size of the point; the line is drawn
+// with a third of this size.
+type GeomPointrange struct {
+	// Width used to dodge the pointranges as a fraction of the resolution
+	// of x. Zero means 0.9. Ignored if width is mapped.
+	Width float64
+
+	Position PositionAdjust
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomPointrange{}
+var _ KeyGeom = GeomPointrange{}
+
+func (p GeomPointrange) Name() string          { return "GeomPointrange" }
+func (p GeomPointrange) NeededSlots() []string { return []string{"x", "y", "ymin", "ymax"} }
+func (p GeomPointrange) OptionalSlots() []string {
+	return []string{"width", "color", "size", "linetype", "shape", "alpha"}
+}
+
+func (p GeomPointrange) Aes(plot *Plot) AesMapping {
+	return MergeStyles(p.Style, plot.Theme.PointStyle, DefaultTheme.PointStyle,
+		plot.Theme.LineStyle, DefaultTheme.LineStyle)
+}
+
+func (p GeomPointrange) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	dodgeIntervals(df, "x", "width", p.Width, p.Position)
+	trainScales(panel, df, "x:x y:y,ymin,ymax")
+	return []Fundamental{
+		Fundamental{
+			Geom: p,
+			Data: df,
+		}}
+}
+
+func (p GeomPointrange) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x, y := data.Columns["x"].Data, data.Columns["y"].Data
+	ymin, ymax := data.Columns["ymin"].Data, data.Columns["ymax"].Data
+	xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+
+	colFunc := makeColorFunc("color", data, panel, style)
+	sizeFunc := makePosFunc("size", data, panel, style, 1, 10)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+	typeFunc := makeStyleFunc("linetype", data, panel, style)
+	shapeFunc := makeStyleFunc("shape", data, panel, style)
+
+	grobs := make([]Grob, 0, 2*data.N)
+	for i := 0; i < data.N; i++ {
+		color := SetAlpha(colFunc(i), alphaFunc(i))
+		size := sizeFunc(i)
+		grobs = append(grobs,
+			GrobLine{
+				x0: xf(x[i]), y0: yf(ymin[i]),
+				x1: xf(x[i]), y1: yf(ymax[i]),
+				size:     size / 3,
+				linetype: LineType(typeFunc(i)),
+				color:    color,
+			},
+			GrobPoint{
+				x: xf(x[i]), y: yf(y[i]),
+				size:  size,
+				shape: PointShape(shapeFunc(i)),
+				color: color,
+			})
+	}
+	return grobs
+}
+
+func (p GeomPointrange) Key(k LegendKey, x, y, size float64) []Grob {
+	xc := x + size/2
+	ps := k.Float("size", 1, 10)
+	color := SetAlpha(k.Color("color"), k.Float("alpha", 0, 1))
+	return []Grob{
+		GrobLine{
+			x0: xc, y0: y + 0.1*size, x1: xc, y1: y + 0.9*size,
+			size:     ps / 3,
+			linetype: LineType(k.Int("linetype")),
+			color:    color,
+		},
+		GrobPoint{
+			x: xc, y: y + size/2,
+			size:  ps,
+			shape: PointShape(k.Int("shape")),
+			color: color,
+		},
+	}
+}
+
+// GeomCrossbar draws boxes from ymin to ymax with a horizontal line at y.
+// The middle line is drawn twice as thick as the box outline.
+type GeomCrossbar struct {
+	// Width of the boxes as a fraction of the resolution of x.
+	// Zero means 0.9. Ignored if width is mapped.
+	Width float64
+
+	Position PositionAdjust
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+}
+
+var _ Geom = GeomCrossbar{}
+var _ KeyGeom = GeomCrossbar{}
+
+// crossbarStyle provides an unfilled box, a fully transparent fill is
+// not drawn at all.
+var crossbarStyle = AesMapping{
+	"fill":  "#ffffff00",
+	"color": "gray20",
+	"size":  "1",
+}
+
+func (c GeomCrossbar) Name() string          { return "GeomCrossbar" }
+func (c GeomCrossbar) NeededSlots() []string { return []string{"x", "y", "ymin", "ymax"} }
+func (c GeomCrossbar) OptionalSlots() []string {
+	return []string{"width", "color", "fill", "size", "linetype", "alpha"}
+}
+
+func (c GeomCrossbar) Aes(plot *Plot) AesMapping {
+	return MergeStyles(c.Style, crossbarStyle, plot.Theme.RectStyle, DefaultTheme.RectStyle)
+}
+
+func (c GeomCrossbar) Construct(df *DataFrame, panel *Panel) []Fundamental {
+	dodgeIntervals(df, "x", "width", c.Width, c.Position)
+	trainIntervals(panel, df, "x", "width")
+	trainScales(panel, df, "y:y,ymin,ymax")
+	return []Fundamental{
+		Fundamental{
+			Geom: c,
+			Data: df,
+		}}
+}
+
+func (c GeomCrossbar) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	x, y, w := data.Columns["x"].Data, data.Columns["y"].Data, data.Columns["width"].Data
+	ymin, ymax := data.Columns["ymin"].Data, data.Columns["ymax"].Data
+	xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+
+	colFunc := makeColorFunc("color", data, panel, style)
+	fillFunc := makeColorFunc("fill", data, panel, style)
+	sizeFunc := makePosFunc("size", data, panel, style, 0, 1)
+	alphaFunc := makePosFunc("alpha", data, panel, style, 0, 1)
+	typeFunc := makeStyleFunc("linetype", data, panel, style)
+
+	grobs := make([]Grob, 0, 3*data.N)
+	for i := 0; i < data.N; i++ {
+		x0, x1 := xf(x[i]-w[i]/2), xf(x[i]+w[i]/2)
+		y0, y1, ym := yf(ymin[i]), yf(ymax[i]), yf(y[i])
+		alpha := alphaFunc(i)
+		color := SetAlpha(colFunc(i), alpha)
+		size, lt := sizeFunc(i), LineType(typeFunc(i))
+		grobs = append(grobs, crossbarGrobs(x0, y0, x1, y1, ym,
+			fillFunc(i), color, alpha, size, lt)...)
+	}
+	return grobs
+}
+
+func (c GeomCrossbar) Key(k LegendKey, x, y, size float64) []Grob {
+	alpha := k.Float("alpha", 0, 1)
+	return crossbarGrobs(x+0.2*size, y+0.2*size, x+0.8*size, y+0.8*size, y+0.5*size,
+		k.Color("fill"), SetAlpha(k.Color("color"), alpha), alpha,
+		k.Float("size", 0, 1), LineType(k.Int("linetype")))
+}
+
+// crossbarGrobs returns the fill, outline and middle line of a crossbar
+// in the rectangle (x0,y0)-(x1,y1) with the middle line at ym.
+func crossbarGrobs(x0, y0, x1, y1, ym float64, fill, color color.Color,
+	alpha, size float64, lt LineType) []Grob {
+
+	grobs := []Grob{}
+	if _, _, _, a := fill.RGBA(); a != 0 {
+		grobs = append(grobs, GrobRect{
+			xmin: x0, ymin: y0, xmax: x1, ymax: y1,
+			fill: SetAlpha(fill, alpha),
+		})
+	}
+	if lt == BlankLine {
+		return grobs
+	}
+	points := []struct{ x, y float64 }{
+		{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0},
+	}
+	grobs = append(grobs,
+		GrobPath{points: points, linetype: lt, color: color, size: size},
+		GrobLine{x0: x0, y0: ym, x1: x1, y1: ym, linetype: lt, color: color, size: 2 * size},
+	)
+	return grobs
+}
+
+// -------------------------------------------------------------------------
+// Geom Boxplot
+
+// GeomBoxplot draws the boxplots computed by StatBoxplot.
+type GeomBoxplot struct {
+	Style    AesMapping // The individal fixed, aka non-mapped aesthetics
+	Position PositionAdjust
+
+	// Width of the boxes as a fraction of the resolution of x (or y for
+	// horizontal boxplots). Zero means 0.9.
+	Width float64
+
+	// VarWidth makes the width of the boxes proportional to the square
+	// root of the number of observations. Dodged boxes are centered in
+	// slots of the full width.
+	VarWidth bool
+
+	// Notch draws boxes notched around the median. NotchWidth is the
+	// width of the box at the median relative to the full width.
+	// Zero means 0.5.
+	Notch      bool
+	NotchWidth float64
+
+	// OutlierStyle contains the shape, color, size and alpha of the
+	// outliers.
+	OutlierStyle AesMapping
+
+	// Horizontal draws boxplots computed by a horizontal StatBoxplot.
+	// A layer with a StatBoxplot is horizontal if either its stat or
+	// its geom is.
+	Horizontal bool
+}
+
+var _ Geom = GeomBoxplot{}
+var _ KeyGeom = GeomBoxplot{}
+
+// outlierStyle is the default style of the outliers of a boxplot.
+var outlierStyle = AesMapping{
+	"color": "#aa0000",
+	"shape": "star",
+}
+
+func (b GeomBoxplot) Name() string { return "GeomBoxplot" }
+func (b GeomBoxplot) NeededSlots() []string {
+	pos := "x"
+	if b.Horizontal {
+		pos = "y"
+	}
+	return []string{pos, "low", "q1", "mid", "q3", "high"}
+}
+func (b GeomBoxplot) OptionalSlots() []string {
+	return []string{"fill", "count", "outliers", "notchlow", "notchhigh"}
+}
+
+func (b GeomBoxplot) Aes(plot *Plot) AesMapping {
+	return MergeStyles(b.Style, plot.Theme.RectStyle, DefaultTheme.RectStyle)
+}
+
+func (b GeomBoxplot) Construct(data *DataFrame, panel *Panel) []Fundamental {
+	// All computations are done with the boxes extending along y at
+	// position x; horizontal boxplots are flipped at the end.
+	pos := "x"
+	if b.Horizontal {
+		pos = "y"
+	}
+	low, high := data.Columns["low"].Data, data.Columns["high"].Data
+	q1, q3 := data.Columns["q1"].Data, data.Columns["q3"].Data
+	x, mid := data.Columns[pos].Data, data.Columns["mid"].Data
+	notch := b.Notch && data.Has("notchlow") && data.Has("notchhigh")
+
+	width := b.Width
+	if width <= 0 {
+		width = 0.9
+	}
+	width *= data.Columns[pos].Resolution()
+	maxCount := 0.0
+	if b.VarWidth && data.Has("count") {
+		_, maxCount, _, _ = data.Columns["count"].MinMax()
+	}
+	notchWidth := b.NotchWidth
+	if notchWidth <= 0 {
+		notchWidth = 0.5
+	}
+
+	rects := NewDataFrame("Rects of Boxplot of "+data.Name, data.Pool)
+	ymin := NewField(0, Float, data.Pool)
+	ymax := NewField(0, Float, data.Pool)
+	xmin := NewField(0, Float, data.Pool)
+	xmax := NewField(0, Float, data.Pool)
+	rfill := NewField(0, Float, data.Pool)
+
+	polygons := NewDataFrame("Notched boxes of Boxplot of "+data.Name, data.Pool)
+	px := NewField(0, Float, data.Pool)
+	py := NewField(0, Float, data.Pool)
+	pg := NewField(0, Int, data.Pool)
+	pfill := NewField(0, Float, data.Pool)
+
+	lines := NewDataFrame("Lines of Boxplot of "+data.Name, data.Pool)
+	lines.N = 6 * data.N
+	xx := NewField(6*data.N, Float, data.Pool)
+	yy := NewField(6*data.N, Float, data.Pool)
+	gg := NewField(6*data.N, Int, data.Pool)
+
+	outliers := NewDataFrame("Outliers of Boxplot of "+data.Name, data.Pool)
+	ox := NewField(0, Float, data.Pool)
+	oy := NewField(0, Float, data.Pool)
+
+	// Count how many bars are draw at each x value.
+	barsAt := make(map[float64]float64)
+	drawnAt := make(map[float64]float64)
+	for i := 0; i < data.N; i++ {
+		barsAt[x[i]]++
+	}
+
+	for i := 0; i < data.N; i++ {
+		xc := x[i]
+		wh := width / 2
+		if b.Position == PosDodge {
+			total := barsAt[xc]
+			drawn := drawnAt[xc]
+			drawnAt[xc]++
+			wh /= total
 			xc += (2*drawn - (total - 1)) * wh
 		}
 
-		xmin.Data[i], xmax.Data[i] = xc-wh, xc+wh
+		// Variable widths shrink the box inside its (dodged) slot.
+		if maxCount > 0 {
+			wh *= math.Sqrt(data.Columns["count"].Data[i] / maxCount)
+		}
 
+		// Indentation of the notch, zero if unnotched.
+		indent := 0.0
 		y1, y3 := q1[i], q3[i]
-		ymin.Data[i], ymax.Data[i] = y1, y3
+		if notch {
+			indent = wh * (1 - notchWidth)
+			nl, nh := data.Columns["notchlow"].Data[i], data.Columns["notchhigh"].Data[i]
+			outline := [][2]float64{
+				{xc - wh, y1}, {xc + wh, y1}, {xc + wh, nl},
+				{xc + wh - indent, mid[i]}, {xc + wh, nh}, {xc + wh, y3},
+				{xc - wh, y3}, {xc - wh, nh}, {xc - wh + indent, mid[i]},
+				{xc - wh, nl},
+			}
+			for _, pt := range outline {
+				px.Data = append(px.Data, pt[0])
+				py.Data = append(py.Data, pt[1])
+				pg.Data = append(pg.Data, float64(i))
+				if data.Has("fill") {
+					pfill.Data = append(pfill.Data, data.Columns["fill"].Data[i])
+				}
+			}
+		} else {
+			xmin.Data = append(xmin.Data, xc-wh)
+			xmax.Data = append(xmax.Data, xc+wh)
+			ymin.Data = append(ymin.Data, y1)
+			ymax.Data = append(ymax.Data, y3)
+			if data.Has("fill") {
+				rfill.Data = append(rfill.Data, data.Columns["fill"].Data[i])
+			}
+		}
 
 		yl, yh := low[i], high[i]
 		xx.Data[6*i], xx.Data[6*i+1] = xc, xc
@@ -615,7 +1911,7 @@ func (b GeomBoxplot) Construct(data *DataFrame, panel *Panel) []Fundamental {
 		yy.Data[6*i], yy.Data[6*i+1] = yl, y1
 		yy.Data[6*i+2], yy.Data[6*i+3] = y3, yh
 
-		xx.Data[6*i+4], xx.Data[6*i+5] = xc-wh, xc+wh
+		xx.Data[6*i+4], xx.Data[6*i+5] = xc-wh+indent, xc+wh-indent
 		yy.Data[6*i+4], yy.Data[6*i+5] = mid[i], mid[i]
 
 		group := float64(3 * i)
@@ -623,42 +1919,69 @@ func (b GeomBoxplot) Construct(data *DataFrame, panel *Panel) []Fundamental {
 		gg.Data[6*i+2], gg.Data[6*i+3] = group+1, group+1
 		gg.Data[6*i+4], gg.Data[6*i+5] = group+2, group+2
 
-		for _, q := range outf.GetVec(i) {
-			ox.Data = append(ox.Data, xc)
-			oy.Data = append(oy.Data, q)
+		if data.Has("outliers") {
+			for _, q := range data.Columns["outliers"].GetVec(i) {
+				ox.Data = append(ox.Data, xc)
+				oy.Data = append(oy.Data, q)
+			}
 		}
 	}
 
+	// Flip horizontal boxplots.
+	xn, yn := "x", "y"
+	if b.Horizontal {
+		xn, yn = "y", "x"
+		xmin, ymin = ymin, xmin
+		xmax, ymax = ymax, xmax
+	}
+
+	rects.N = len(xmin.Data)
 	rects.Columns["xmin"] = xmin
 	rects.Columns["xmax"] = xmax
 	rects.Columns["ymin"] = ymin
 	rects.Columns["ymax"] = ymax
+
+	polygons.N = len(px.Data)
+	polygons.Columns[xn] = px
+	polygons.Columns[yn] = py
+	polygons.Columns["group"] = pg
+
 	if data.Has("fill") {
-		rects.Columns["fill"] = data.Columns["fill"]
+		fill := data.Columns["fill"]
+		rfill.Type, rfill.Pool, rfill.Origin = fill.Type, fill.Pool, fill.Origin
+		pfill.Type, pfill.Pool, pfill.Origin = fill.Type, fill.Pool, fill.Origin
+		rects.Columns["fill"] = rfill
+		polygons.Columns["fill"] = pfill
 	}
 
-	lines.Columns["x"] = xx
-	lines.Columns["y"] = yy
+	lines.Columns[xn] = xx
+	lines.Columns[yn] = yy
 	lines.Columns["group"] = gg
 
-	outliers.Columns["x"] = ox
-	outliers.Columns["y"] = oy
+	outliers.Columns[xn] = ox
+	outliers.Columns[yn] = oy
 	outliers.N = len(ox.Data)
 
-	trainScales(panel, rects, "x:xmin,xmax")
-	trainScales(panel, lines, "y:yy") // Bug: should train on real ymin/max
+	trainScales(panel, rects, "x:xmin,xmax y:ymin,ymax")
+	trainScales(panel, polygons, "x:x y:y")
+	trainScales(panel, lines, "x:x y:y")
+	trainScales(panel, outliers, "x:x y:y")
 
-	outlierStyle := b.Style.Copy()
-	outlierStyle["color"] = "#aa0000"
-	outlierStyle["shape"] = "star"
+	box := Fundamental{
+		Geom: GeomRect{
+			Style: b.Style.Copy(),
+		},
+		Data: rects,
+	}
+	if notch {
+		box = Fundamental{
+			Geom: b,
+			Data: polygons,
+		}
+	}
 
 	return []Fundamental{
-		Fundamental{
-			Geom: GeomRect{
-				Style: b.Style.Copy(),
-			},
-			Data: rects,
-		},
+		box,
 		Fundamental{
 			Geom: GeomLine{
 				Style: b.Style.Copy(),
@@ -667,13 +1990,85 @@ func (b GeomBoxplot) Construct(data *DataFrame, panel *Panel) []Fundamental {
 		},
 		Fundamental{
 			Geom: GeomPoint{
-				Style: outlierStyle,
+				Style: MergeStyles(b.OutlierStyle, outlierStyle, b.Style),
 			},
 			Data: outliers,
 		},
 	}
 }
 
+// Render draws the notched boxes constructed by Construct. All other
+// parts of the boxplot are drawn by rects, lines and points.
 func (b GeomBoxplot) Render(panel *Panel, data *DataFrame, style AesMapping) []Grob {
-	panic("Should not be called...")
+	return renderPolygons(panel, data, style)
+}
+
+// renderPolygons draws the filled polygons through the points (x,y) of
+// data, one for each group, and their outline unless the linetype is blank.
+func renderPolygons(panel *Panel, data *DataFrame, style AesMapping) []Grob {
+	xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+	partitions := []*DataFrame{data}
+	if data.Has("group") {
+		partitions = Partition(data, "group", Levels(data, "group").Elements())
+	}
+
+	grobs := make([]Grob, 0)
+	for _, part := range partitions {
+		if part.N == 0 {
+			continue
+		}
+		x, y := part.Columns["x"].Data, part.Columns["y"].Data
+		colFunc := makeColorFunc("color", part, panel, style)
+		fillFunc := makeColorFunc("fill", part, panel, style)
+		linetypeFunc := makeStyleFunc("linetype", part, panel, style)
+		alphaFunc := makePosFunc("alpha", part, panel, style, 0, 1)
+		sizeFunc := makePosFunc("size", part, panel, style, 0, 1)
+
+		points := make([]struct{ x, y float64 }, part.N+1)
+		for i := 0; i < part.N; i++ {
+			points[i].x, points[i].y = xf(x[i]), yf(y[i])
+		}
+		points[part.N] = points[0]
+
+		alpha := alphaFunc(0)
+		grobs = append(grobs, GrobPolygon{
+			points: points[:part.N],
+			fill:   SetAlpha(fillFunc(0), alpha),
+		})
+		if lt := LineType(linetypeFunc(0)); lt != BlankLine {
+			grobs = append(grobs, GrobPath{
+				points:   points,
+				linetype: lt,
+				color:    SetAlpha(colFunc(0), alpha),
+				size:     sizeFunc(0),
+			})
+		}
+	}
+	return grobs
+}
+
+func (b GeomBoxplot) Key(k LegendKey, x, y, size float64) []Grob {
+	// The key is set up for a vertical boxplot in (u,v) ranging from
+	// 0 to 1 and flipped for horizontal boxplots.
+	at := func(u, v float64) (float64, float64) {
+		if b.Horizontal {
+			u, v = v, u
+		}
+		return x + u*size, y + v*size
+	}
+	x0, y0 := at(0.2, 0.3)
+	x1, y1 := at(0.8, 0.7)
+	grobs := keyRect(k, x0, y0, x1, y1)
+
+	// Whiskers and median are drawn as lines like in Construct.
+	lk := k
+	lk.Style = MergeStyles(b.Style, DefaultTheme.LineStyle)
+	for _, seg := range [][4]float64{
+		{0.5, 0.1, 0.5, 0.3}, {0.5, 0.7, 0.5, 0.9}, {0.2, 0.5, 0.8, 0.5},
+	} {
+		x0, y0 := at(seg[0], seg[1])
+		x1, y1 := at(seg[2], seg[3])
+		grobs = append(grobs, keyLine(lk, x0, y0, x1, y1))
+	}
+	return grobs
 }
@@ -2,8 +2,10 @@ package plot
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"math"
+	"math/rand"
 	"strings"
 
 	"gonum.org/v1/plot/vg"
@@ -27,6 +29,9 @@ type GrobPoint struct {
 var _ Grob = GrobPoint{}
 
 func (point GrobPoint) Draw(vp Viewport) {
+	if vp.Clip && !insideUnit(point.x, point.y) {
+		return
+	}
 	vp.Canvas.Push()
 	vp.Canvas.SetColor(point.color)
 	vp.Canvas.SetLineWidth(1)
@@ -124,6 +129,8 @@ type GrobLine struct {
 	size           float64
 	linetype       LineType
 	color          color.Color
+	cap            LineCap
+	arrow          *Arrow
 }
 
 var _ Grob = GrobLine{}
@@ -140,17 +147,24 @@ var dashLength = [][]vg.Length{
 }
 
 func (line GrobLine) Draw(vp Viewport) {
+	if vp.Clip {
+		x0, y0, x1, y1, ok := clipSegment(line.x0, line.y0, line.x1, line.y1)
+		if !ok {
+			return
+		}
+		line.arrow = line.arrow.At(x0 == line.x0 && y0 == line.y0, x1 == line.x1 && y1 == line.y1)
+		line.x0, line.y0, line.x1, line.y1 = x0, y0, x1, y1
+	}
+	vp.Canvas.Push()
 	vp.Canvas.SetColor(line.color)
 	vp.Canvas.SetLineWidth(vg.Points(line.size))
 	vp.Canvas.SetLineDash(dashLength[line.linetype%7], 0)
 	fmt.Println("%%%%%%%%%%%%", line.linetype, line.linetype%7, dashLength[line.linetype%7])
 	x0, y0 := vp.X(line.x0), vp.Y(line.y0)
 	x1, y1 := vp.X(line.x1), vp.Y(line.y1)
-	var p vg.Path
-
-	p.Move(vg.Point{x0, y0})
-	p.Line(vg.Point{x1, y1})
-	vp.Canvas.Stroke(p)
+	points := []vg.Point{{X: x0, Y: y0}, {X: x1, Y: y1}}
+	strokePolyline(vp.Canvas, points, vg.Points(line.size), line.linetype, line.cap, DefaultJoin, line.arrow)
+	vp.Canvas.Pop()
 }
 
 func (line GrobLine) String() string {
@@ -168,24 +182,39 @@ type GrobPath struct {
 	size     float64
 	linetype LineType
 	color    color.Color
+	cap      LineCap
+	join     LineJoin
+	arrow    *Arrow
 }
 
 var _ Grob = GrobPath{}
 
 func (path GrobPath) Draw(vp Viewport) {
+	if vp.Clip {
+		// Draw the visible pieces unclipped.
+		unclippedVP := vp
+		unclippedVP.Clip = false
+		n := len(path.points)
+		pieces := clipPath(path.points)
+		for i, piece := range pieces {
+			p := path
+			p.points = piece
+			first := i == 0 && piece[0] == path.points[0]
+			last := i == len(pieces)-1 && piece[len(piece)-1] == path.points[n-1]
+			p.arrow = path.arrow.At(first, last)
+			p.Draw(unclippedVP)
+		}
+		return
+	}
 	vp.Canvas.Push()
 	vp.Canvas.SetColor(path.color)
 	vp.Canvas.SetLineWidth(vg.Points(path.size))
 	vp.Canvas.SetLineDash(dashLength[path.linetype], 0)
-	x, y := vp.X(path.points[0].x), vp.Y(path.points[0].y)
-	var p vg.Path
-
-	p.Move(vg.Point{x, y})
-	for i := 1; i < len(path.points); i++ {
-		x, y = vp.X(path.points[i].x), vp.Y(path.points[i].y)
-		p.Line(vg.Point{x, y})
+	points := make([]vg.Point, len(path.points))
+	for i, p := range path.points {
+		points[i] = vg.Point{vp.X(p.x), vp.Y(p.y)}
 	}
-	vp.Canvas.Stroke(p)
+	strokePolyline(vp.Canvas, points, vg.Points(path.size), path.linetype, path.cap, path.join, path.arrow)
 	vp.Canvas.Pop()
 }
 
@@ -209,6 +238,258 @@ func (path GrobPath) String() string {
 		path.size)
 }
 
+// -------------------------------------------------------------------------
+// Line Caps, Joins and Arrows
+
+// LineCap is the shape of the ends of lines and paths.
+type LineCap int
+
+const (
+	ButtCap   LineCap = iota // Squared off at the endpoint.
+	RoundCap                 // Semicircle around the endpoint.
+	SquareCap                // Squared off half the line width beyond the endpoint.
+)
+
+// LineJoin is the shape of the corners of paths.
+type LineJoin int
+
+const (
+	DefaultJoin LineJoin = iota // Whatever the canvas draws.
+	RoundJoin                   // Circular arc around the corner.
+	MiterJoin                   // Sharp corner, beveled if too long.
+	BevelJoin                   // Corner cut off straight.
+)
+
+// miterLimit is the ratio of miter length to line width above which
+// miter joins are drawn as bevel joins.
+const miterLimit = 4
+
+// ArrowEnds selects the ends of a line which get arrowheads.
+type ArrowEnds int
+
+const (
+	ArrowLast ArrowEnds = iota
+	ArrowFirst
+	ArrowBoth
+)
+
+// Arrow describes the arrowheads drawn at the ends of lines and paths.
+type Arrow struct {
+	// Angle between the line and the sides of the head in degrees.
+	// Zero means 30.
+	Angle float64
+
+	// Length of the sides of the head. Zero means 3 mm.
+	Length vg.Length
+
+	// Ends at which arrowheads are drawn.
+	Ends ArrowEnds
+
+	// Closed heads are filled triangles, open heads just two lines.
+	Closed bool
+}
+
+// At returns the arrow for a piece of a longer line which starts at the
+// first and ends at the last end of the line: Heads at ends not contained
+// in the piece are removed. Nil is returned if no head is left.
+func (a *Arrow) At(first, last bool) *Arrow {
+	if a == nil {
+		return nil
+	}
+	f := first && (a.Ends == ArrowFirst || a.Ends == ArrowBoth)
+	l := last && (a.Ends == ArrowLast || a.Ends == ArrowBoth)
+	arrow := *a
+	switch {
+	case f && l:
+		arrow.Ends = ArrowBoth
+	case f:
+		arrow.Ends = ArrowFirst
+	case l:
+		arrow.Ends = ArrowLast
+	default:
+		return nil
+	}
+	return &arrow
+}
+
+func (a *Arrow) first() bool { return a != nil && (a.Ends == ArrowFirst || a.Ends == ArrowBoth) }
+func (a *Arrow) last() bool  { return a != nil && (a.Ends == ArrowLast || a.Ends == ArrowBoth) }
+
+// head returns the two corners of the arrowhead with the tip at tip for
+// a line coming from from.
+func (a *Arrow) head(from, tip vg.Point) (vg.Point, vg.Point) {
+	angle, length := a.Angle, a.Length
+	if angle <= 0 {
+		angle = 30
+	}
+	if length <= 0 {
+		length = 3 * vg.Millimeter
+	}
+	phi := math.Atan2(float64(from.Y-tip.Y), float64(from.X-tip.X))
+	alpha := angle * math.Pi / 180
+	l := float64(length)
+	c1 := vg.Point{
+		X: tip.X + vg.Length(l*math.Cos(phi+alpha)),
+		Y: tip.Y + vg.Length(l*math.Sin(phi+alpha)),
+	}
+	c2 := vg.Point{
+		X: tip.X + vg.Length(l*math.Cos(phi-alpha)),
+		Y: tip.Y + vg.Length(l*math.Sin(phi-alpha)),
+	}
+	return c1, c2
+}
+
+// strokePolyline strokes the path through points (in canvas coordinates)
+// with the current color and dashes of c, line width w and linetype lt.
+// The ends are drawn with cap or arrowheads and the corners with join.
+//
+// The canvases do not support caps and joins directly; they are drawn as
+// additional filled shapes. Only explicitly requested joins are drawn,
+// DefaultJoin leaves the corners to the canvas. Miter and bevel joins
+// require the segments to be stroked individually which restarts the dash
+// pattern at each corner.
+func strokePolyline(c vg.Canvas, points []vg.Point, w vg.Length, lt LineType, cap LineCap, join LineJoin, arrow *Arrow) {
+	n := len(points)
+	if n < 2 {
+		return
+	}
+	pts := make([]vg.Point, n)
+	copy(pts, points)
+	tips := [2]vg.Point{pts[0], pts[n-1]}
+	froms := [2]vg.Point{pts[1], pts[n-2]}
+	heads := [2]bool{arrow.first(), arrow.last()}
+
+	// Closed heads cover the end of the line: Shorten the line so
+	// that it does not poke through the tip.
+	// Square caps extend the line.
+	for e, i := range []int{0, n - 1} {
+		j := 1
+		if e == 1 {
+			j = n - 2
+		}
+		switch {
+		case heads[e] && arrow.Closed:
+			c1, c2 := arrow.head(froms[e], tips[e])
+			base := vg.Point{X: (c1.X + c2.X) / 2, Y: (c1.Y + c2.Y) / 2}
+			if distance(base, tips[e]) < distance(pts[j], tips[e]) {
+				pts[i] = moveTowards(tips[e], pts[j], distance(base, tips[e])/2)
+			}
+		case !heads[e] && cap == SquareCap:
+			pts[i] = moveTowards(pts[i], pts[j], -w/2)
+		}
+	}
+
+	if n > 2 && (join == MiterJoin || join == BevelJoin) {
+		for i := 1; i < n; i++ {
+			var p vg.Path
+			p.Move(pts[i-1])
+			p.Line(pts[i])
+			c.Stroke(p)
+		}
+	} else {
+		var p vg.Path
+		p.Move(pts[0])
+		for _, pt := range pts[1:] {
+			p.Line(pt)
+		}
+		c.Stroke(p)
+	}
+
+	// Joins.
+	for i := 1; i < n-1; i++ {
+		switch join {
+		case RoundJoin:
+			if lt == SolidLine {
+				c.Fill(circle(pts[i], w/2))
+			}
+		case MiterJoin, BevelJoin:
+			c.Fill(joinPath(pts[i-1], pts[i], pts[i+1], w, join))
+		}
+	}
+
+	// Caps and arrowheads.
+	for e := range tips {
+		switch {
+		case heads[e]:
+			c1, c2 := arrow.head(froms[e], tips[e])
+			var p vg.Path
+			p.Move(c1)
+			p.Line(tips[e])
+			p.Line(c2)
+			c.Push()
+			c.SetLineDash(nil, 0)
+			if arrow.Closed {
+				p.Close()
+				c.Fill(p)
+			}
+			c.Stroke(p)
+			c.Pop()
+		case cap == RoundCap:
+			c.Fill(circle(tips[e], w/2))
+		}
+	}
+}
+
+// joinPath returns the shape which fills the outer corner at b of the
+// segments a-b and b-c of width w.
+func joinPath(a, b, c vg.Point, w vg.Length, join LineJoin) vg.Path {
+	d1x, d1y := normalize(float64(b.X-a.X), float64(b.Y-a.Y))
+	d2x, d2y := normalize(float64(c.X-b.X), float64(c.Y-b.Y))
+	// Normals on the outer side of the corner.
+	side := 1.0
+	if d1x*d2y-d1y*d2x > 0 {
+		side = -1
+	}
+	hw := float64(w) / 2
+	n1x, n1y := -side*d1y*hw, side*d1x*hw
+	n2x, n2y := -side*d2y*hw, side*d2x*hw
+
+	var p vg.Path
+	p.Move(b)
+	p.Line(vg.Point{X: b.X + vg.Length(n1x), Y: b.Y + vg.Length(n1y)})
+	if join == MiterJoin {
+		// The miter tip lies on the bisector of the normals.
+		mx, my := n1x+n2x, n1y+n2y
+		if m := math.Hypot(mx, my); m > 0 {
+			cos := m / (2 * hw) // cosine of half the angle between the normals
+			if 1/cos <= miterLimit {
+				l := hw / cos
+				p.Line(vg.Point{X: b.X + vg.Length(mx/m*l), Y: b.Y + vg.Length(my/m*l)})
+			}
+		}
+	}
+	p.Line(vg.Point{X: b.X + vg.Length(n2x), Y: b.Y + vg.Length(n2y)})
+	p.Close()
+	return p
+}
+
+// circle returns the path of a circle around center with radius r.
+func circle(center vg.Point, r vg.Length) vg.Path {
+	var p vg.Path
+	p.Move(vg.Point{X: center.X + r, Y: center.Y})
+	p.Arc(center, r, 0, 2*math.Pi)
+	p.Close()
+	return p
+}
+
+func normalize(x, y float64) (float64, float64) {
+	l := math.Hypot(x, y)
+	if l == 0 {
+		return 0, 0
+	}
+	return x / l, y / l
+}
+
+func distance(a, b vg.Point) vg.Length {
+	return vg.Length(math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)))
+}
+
+// moveTowards returns the point at distance d from p in direction of q.
+func moveTowards(p, q vg.Point, d vg.Length) vg.Point {
+	dx, dy := normalize(float64(q.X-p.X), float64(q.Y-p.Y))
+	return vg.Point{X: p.X + vg.Length(dx*float64(d)), Y: p.Y + vg.Length(dy*float64(d))}
+}
+
 // -------------------------------------------------------------------------
 // Grob Text
 
@@ -238,6 +519,9 @@ func (text GrobText) Font() vg.Font {
 }
 
 func (text GrobText) Draw(vp Viewport) {
+	if vp.Clip && !insideUnit(text.x, text.y) {
+		return
+	}
 	vp.Canvas.Push()
 	vp.Canvas.SetColor(text.color)
 	x, y := vp.X(text.x), vp.Y(text.y)
@@ -248,7 +532,10 @@ func (text GrobText) Draw(vp Viewport) {
 	h := font.Extents().Ascent
 	dx := ww * vg.Length(text.hjust)
 	dy := hh * vg.Length(text.vjust)
-	if text.angle <= math.Pi/2 {
+	if text.angle < 0 && text.angle >= -math.Pi/2 {
+		// Text reading downwards.
+		dy += font.Width(text.text) * vg.Length(math.Sin(text.angle))
+	} else if text.angle <= math.Pi/2 {
 		dx -= h * vg.Length(math.Sin(text.angle))
 	} else if text.angle <= math.Pi {
 		dx -= ww
@@ -270,7 +557,7 @@ func (text GrobText) BoundingBox() (vg.Length, vg.Length) {
 	// Compute width ww and height hh of the rotateted bounding box.
 	w := font.Width(text.text)
 	h := font.Extents().Ascent
-	s := math.Sin(text.angle)
+	s := math.Abs(math.Sin(text.angle))
 	z := vg.Length(math.Sqrt(1 - s*s))
 	ww := w*z + h*vg.Length(s)
 	hh := w*vg.Length(s) + h*z
@@ -286,6 +573,262 @@ func (text GrobText) String() string {
 		180*text.angle/math.Pi, text.hjust, text.vjust, fnt)
 }
 
+// -------------------------------------------------------------------------
+// Grob Label
+
+// GrobLabel is a text in a box with rounded corners. The box is centered
+// at (x,y) displaced by (dx,dy). A displaced box is connected to (x,y) by
+// a leader segment if the box is farther away than minLeader.
+type GrobLabel struct {
+	x, y     float64
+	dx, dy   vg.Length
+	text     GrobText // Only text, size, color and font are used.
+	fill     color.Color
+	color    color.Color // Color of border and leader.
+	linetype LineType    // Linetype of border and leader.
+	size     float64     // Line width of border and leader.
+
+	padding, radius vg.Length
+}
+
+var _ Grob = GrobLabel{}
+
+// minLeader is the shortest leader segment drawn.
+const minLeader = 5 * vg.Millimeter / 2
+
+// Extent returns the width and height of the box of label.
+func (label GrobLabel) Extent() (vg.Length, vg.Length) {
+	w, h := label.text.BoundingBox()
+	return w + 2*label.padding, h + 2*label.padding
+}
+
+func (label GrobLabel) Draw(vp Viewport) {
+	if vp.Clip && !insideUnit(label.x, label.y) {
+		return
+	}
+	ax, ay := vp.X(label.x), vp.Y(label.y)
+	cx, cy := ax+label.dx, ay+label.dy
+	w, h := label.Extent()
+	xmin, ymin := cx-w/2, cy-h/2
+	xmax, ymax := cx+w/2, cy+h/2
+
+	vp.Canvas.Push()
+	vp.Canvas.SetLineWidth(vg.Points(label.size))
+	vp.Canvas.SetLineDash(dashLength[label.linetype], 0)
+	vp.Canvas.SetColor(label.color)
+
+	// Leader from anchor to the nearest point of the box.
+	lx := vg.Length(math.Max(float64(xmin), math.Min(float64(ax), float64(xmax))))
+	ly := vg.Length(math.Max(float64(ymin), math.Min(float64(ay), float64(ymax))))
+	if label.linetype != BlankLine && math.Hypot(float64(lx-ax), float64(ly-ay)) > float64(minLeader) {
+		var leader vg.Path
+		leader.Move(vg.Point{X: ax, Y: ay})
+		leader.Line(vg.Point{X: lx, Y: ly})
+		vp.Canvas.Stroke(leader)
+	}
+
+	box := roundedRect(xmin, ymin, xmax, ymax, label.radius)
+	if label.fill != nil {
+		vp.Canvas.SetColor(label.fill)
+		vp.Canvas.Fill(box)
+	}
+	if label.linetype != BlankLine {
+		vp.Canvas.SetColor(label.color)
+		vp.Canvas.Stroke(box)
+	}
+	vp.Canvas.Pop()
+
+	// Text is drawn in absolute canvas coordinates.
+	text := label.text
+	text.x, text.y = float64(cx), float64(cy)
+	text.hjust, text.vjust, text.angle = 0.5, 0.5, 0
+	text.Draw(Viewport{Canvas: vp.Canvas, Direct: true})
+}
+
+func (label GrobLabel) String() string {
+	return fmt.Sprintf("Label(%.3f,%.3f %+.1f%+.1f %q %s %s %s %.1f)",
+		label.x, label.y, label.dx.Points(), label.dy.Points(),
+		label.text.text, Color2String(label.fill),
+		Color2String(label.color), label.linetype.String(), label.size)
+}
+
+// roundedRect returns the outline of a rectangle with corners rounded
+// with radius r.
+func roundedRect(xmin, ymin, xmax, ymax, r vg.Length) vg.Path {
+	if max := (xmax - xmin) / 2; r > max {
+		r = max
+	}
+	if max := (ymax - ymin) / 2; r > max {
+		r = max
+	}
+	var p vg.Path
+	p.Move(vg.Point{X: xmin + r, Y: ymin})
+	p.Line(vg.Point{X: xmax - r, Y: ymin})
+	p.Arc(vg.Point{X: xmax - r, Y: ymin + r}, r, -math.Pi/2, math.Pi/2)
+	p.Line(vg.Point{X: xmax, Y: ymax - r})
+	p.Arc(vg.Point{X: xmax - r, Y: ymax - r}, r, 0, math.Pi/2)
+	p.Line(vg.Point{X: xmin + r, Y: ymax})
+	p.Arc(vg.Point{X: xmin + r, Y: ymax - r}, r, math.Pi/2, math.Pi/2)
+	p.Line(vg.Point{X: xmin, Y: ymin + r})
+	p.Arc(vg.Point{X: xmin + r, Y: ymin + r}, r, math.Pi, math.Pi/2)
+	p.Close()
+	return p
+}
+
+// -------------------------------------------------------------------------
+// Grob Repel
+
+// GrobRepel draws labels after moving them away from each other and from
+// the labeled points until they do not overlap. The final placement
+// depends on the extent of the viewport and is thus computed during
+// drawing. For a fixed seed the output is deterministic.
+type GrobRepel struct {
+	labels     []GrobLabel
+	seed       int64
+	iterations int
+}
+
+var _ Grob = GrobRepel{}
+
+func (repel GrobRepel) Draw(vp Viewport) {
+	if vp.Clip {
+		visible := []GrobLabel{}
+		for _, label := range repel.labels {
+			if insideUnit(label.x, label.y) {
+				visible = append(visible, label)
+			}
+		}
+		repel.labels = visible
+	}
+	n := len(repel.labels)
+	ax, ay := make([]float64, n), make([]float64, n)
+	ws, hs := make([]float64, n), make([]float64, n)
+	for i, label := range repel.labels {
+		ax[i], ay[i] = float64(vp.X(label.x)), float64(vp.Y(label.y))
+		w, h := label.Extent()
+		ws[i], hs[i] = float64(w), float64(h)
+	}
+	xmin, ymin := float64(vp.X(0)), float64(vp.Y(0))
+	xmax, ymax := float64(vp.X(1)), float64(vp.Y(1))
+	dx, dy := repelBoxes(ax, ay, ws, hs, xmin, ymin, xmax, ymax,
+		repel.seed, repel.iterations)
+
+	for i, label := range repel.labels {
+		label.dx, label.dy = vg.Length(dx[i]), vg.Length(dy[i])
+		label.Draw(vp)
+	}
+}
+
+func (repel GrobRepel) String() string {
+	return fmt.Sprintf("Repel of %d labels (seed %d)", len(repel.labels), repel.seed)
+}
+
+// repelBoxes moves boxes of size ws x hs, initially centered on the
+// anchors (ax,ay), until they overlap neither each other nor any anchor
+// or the maximum number of iterations is reached. Boxes are kept inside
+// [xmin,xmax] x [ymin,ymax]. The returned displacements are relative to
+// the anchors. All values are in the same unit, typically points.
+//
+// Each iteration pushes every pair of overlapping boxes apart along the
+// axis of smaller overlap. Anchors push overlapping boxes away with a
+// strength decreasing over the iterations. Ties are broken by a random
+// generator seeded with seed which makes the result reproducible.
+func repelBoxes(ax, ay, ws, hs []float64, xmin, ymin, xmax, ymax float64, seed int64, iterations int) (dx, dy []float64) {
+	const margin = 1.0      // Minimal distance between boxes.
+	const pointRadius = 6.0 // Minimal distance of box to anchor.
+
+	n := len(ax)
+	if iterations <= 0 {
+		iterations = 500
+	}
+	rng := rand.New(rand.NewSource(seed))
+	cx, cy := make([]float64, n), make([]float64, n)
+	for i := range cx {
+		cx[i] = ax[i] + rng.Float64() - 0.5
+		cy[i] = ay[i] + rng.Float64() - 0.5
+	}
+
+	direction := func(d float64) float64 {
+		switch {
+		case d > 0:
+			return 1
+		case d < 0:
+			return -1
+		}
+		return float64(2*rng.Intn(2) - 1)
+	}
+
+	fx, fy := make([]float64, n), make([]float64, n)
+	for it := 0; it < iterations; it++ {
+		moved := false
+		for i := range fx {
+			fx[i], fy[i] = 0, 0
+		}
+
+		// Boxes repel each other.
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				ox := (ws[i]+ws[j])/2 + margin - math.Abs(cx[i]-cx[j])
+				oy := (hs[i]+hs[j])/2 + margin - math.Abs(cy[i]-cy[j])
+				if ox <= 0 || oy <= 0 {
+					continue
+				}
+				moved = true
+				if ox < oy {
+					d := direction(cx[i]-cx[j]) * ox / 2
+					fx[i] += d
+					fx[j] -= d
+				} else {
+					d := direction(cy[i]-cy[j]) * oy / 2
+					fy[i] += d
+					fy[j] -= d
+				}
+			}
+		}
+
+		// Anchors repel boxes. Their influence fades over the iterations
+		// so that they cannot keep two boxes pushed into each other.
+		weight := 1 - float64(it)/float64(iterations)
+		for i := 0; i < n; i++ {
+			for k := 0; k < n; k++ {
+				ox := ws[i]/2 + pointRadius - math.Abs(cx[i]-ax[k])
+				oy := hs[i]/2 + pointRadius - math.Abs(cy[i]-ay[k])
+				if ox <= 0 || oy <= 0 {
+					continue
+				}
+				moved = true
+				if ox < oy {
+					fx[i] += weight * direction(cx[i]-ax[k]) * ox
+				} else {
+					fy[i] += weight * direction(cy[i]-ay[k]) * oy
+				}
+			}
+		}
+
+		if !moved {
+			break
+		}
+		for i := 0; i < n; i++ {
+			cx[i] = clamp(cx[i]+fx[i], xmin+ws[i]/2, xmax-ws[i]/2)
+			cy[i] = clamp(cy[i]+fy[i], ymin+hs[i]/2, ymax-hs[i]/2)
+		}
+	}
+
+	dx, dy = make([]float64, n), make([]float64, n)
+	for i := range dx {
+		dx[i], dy[i] = cx[i]-ax[i], cy[i]-ay[i]
+	}
+	return dx, dy
+}
+
+// clamp x to [min,max]. If min > max the midpoint is returned.
+func clamp(x, min, max float64) float64 {
+	if min > max {
+		return (min + max) / 2
+	}
+	return math.Max(min, math.Min(x, max))
+}
+
 // -------------------------------------------------------------------------
 // Grob Rect
 
@@ -298,6 +841,13 @@ type GrobRect struct {
 var _ Grob = GrobRect{}
 
 func (rect GrobRect) Draw(vp Viewport) {
+	if vp.Clip {
+		rect.xmin, rect.xmax = clampOrdered(rect.xmin, rect.xmax)
+		rect.ymin, rect.ymax = clampOrdered(rect.ymin, rect.ymax)
+		if rect.xmin == rect.xmax || rect.ymin == rect.ymax {
+			return
+		}
+	}
 	println("GrobRect.Draw: ", rect.String(), " to ", vp.String())
 	vp.Canvas.Push()
 	vp.Canvas.SetColor(rect.fill)
@@ -323,6 +873,144 @@ func (rect GrobRect) String() string {
 		Color2String(rect.fill))
 }
 
+// -------------------------------------------------------------------------
+// Grob Polygon
+
+// GrobPolygon is the filled area enclosed by points. Only the area is
+// drawn, not the outline.
+type GrobPolygon struct {
+	points []struct{ x, y float64 }
+	fill   color.Color
+}
+
+var _ Grob = GrobPolygon{}
+
+func (polygon GrobPolygon) Draw(vp Viewport) {
+	if vp.Clip {
+		polygon.points = clipPolygon(polygon.points)
+	}
+	if len(polygon.points) < 3 {
+		return
+	}
+	vp.Canvas.Push()
+	vp.Canvas.SetColor(polygon.fill)
+	var p vg.Path
+	p.Move(vg.Point{vp.X(polygon.points[0].x), vp.Y(polygon.points[0].y)})
+	for _, pt := range polygon.points[1:] {
+		p.Line(vg.Point{vp.X(pt.x), vp.Y(pt.y)})
+	}
+	p.Close()
+	vp.Canvas.Fill(p)
+	vp.Canvas.Pop()
+}
+
+func (polygon GrobPolygon) String() string {
+	return fmt.Sprintf("Polygon(%d points %s)",
+		len(polygon.points), Color2String(polygon.fill))
+}
+
+// -------------------------------------------------------------------------
+// Grob Raster
+
+// GrobRaster is an image stretched to the rectangle with the diagonal
+// corners (xmin,ymin) and (xmax,ymax). Pixel (0,0) of the image is drawn
+// to the upper left corner.
+type GrobRaster struct {
+	xmin, ymin float64
+	xmax, ymax float64
+	image      image.Image
+}
+
+var _ Grob = GrobRaster{}
+
+func (raster GrobRaster) Draw(vp Viewport) {
+	if vp.Clip {
+		var ok bool
+		if raster, ok = raster.clip(); !ok {
+			return
+		}
+	}
+	xmin, ymin := vp.X(raster.xmin), vp.Y(raster.ymin)
+	xmax, ymax := vp.X(raster.xmax), vp.Y(raster.ymax)
+	rect := vg.Rectangle{
+		Min: vg.Point{X: xmin, Y: ymin},
+		Max: vg.Point{X: xmax, Y: ymax},
+	}
+
+	// Canvases interpolate the image while stretching it which blurs
+	// the cells of small rasters. Enlarge the image (nearest neighbour)
+	// to roughly twice the target size in points before handing it over.
+	b := raster.image.Bounds()
+	kx := int(math.Ceil(2 * math.Abs((xmax - xmin).Points()) / float64(b.Dx())))
+	ky := int(math.Ceil(2 * math.Abs((ymax - ymin).Points()) / float64(b.Dy())))
+	vp.Canvas.DrawImage(rect, upscale(raster.image, kx, ky))
+}
+
+// clip restricts raster to the unit square by cropping its image to the
+// visible cells. Cells cut by the border are kept completely. It reports
+// false if no cell is visible.
+func (raster GrobRaster) clip() (GrobRaster, bool) {
+	b := raster.image.Bounds()
+	dx := (raster.xmax - raster.xmin) / float64(b.Dx())
+	dy := (raster.ymax - raster.ymin) / float64(b.Dy())
+	// visible returns the range [c0,c1) of the n cells of size d starting
+	// at 0 which intersect [lo,hi].
+	visible := func(lo, hi, d float64, n int) (int, int) {
+		c0 := int(math.Max(0, math.Floor(lo/d+clipEps)))
+		c1 := int(math.Min(float64(n), math.Ceil(hi/d-clipEps)))
+		return c0, c1
+	}
+	x0, x1 := visible(-raster.xmin, 1-raster.xmin, dx, b.Dx())
+	// Image rows run from the top (ymax) downwards.
+	y0, y1 := visible(raster.ymax-1, raster.ymax, dy, b.Dy())
+	if x0 >= x1 || y0 >= y1 {
+		return raster, false
+	}
+	if x0 == 0 && y0 == 0 && x1 == b.Dx() && y1 == b.Dy() {
+		return raster, true
+	}
+	cropped := image.NewNRGBA(image.Rect(0, 0, x1-x0, y1-y0))
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			cropped.Set(x-x0, y-y0, raster.image.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return GrobRaster{
+		xmin: raster.xmin + float64(x0)*dx, xmax: raster.xmin + float64(x1)*dx,
+		ymin: raster.ymax - float64(y1)*dy, ymax: raster.ymax - float64(y0)*dy,
+		image: cropped,
+	}, true
+}
+
+// upscale enlarges img by the integer factors kx and ky by replicating
+// pixels.
+func upscale(img image.Image, kx, ky int) image.Image {
+	if kx <= 1 && ky <= 1 {
+		return img
+	}
+	if kx < 1 {
+		kx = 1
+	}
+	if ky < 1 {
+		ky = 1
+	}
+	b := img.Bounds()
+	big := image.NewNRGBA(image.Rect(0, 0, kx*b.Dx(), ky*b.Dy()))
+	for y := 0; y < big.Bounds().Dy(); y++ {
+		for x := 0; x < big.Bounds().Dx(); x++ {
+			big.Set(x, y, img.At(b.Min.X+x/kx, b.Min.Y+y/ky))
+		}
+	}
+	return big
+}
+
+func (raster GrobRaster) String() string {
+	b := raster.image.Bounds()
+	return fmt.Sprintf("Raster(%.3f,%.3f - %.3f,%.3f %dx%d)",
+		raster.xmin, raster.ymin, raster.xmax, raster.ymax,
+		b.Dx(), b.Dy())
+}
+
 // -------------------------------------------------------------------------
 // Grob Group
 
@@ -361,6 +1049,10 @@ type Viewport struct {
 	// Direct to true interpretes geom coordinates a direct
 	// length and uses them unscaled.
 	Direct bool
+
+	// Clip restricts drawing to the viewport: Grobs are clipped to
+	// the unit square of natural grob coordinates.
+	Clip bool
 }
 
 func (vp Viewport) String() string {
@@ -411,3 +1103,121 @@ func (vp Viewport) XI(w vg.Length) float64 {
 func (vp Viewport) YI(h vg.Length) float64 {
 	return float64(h / vp.Height)
 }
+
+// -------------------------------------------------------------------------
+// Clipping to the unit square
+
+// clipEps is the tolerance for coordinates on the border of the unit
+// square.
+const clipEps = 1e-9
+
+func insideUnit(x, y float64) bool {
+	return x >= -clipEps && x <= 1+clipEps && y >= -clipEps && y <= 1+clipEps
+}
+
+// clampOrdered clamps a and b to [0,1] and returns them ordered.
+func clampOrdered(a, b float64) (float64, float64) {
+	if a > b {
+		a, b = b, a
+	}
+	return clamp(a, 0, 1), clamp(b, 0, 1)
+}
+
+// clipSegment clips the segment (x0,y0)-(x1,y1) to the unit square with
+// the algorithm of Liang and Barsky. It reports false if no part of the
+// segment is visible. Unclipped ends are returned unchanged.
+func clipSegment(x0, y0, x1, y1 float64) (float64, float64, float64, float64, bool) {
+	dx, dy := x1-x0, y1-y0
+	t0, t1 := 0.0, 1.0
+	p := [4]float64{-dx, dx, -dy, dy}
+	q := [4]float64{x0 + clipEps, 1 + clipEps - x0, y0 + clipEps, 1 + clipEps - y0}
+	for i := range p {
+		if p[i] == 0 {
+			if q[i] < 0 {
+				return 0, 0, 0, 0, false
+			}
+			continue
+		}
+		r := q[i] / p[i]
+		if p[i] < 0 {
+			if r > t1 {
+				return 0, 0, 0, 0, false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return 0, 0, 0, 0, false
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	cx0, cy0, cx1, cy1 := x0, y0, x1, y1
+	if t0 > 0 {
+		cx0, cy0 = x0+t0*dx, y0+t0*dy
+	}
+	if t1 < 1 {
+		cx1, cy1 = x0+t1*dx, y0+t1*dy
+	}
+	return cx0, cy0, cx1, cy1, true
+}
+
+// clipPath clips the polyline points to the unit square. The visible parts
+// are returned as separate polylines.
+func clipPath(points []struct{ x, y float64 }) [][]struct{ x, y float64 } {
+	pieces := [][]struct{ x, y float64 }{}
+	var current []struct{ x, y float64 }
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		x0, y0, x1, y1, ok := clipSegment(a.x, a.y, b.x, b.y)
+		if !ok {
+			continue
+		}
+		start := struct{ x, y float64 }{x0, y0}
+		if current == nil || current[len(current)-1] != start {
+			if len(current) > 1 {
+				pieces = append(pieces, current)
+			}
+			current = []struct{ x, y float64 }{start}
+		}
+		current = append(current, struct{ x, y float64 }{x1, y1})
+	}
+	if len(current) > 1 {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// clipPolygon clips the polygon points to the unit square with the
+// algorithm of Sutherland and Hodgman.
+func clipPolygon(points []struct{ x, y float64 }) []struct{ x, y float64 } {
+	type pt = struct{ x, y float64 }
+	edges := []struct {
+		inside func(p pt) bool
+		cut    func(a, b pt) pt
+	}{
+		{func(p pt) bool { return p.x >= -clipEps }, func(a, b pt) pt { return pt{0, a.y + (b.y-a.y)*(0-a.x)/(b.x-a.x)} }},
+		{func(p pt) bool { return p.x <= 1+clipEps }, func(a, b pt) pt { return pt{1, a.y + (b.y-a.y)*(1-a.x)/(b.x-a.x)} }},
+		{func(p pt) bool { return p.y >= -clipEps }, func(a, b pt) pt { return pt{a.x + (b.x-a.x)*(0-a.y)/(b.y-a.y), 0} }},
+		{func(p pt) bool { return p.y <= 1+clipEps }, func(a, b pt) pt { return pt{a.x + (b.x-a.x)*(1-a.y)/(b.y-a.y), 1} }},
+	}
+	out := points
+	for _, edge := range edges {
+		in := out
+		out = nil
+		for i, b := range in {
+			a := in[(i+len(in)-1)%len(in)]
+			switch {
+			case edge.inside(b) && edge.inside(a):
+				out = append(out, b)
+			case edge.inside(b):
+				out = append(out, edge.cut(a, b), b)
+			case edge.inside(a):
+				out = append(out, edge.cut(a, b))
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+	}
+	return out
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/recorder"
 	"gonum.org/v1/plot/vg/vgimg"
 )
 
@@ -263,3 +264,104 @@ func TestTextGrobs(t *testing.T) {
 	pngCanvas.WriteTo(file)
 	file.Close()
 }
+
+func TestRepelBoxes(t *testing.T) {
+	// Five boxes of 30x10 stacked on nearly the same point.
+	ax := []float64{100, 101, 102, 100, 99}
+	ay := []float64{100, 100, 101, 102, 99}
+	ws := []float64{30, 30, 30, 30, 30}
+	hs := []float64{10, 10, 10, 10, 10}
+
+	dx, dy := repelBoxes(ax, ay, ws, hs, 0, 0, 400, 400, 42, 0)
+	for i := range dx {
+		for j := i + 1; j < len(dx); j++ {
+			ox := (ws[i]+ws[j])/2 - math.Abs(ax[i]+dx[i]-ax[j]-dx[j])
+			oy := (hs[i]+hs[j])/2 - math.Abs(ay[i]+dy[i]-ay[j]-dy[j])
+			if ox > 0 && oy > 0 {
+				t.Errorf("Boxes %d and %d overlap by %.1f x %.1f", i, j, ox, oy)
+			}
+		}
+	}
+
+	// Same seed, same result.
+	dx2, dy2 := repelBoxes(ax, ay, ws, hs, 0, 0, 400, 400, 42, 0)
+	for i := range dx {
+		if dx[i] != dx2[i] || dy[i] != dy2[i] {
+			t.Errorf("Box %d: got %.2f,%.2f and %.2f,%.2f", i, dx[i], dy[i], dx2[i], dy2[i])
+		}
+	}
+}
+
+func TestClipping(t *testing.T) {
+	type pt = struct{ x, y float64 }
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+	for i, tc := range []struct {
+		seg  [4]float64
+		want [4]float64
+		ok   bool
+	}{
+		{[4]float64{0.2, 0.2, 0.8, 0.8}, [4]float64{0.2, 0.2, 0.8, 0.8}, true},
+		{[4]float64{-1, 0.5, 2, 0.5}, [4]float64{0, 0.5, 1, 0.5}, true},
+		{[4]float64{0.5, 0.5, 0.5, 3}, [4]float64{0.5, 0.5, 0.5, 1}, true},
+		{[4]float64{-1, -1, 2, 2}, [4]float64{0, 0, 1, 1}, true},
+		{[4]float64{1.5, 0, 1.5, 1}, [4]float64{}, false},
+		{[4]float64{-1, 0.5, 0.5, 2}, [4]float64{}, false},
+	} {
+		x0, y0, x1, y1, ok := clipSegment(tc.seg[0], tc.seg[1], tc.seg[2], tc.seg[3])
+		if ok != tc.ok {
+			t.Errorf("%d: Got ok=%t", i, ok)
+			continue
+		}
+		if ok && !(near(x0, tc.want[0]) && near(y0, tc.want[1]) && near(x1, tc.want[2]) && near(y1, tc.want[3])) {
+			t.Errorf("%d: Got %.3f,%.3f - %.3f,%.3f", i, x0, y0, x1, y1)
+		}
+	}
+
+	// A path leaving and re-entering the unit square is split.
+	pieces := clipPath([]pt{{0.1, 0.5}, {0.5, 1.5}, {0.9, 0.5}, {0.9, 0.1}})
+	if len(pieces) != 2 || len(pieces[0]) != 2 || len(pieces[1]) != 3 {
+		t.Errorf("Got pieces %v", pieces)
+	} else if !near(pieces[0][1].y, 1) || !near(pieces[1][0].y, 1) {
+		t.Errorf("Bad cut points in %v", pieces)
+	}
+
+	// A triangle sticking out on the right gets a vertical edge at x=1.
+	polygon := clipPolygon([]pt{{0.5, 0}, {1.5, 0.5}, {0.5, 1}})
+	area := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if len(polygon) != 4 || !near(math.Abs(area)/2, 0.375) {
+		t.Errorf("Got polygon %v with area %.3f", polygon, math.Abs(area)/2)
+	}
+	if clipPolygon([]pt{{2, 2}, {3, 2}, {3, 3}}) != nil {
+		t.Errorf("Invisible polygon not removed")
+	}
+}
+
+func TestLineJoins(t *testing.T) {
+	points := []vg.Point{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 20, Y: 0}, {X: 30, Y: 10}}
+	for _, tc := range []struct {
+		join  LineJoin
+		fills int
+	}{
+		{DefaultJoin, 0}, // corners are left to the canvas
+		{RoundJoin, 2},
+		{MiterJoin, 2},
+		{BevelJoin, 2},
+	} {
+		canvas := &recorder.Canvas{}
+		strokePolyline(canvas, points, 4, SolidLine, ButtCap, tc.join, nil)
+		fills := 0
+		for _, a := range canvas.Actions {
+			if _, ok := a.(*recorder.Fill); ok {
+				fills++
+			}
+		}
+		if fills != tc.fills {
+			t.Errorf("Join %d: got %d fills, want %d", tc.join, fills, tc.fills)
+		}
+	}
+}
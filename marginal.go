@@ -0,0 +1,264 @@
+package plot
+
+import (
+	"math"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// -------------------------------------------------------------------------
+// Marginal Distributions
+
+// MarginalType determines how a marginal distribution is displayed.
+type MarginalType int
+
+const (
+	MarginalHistogram MarginalType = iota
+	MarginalDensity
+)
+
+// Marginal describes the display of the marginal distribution of the
+// x values (in an extra area on top of the panels) or the y values (in an
+// extra area right of the panels) of the plot data.
+//
+// On faceted plots the marginal of a column (x) or row (y) shows the
+// distribution of all panels in this column or row. On flipped coordinates
+// the marginal of x is drawn right of the panels and the one of y on top.
+type Marginal struct {
+	// Type selects histogram or kernel density.
+	Type MarginalType
+
+	// Bins is the number of bins of a histogram. Zero means 30.
+	Bins int
+
+	// Size is the height (x) or width (y) of the marginal area.
+	// Zero means 15 mm.
+	Size vg.Length
+
+	// Style contains the fill of the histogram bars and the color,
+	// size, linetype and alpha of the density curve. Unset values are
+	// taken from the theme.
+	Style AesMapping
+}
+
+func (m *Marginal) size() vg.Length {
+	if m.Size == 0 {
+		return 15 * vg.Millimeter
+	}
+	return m.Size
+}
+
+// renderMarginals populates the Tmg and Rmg grobs of the top row and right
+// column of panels.
+func (plot *Plot) renderMarginals() {
+	nrows, ncols := len(plot.Panels), len(plot.Panels[0])
+	horizontal, vertical := plot.axes()
+	mTop, mRight := plot.MarginalX, plot.MarginalY
+	if plot.coord().Flipped() {
+		mTop, mRight = mRight, mTop
+	}
+	if m := mTop; m != nil {
+		for c := 0; c < ncols; c++ {
+			top := plot.Panels[nrows-1][c]
+			top.Tmg = m.render(plot.columnValues(c, horizontal), top.Scales[horizontal], plot.Theme, false)
+		}
+		plot.renderInfo["Marginal-X.Height"] = m.size()
+	}
+	if m := mRight; m != nil {
+		for r := 0; r < nrows; r++ {
+			right := plot.Panels[r][ncols-1]
+			if right.blank {
+				continue
+			}
+			right.Rmg = m.render(plot.rowValues(r, vertical), right.Scales[vertical], plot.Theme, true)
+		}
+		plot.renderInfo["Marginal-Y.Width"] = m.size()
+	}
+}
+
+// columnValues returns the marginal values of aes of the panels in column
+// c. A total row is skipped as its data is contained in the other rows.
+func (plot *Plot) columnValues(c int, aes string) []float64 {
+	values := []float64{}
+	for r := range plot.Panels {
+		if plot.Panels[r][0].total {
+			continue // the first column is never a total column
+		}
+		values = append(values, plot.Panels[r][c].marginals[aes]...)
+	}
+	return values
+}
+
+// rowValues returns the marginal values of aes of the panels in row r.
+// A total column is skipped as its data is contained in the other columns.
+func (plot *Plot) rowValues(r int, aes string) []float64 {
+	top := plot.Panels[len(plot.Panels)-1]
+	values := []float64{}
+	for c := range plot.Panels[r] {
+		if top[c].total {
+			continue // the top row is never a total row
+		}
+		values = append(values, plot.Panels[r][c].marginals[aes]...)
+	}
+	return values
+}
+
+// collectMarginals stores the (scale transformed) values of x and y of
+// the prepared layer data of panel for the marginal distributions. Layers
+// sharing the same data and field contribute their values only once.
+func (panel *Panel) collectMarginals() {
+	type source struct {
+		data *DataFrame
+		name string
+	}
+	panel.marginals = make(map[string][]float64)
+	for _, aes := range []string{"x", "y"} {
+		seen := make(map[source]bool)
+		for i, layer := range panel.Layers {
+			name, ok := MergeAes(layer.DataMapping, panel.Plot.Aes)[aes]
+			src := source{panel.Plot.Layers[i].Data, name}
+			field, has := layer.Data.Columns[aes]
+			if !ok || !has || seen[src] {
+				continue
+			}
+			seen[src] = true
+			panel.marginals[aes] = append(panel.marginals[aes], field.Data...)
+		}
+	}
+}
+
+// render produces the grobs of the marginal distribution of values on
+// scale. The distribution is drawn in natural coordinates of the marginal
+// area: The scale runs along x (or y if vertical) and the height of the
+// distribution along y (or x).
+func (m *Marginal) render(values []float64, scale *Scale, theme Theme, vertical bool) []Grob {
+	if len(values) == 0 || (!scale.Discrete && !(scale.Max > scale.Min)) {
+		return nil // Nothing to show or no room to show it.
+	}
+	style := MergeStyles(m.Style, theme.Marginal, DefaultTheme.Marginal)
+	alpha := String2Float(style["alpha"], 0, 1)
+	fill := SetAlpha(String2Color(style["fill"]), alpha)
+
+	// Bars of the histogram from lo to hi with relative height h.
+	type bar struct{ lo, hi, h float64 }
+	var bars []bar
+	var curve []struct{ x, y float64 }
+
+	switch {
+	case scale.Discrete:
+		counts := make(map[float64]float64)
+		max := 0.0
+		for _, v := range values {
+			counts[v]++
+			if counts[v] > max {
+				max = counts[v]
+			}
+		}
+		for v, n := range counts {
+			bars = append(bars, bar{v - 0.45, v + 0.45, n / max})
+		}
+	case m.Type == MarginalDensity:
+		curve = kernelDensity(values, scale.Min, scale.Max, 101)
+	default:
+		nbins := m.Bins
+		if nbins <= 0 {
+			nbins = 30
+		}
+		width := (scale.Max - scale.Min) / float64(nbins)
+		counts := make([]float64, nbins)
+		max := 0.0
+		for _, v := range values {
+			b := int((v - scale.Min) / width)
+			if b == nbins && v == scale.Max {
+				b = nbins - 1 // The last bin includes the upper limit.
+			}
+			if b < 0 || b >= nbins {
+				continue
+			}
+			counts[b]++
+			if counts[b] > max {
+				max = counts[b]
+			}
+		}
+		for b, n := range counts {
+			if n == 0 {
+				continue
+			}
+			lo := scale.Min + float64(b)*width
+			bars = append(bars, bar{lo, lo + width, n / max})
+		}
+	}
+
+	// Leave some space between the distribution and the strips.
+	const headroom = 0.9
+	grobs := []Grob{}
+	for _, b := range bars {
+		lo, hi, h := scale.Pos(b.lo), scale.Pos(b.hi), headroom*b.h
+		rect := GrobRect{xmin: lo, xmax: hi, ymin: 0, ymax: h, fill: fill}
+		if vertical {
+			rect = GrobRect{xmin: 0, xmax: h, ymin: lo, ymax: hi, fill: fill}
+		}
+		grobs = append(grobs, rect)
+	}
+	if len(curve) > 0 {
+		max := 0.0
+		for _, p := range curve {
+			max = math.Max(max, p.y)
+		}
+		points := make([]struct{ x, y float64 }, len(curve))
+		for i, p := range curve {
+			pos, h := scale.Pos(p.x), 0.0
+			if max > 0 {
+				h = headroom * p.y / max
+			}
+			if vertical {
+				points[i].x, points[i].y = h, pos
+			} else {
+				points[i].x, points[i].y = pos, h
+			}
+		}
+		grobs = append(grobs, GrobPath{
+			points:   points,
+			color:    SetAlpha(String2Color(style["color"]), alpha),
+			size:     String2Float(style["size"], 0, 20),
+			linetype: String2LineType(style["linetype"]),
+		})
+	}
+
+	return grobs
+}
+
+// kernelDensity estimates the density of values with a gaussian kernel
+// of bandwith determined by Silverman's rule of thumb. The density is
+// evaluated on n points equally spaced in [min,max].
+func kernelDensity(values []float64, min, max float64, n int) []struct{ x, y float64 } {
+	N := float64(len(values))
+	mean, sd := 0.0, 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= N
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	if N > 1 {
+		sd = math.Sqrt(sd / (N - 1))
+	}
+	bw := 1.06 * sd * math.Pow(N, -0.2)
+	if bw == 0 {
+		bw = (max - min) / 30
+	}
+
+	density := make([]struct{ x, y float64 }, n)
+	norm := 1 / (N * bw * math.Sqrt(2*math.Pi))
+	for i := range density {
+		x := min + float64(i)*(max-min)/float64(n-1)
+		y := 0.0
+		for _, v := range values {
+			u := (x - v) / bw
+			y += math.Exp(-u * u / 2)
+		}
+		density[i].x, density[i].y = x, y*norm
+	}
+	return density
+}
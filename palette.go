@@ -0,0 +1,619 @@
+package plot
+
+import (
+	"image/color"
+	"math"
+)
+
+// Palette provides the colors of color and fill scales.
+type Palette interface {
+	// Color maps t in [0,1] to a color. It is used by continuous scales.
+	Color(t float64) color.Color
+
+	// Colors returns n colors to be used for the n levels of a
+	// discrete scale.
+	Colors(n int) []color.Color
+}
+
+// ColorSpace is the color space in which palettes interpolate colors.
+type ColorSpace int
+
+const (
+	// LabSpace interpolates in CIE L*a*b* which is perceptually uniform:
+	// Equal steps are percieved as equal changes of color.
+	LabSpace ColorSpace = iota
+
+	// HCLSpace interpolates hue, chroma and luminance (the polar form
+	// of L*a*b*) which keeps colors saturated but passes through other
+	// hues.
+	HCLSpace
+
+	// RGBSpace interpolates in plain sRGB.
+	RGBSpace
+)
+
+// -------------------------------------------------------------------------
+// Hue palette
+
+// HuePalette places colors equidistant on the hue circle with fixed
+// saturation and value (in HSV). It is the default palette of color scales.
+type HuePalette struct {
+	Saturation float64 // Zero means 1.
+	Value      float64 // Zero means 0.8.
+}
+
+// Color maps t to hue, leaving out the last sixth of the (cyclic) hue
+// circle so that 0 and 1 get different colors.
+func (p HuePalette) Color(t float64) color.Color {
+	s, v := p.Saturation, p.Value
+	if s == 0 {
+		s = 1
+	}
+	if v == 0 {
+		v = 0.8
+	}
+	return hsv(clamp01(t)*5/6, s, v)
+}
+
+// Colors returns n colors with equidistant hues.
+func (p HuePalette) Colors(n int) []color.Color {
+	colors := make([]color.Color, n)
+	for i := range colors {
+		colors[i] = p.Color(float64(i) / float64(n))
+	}
+	return colors
+}
+
+// hsv converts h,s,v (all in [0,1]) to RGB.
+func hsv(h, s, v float64) color.Color {
+	hi := int(h * 6)
+	f := h*6 - float64(hi)
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	vv, tt, pp, qq := uint8(v*255), uint8(t*255), uint8(p*255), uint8(q*255)
+	switch hi {
+	case 0, 6:
+		return color.RGBA{vv, tt, pp, 0xff}
+	case 1:
+		return color.RGBA{qq, vv, pp, 0xff}
+	case 2:
+		return color.RGBA{pp, vv, tt, 0xff}
+	case 3:
+		return color.RGBA{pp, qq, vv, 0xff}
+	case 4:
+		return color.RGBA{tt, pp, vv, 0xff}
+	case 5:
+		return color.RGBA{vv, pp, qq, 0xff}
+	}
+	return color.RGBA{}
+}
+
+// -------------------------------------------------------------------------
+// Gradients
+
+// Gradient is a palette of two colors Low and High or, if Mid is non-nil,
+// a diverging palette of three colors. The colors are interpolated in
+// Space.
+type Gradient struct {
+	Low, Mid, High color.Color
+
+	// Midpoint is the data value which gets the color Mid. It is used
+	// by scales only if Mid is set.
+	Midpoint float64
+
+	Space ColorSpace
+}
+
+// Color interpolates the colors of g.
+func (g Gradient) Color(t float64) color.Color {
+	t = clamp01(t)
+	if g.Mid == nil {
+		return interpolate(g.Low, g.High, t, g.Space)
+	}
+	if t < 0.5 {
+		return interpolate(g.Low, g.Mid, 2*t, g.Space)
+	}
+	return interpolate(g.Mid, g.High, 2*t-1, g.Space)
+}
+
+// Colors returns n equidistant colors of g.
+func (g Gradient) Colors(n int) []color.Color {
+	return sample(g, n)
+}
+
+func (g Gradient) midpoint() (float64, bool) {
+	return g.Midpoint, g.Mid != nil
+}
+
+// midpointer is implemented by palettes which map a certain data value
+// to their center.
+type midpointer interface {
+	midpoint() (float64, bool)
+}
+
+// -------------------------------------------------------------------------
+// Color ramps
+
+// PaletteKind distinguishes the intended use of a ColorRamp.
+type PaletteKind int
+
+const (
+	Sequential  PaletteKind = iota // ordered data from low to high
+	Diverging                      // data with a critical mid value
+	Qualitative                    // unordered categories
+)
+
+// ColorRamp is a palette of a list of colors. Sequential and diverging
+// ramps interpolate the colors in L*a*b*; qualitative ramps use the colors
+// as they are and repeat them if more colors are requested.
+type ColorRamp struct {
+	Name   string
+	Kind   PaletteKind
+	Values []color.Color
+
+	// Classes optionally contains dedicated sets of n colors by n
+	// which are used by Colors instead of interpolating Values.
+	Classes map[int][]color.Color
+}
+
+// Color interpolates the colors of the ramp or, for a qualitative ramp,
+// returns the nearest color.
+func (r ColorRamp) Color(t float64) color.Color {
+	n := len(r.Values)
+	t = clamp01(t)
+	if r.Kind == Qualitative {
+		return r.Values[int(t*float64(n-1)+0.5)]
+	}
+	x := t * float64(n-1)
+	i := int(x)
+	if i >= n-1 {
+		return r.Values[n-1]
+	}
+	return interpolate(r.Values[i], r.Values[i+1], x-float64(i), LabSpace)
+}
+
+// Colors returns the n-class set of Classes if present, the first n
+// colors of a qualitative ramp and n colors evenly spread over the whole
+// ramp otherwise.
+func (r ColorRamp) Colors(n int) []color.Color {
+	if set, ok := r.Classes[n]; ok {
+		return append([]color.Color(nil), set...)
+	}
+	if r.Kind != Qualitative {
+		return sample(r, n)
+	}
+	colors := make([]color.Color, n)
+	for i := range colors {
+		colors[i] = r.Values[i%len(r.Values)]
+	}
+	return colors
+}
+
+// ramp constructs a ColorRamp from colors written as hex strings.
+func ramp(name string, kind PaletteKind, hex ...string) ColorRamp {
+	r := ColorRamp{Name: name, Kind: kind}
+	for _, h := range hex {
+		r.Values = append(r.Values, String2Color("#"+h))
+	}
+	return r
+}
+
+// The perceptually uniform palettes from matplotlib.
+var (
+	Viridis = ramp("viridis", Sequential, "440154", "472d7b", "3b528b", "2c728e",
+		"21908c", "27ad81", "5dc863", "aadc32", "fde725")
+	Magma = ramp("magma", Sequential, "000004", "1d1147", "51127c", "822681",
+		"b63679", "e65164", "fb8861", "fec287", "fcfdbf")
+	Inferno = ramp("inferno", Sequential, "000004", "1f0c48", "550f6d", "88226a",
+		"ba3655", "e35932", "f98c0a", "f9c932", "fcffa4")
+	Plasma = ramp("plasma", Sequential, "0d0887", "47039f", "7301a8", "9c179e",
+		"bd3786", "d8576b", "ed7953", "fa9e3b", "f0f921")
+	Cividis = ramp("cividis", Sequential, "00204d", "00336f", "39486b", "575c6d",
+		"707173", "8a8779", "a69d75", "c4b56c", "e4cf5b", "ffea46")
+)
+
+// BrewerPalettes contains all 35 ColorBrewer palettes (http://colorbrewer2.org)
+// by their name, each with the maximal number of classes. The sequential
+// and diverging palettes contain all their published n-class sets; the
+// n-class sets of the qualitative palettes are the first n colors.
+var BrewerPalettes = map[string]ColorRamp{}
+
+func init() {
+	for _, r := range []ColorRamp{
+		// Sequential, 3 to 9 classes
+		brewer("Blues", Sequential,
+			"deebf79ecae13182bd",
+			"eff3ffbdd7e76baed62171b5",
+			"eff3ffbdd7e76baed63182bd08519c",
+			"eff3ffc6dbef9ecae16baed63182bd08519c",
+			"eff3ffc6dbef9ecae16baed64292c62171b5084594",
+			"f7fbffdeebf7c6dbef9ecae16baed64292c62171b5084594",
+			"f7fbffdeebf7c6dbef9ecae16baed64292c62171b508519c08306b"),
+		brewer("BuGn", Sequential,
+			"e5f5f999d8c92ca25f",
+			"edf8fbb2e2e266c2a4238b45",
+			"edf8fbb2e2e266c2a42ca25f006d2c",
+			"edf8fbccece699d8c966c2a42ca25f006d2c",
+			"edf8fbccece699d8c966c2a441ae76238b45005824",
+			"f7fcfde5f5f9ccece699d8c966c2a441ae76238b45005824",
+			"f7fcfde5f5f9ccece699d8c966c2a441ae76238b45006d2c00441b"),
+		brewer("BuPu", Sequential,
+			"e0ecf49ebcda8856a7",
+			"edf8fbb3cde38c96c688419d",
+			"edf8fbb3cde38c96c68856a7810f7c",
+			"edf8fbbfd3e69ebcda8c96c68856a7810f7c",
+			"edf8fbbfd3e69ebcda8c96c68c6bb188419d6e016b",
+			"f7fcfde0ecf4bfd3e69ebcda8c96c68c6bb188419d6e016b",
+			"f7fcfde0ecf4bfd3e69ebcda8c96c68c6bb188419d810f7c4d004b"),
+		brewer("GnBu", Sequential,
+			"e0f3dba8ddb543a2ca",
+			"f0f9e8bae4bc7bccc42b8cbe",
+			"f0f9e8bae4bc7bccc443a2ca0868ac",
+			"f0f9e8ccebc5a8ddb57bccc443a2ca0868ac",
+			"f0f9e8ccebc5a8ddb57bccc44eb3d32b8cbe08589e",
+			"f7fcf0e0f3dbccebc5a8ddb57bccc44eb3d32b8cbe08589e",
+			"f7fcf0e0f3dbccebc5a8ddb57bccc44eb3d32b8cbe0868ac084081"),
+		brewer("Greens", Sequential,
+			"e5f5e0a1d99b31a354",
+			"edf8e9bae4b374c476238b45",
+			"edf8e9bae4b374c47631a354006d2c",
+			"edf8e9c7e9c0a1d99b74c47631a354006d2c",
+			"edf8e9c7e9c0a1d99b74c47641ab5d238b45005a32",
+			"f7fcf5e5f5e0c7e9c0a1d99b74c47641ab5d238b45005a32",
+			"f7fcf5e5f5e0c7e9c0a1d99b74c47641ab5d238b45006d2c00441b"),
+		brewer("Greys", Sequential,
+			"f0f0f0bdbdbd636363",
+			"f7f7f7cccccc969696525252",
+			"f7f7f7cccccc969696636363252525",
+			"f7f7f7d9d9d9bdbdbd969696636363252525",
+			"f7f7f7d9d9d9bdbdbd969696737373525252252525",
+			"fffffff0f0f0d9d9d9bdbdbd969696737373525252252525",
+			"fffffff0f0f0d9d9d9bdbdbd969696737373525252252525000000"),
+		brewer("OrRd", Sequential,
+			"fee8c8fdbb84e34a33",
+			"fef0d9fdcc8afc8d59d7301f",
+			"fef0d9fdcc8afc8d59e34a33b30000",
+			"fef0d9fdd49efdbb84fc8d59e34a33b30000",
+			"fef0d9fdd49efdbb84fc8d59ef6548d7301f990000",
+			"fff7ecfee8c8fdd49efdbb84fc8d59ef6548d7301f990000",
+			"fff7ecfee8c8fdd49efdbb84fc8d59ef6548d7301fb300007f0000"),
+		brewer("Oranges", Sequential,
+			"fee6cefdae6be6550d",
+			"feeddefdbe85fd8d3cd94701",
+			"feeddefdbe85fd8d3ce6550da63603",
+			"feeddefdd0a2fdae6bfd8d3ce6550da63603",
+			"feeddefdd0a2fdae6bfd8d3cf16913d948018c2d04",
+			"fff5ebfee6cefdd0a2fdae6bfd8d3cf16913d948018c2d04",
+			"fff5ebfee6cefdd0a2fdae6bfd8d3cf16913d94801a636037f2704"),
+		brewer("PuBu", Sequential,
+			"ece7f2a6bddb2b8cbe",
+			"f1eef6bdc9e174a9cf0570b0",
+			"f1eef6bdc9e174a9cf2b8cbe045a8d",
+			"f1eef6d0d1e6a6bddb74a9cf2b8cbe045a8d",
+			"f1eef6d0d1e6a6bddb74a9cf3690c00570b0034e7b",
+			"fff7fbece7f2d0d1e6a6bddb74a9cf3690c00570b0034e7b",
+			"fff7fbece7f2d0d1e6a6bddb74a9cf3690c00570b0045a8d023858"),
+		brewer("PuBuGn", Sequential,
+			"ece2f0a6bddb1c9099",
+			"f6eff7bdc9e167a9cf02818a",
+			"f6eff7bdc9e167a9cf1c9099016c59",
+			"f6eff7d0d1e6a6bddb67a9cf1c9099016c59",
+			"f6eff7d0d1e6a6bddb67a9cf3690c002818a016450",
+			"fff7fbece2f0d0d1e6a6bddb67a9cf3690c002818a016450",
+			"fff7fbece2f0d0d1e6a6bddb67a9cf3690c002818a016c59014636"),
+		brewer("PuRd", Sequential,
+			"e7e1efc994c7dd1c77",
+			"f1eef6d7b5d8df65b0ce1256",
+			"f1eef6d7b5d8df65b0dd1c77980043",
+			"f1eef6d4b9dac994c7df65b0dd1c77980043",
+			"f1eef6d4b9dac994c7df65b0e7298ace125691003f",
+			"f7f4f9e7e1efd4b9dac994c7df65b0e7298ace125691003f",
+			"f7f4f9e7e1efd4b9dac994c7df65b0e7298ace125698004367001f"),
+		brewer("Purples", Sequential,
+			"efedf5bcbddc756bb1",
+			"f2f0f7cbc9e29e9ac86a51a3",
+			"f2f0f7cbc9e29e9ac8756bb154278f",
+			"f2f0f7dadaebbcbddc9e9ac8756bb154278f",
+			"f2f0f7dadaebbcbddc9e9ac8807dba6a51a34a1486",
+			"fcfbfdefedf5dadaebbcbddc9e9ac8807dba6a51a34a1486",
+			"fcfbfdefedf5dadaebbcbddc9e9ac8807dba6a51a354278f3f007d"),
+		brewer("RdPu", Sequential,
+			"fde0ddfa9fb5c51b8a",
+			"feebe2fbb4b9f768a1ae017e",
+			"feebe2fbb4b9f768a1c51b8a7a0177",
+			"feebe2fcc5c0fa9fb5f768a1c51b8a7a0177",
+			"feebe2fcc5c0fa9fb5f768a1dd3497ae017e7a0177",
+			"fff7f3fde0ddfcc5c0fa9fb5f768a1dd3497ae017e7a0177",
+			"fff7f3fde0ddfcc5c0fa9fb5f768a1dd3497ae017e7a017749006a"),
+		brewer("Reds", Sequential,
+			"fee0d2fc9272de2d26",
+			"fee5d9fcae91fb6a4acb181d",
+			"fee5d9fcae91fb6a4ade2d26a50f15",
+			"fee5d9fcbba1fc9272fb6a4ade2d26a50f15",
+			"fee5d9fcbba1fc9272fb6a4aef3b2ccb181d99000d",
+			"fff5f0fee0d2fcbba1fc9272fb6a4aef3b2ccb181d99000d",
+			"fff5f0fee0d2fcbba1fc9272fb6a4aef3b2ccb181da50f1567000d"),
+		brewer("YlGn", Sequential,
+			"f7fcb9addd8e31a354",
+			"ffffccc2e69978c679238443",
+			"ffffccc2e69978c67931a354006837",
+			"ffffccd9f0a3addd8e78c67931a354006837",
+			"ffffccd9f0a3addd8e78c67941ab5d238443005a32",
+			"ffffe5f7fcb9d9f0a3addd8e78c67941ab5d238443005a32",
+			"ffffe5f7fcb9d9f0a3addd8e78c67941ab5d238443006837004529"),
+		brewer("YlGnBu", Sequential,
+			"edf8b17fcdbb2c7fb8",
+			"ffffcca1dab441b6c4225ea8",
+			"ffffcca1dab441b6c42c7fb8253494",
+			"ffffccc7e9b47fcdbb41b6c42c7fb8253494",
+			"ffffccc7e9b47fcdbb41b6c41d91c0225ea80c2c84",
+			"ffffd9edf8b1c7e9b47fcdbb41b6c41d91c0225ea80c2c84",
+			"ffffd9edf8b1c7e9b47fcdbb41b6c41d91c0225ea8253494081d58"),
+		brewer("YlOrBr", Sequential,
+			"fff7bcfec44fd95f0e",
+			"ffffd4fed98efe9929cc4c02",
+			"ffffd4fed98efe9929d95f0e993404",
+			"ffffd4fee391fec44ffe9929d95f0e993404",
+			"ffffd4fee391fec44ffe9929ec7014cc4c028c2d04",
+			"ffffe5fff7bcfee391fec44ffe9929ec7014cc4c028c2d04",
+			"ffffe5fff7bcfee391fec44ffe9929ec7014cc4c02993404662506"),
+		brewer("YlOrRd", Sequential,
+			"ffeda0feb24cf03b20",
+			"ffffb2fecc5cfd8d3ce31a1c",
+			"ffffb2fecc5cfd8d3cf03b20bd0026",
+			"ffffb2fed976feb24cfd8d3cf03b20bd0026",
+			"ffffb2fed976feb24cfd8d3cfc4e2ae31a1cb10026",
+			"ffffccffeda0fed976feb24cfd8d3cfc4e2ae31a1cb10026",
+			"ffffccffeda0fed976feb24cfd8d3cfc4e2ae31a1cbd0026800026"),
+
+		// Diverging, 3 to 11 classes
+		brewer("BrBG", Diverging,
+			"d8b365f5f5f55ab4ac",
+			"a6611adfc27d80cdc1018571",
+			"a6611adfc27df5f5f580cdc1018571",
+			"8c510ad8b365f6e8c3c7eae55ab4ac01665e",
+			"8c510ad8b365f6e8c3f5f5f5c7eae55ab4ac01665e",
+			"8c510abf812ddfc27df6e8c3c7eae580cdc135978f01665e",
+			"8c510abf812ddfc27df6e8c3f5f5f5c7eae580cdc135978f01665e",
+			"5430058c510abf812ddfc27df6e8c3c7eae580cdc135978f01665e003c30",
+			"5430058c510abf812ddfc27df6e8c3f5f5f5c7eae580cdc135978f01665e003c30"),
+		brewer("PRGn", Diverging,
+			"af8dc3f7f7f77fbf7b",
+			"7b3294c2a5cfa6dba0008837",
+			"7b3294c2a5cff7f7f7a6dba0008837",
+			"762a83af8dc3e7d4e8d9f0d37fbf7b1b7837",
+			"762a83af8dc3e7d4e8f7f7f7d9f0d37fbf7b1b7837",
+			"762a839970abc2a5cfe7d4e8d9f0d3a6dba05aae611b7837",
+			"762a839970abc2a5cfe7d4e8f7f7f7d9f0d3a6dba05aae611b7837",
+			"40004b762a839970abc2a5cfe7d4e8d9f0d3a6dba05aae611b783700441b",
+			"40004b762a839970abc2a5cfe7d4e8f7f7f7d9f0d3a6dba05aae611b783700441b"),
+		brewer("PiYG", Diverging,
+			"e9a3c9f7f7f7a1d76a",
+			"d01c8bf1b6dab8e1864dac26",
+			"d01c8bf1b6daf7f7f7b8e1864dac26",
+			"c51b7de9a3c9fde0efe6f5d0a1d76a4d9221",
+			"c51b7de9a3c9fde0eff7f7f7e6f5d0a1d76a4d9221",
+			"c51b7dde77aef1b6dafde0efe6f5d0b8e1867fbc414d9221",
+			"c51b7dde77aef1b6dafde0eff7f7f7e6f5d0b8e1867fbc414d9221",
+			"8e0152c51b7dde77aef1b6dafde0efe6f5d0b8e1867fbc414d9221276419",
+			"8e0152c51b7dde77aef1b6dafde0eff7f7f7e6f5d0b8e1867fbc414d9221276419"),
+		brewer("PuOr", Diverging,
+			"f1a340f7f7f7998ec3",
+			"e66101fdb863b2abd25e3c99",
+			"e66101fdb863f7f7f7b2abd25e3c99",
+			"b35806f1a340fee0b6d8daeb998ec3542788",
+			"b35806f1a340fee0b6f7f7f7d8daeb998ec3542788",
+			"b35806e08214fdb863fee0b6d8daebb2abd28073ac542788",
+			"b35806e08214fdb863fee0b6f7f7f7d8daebb2abd28073ac542788",
+			"7f3b08b35806e08214fdb863fee0b6d8daebb2abd28073ac5427882d004b",
+			"7f3b08b35806e08214fdb863fee0b6f7f7f7d8daebb2abd28073ac5427882d004b"),
+		brewer("RdBu", Diverging,
+			"ef8a62f7f7f767a9cf",
+			"ca0020f4a58292c5de0571b0",
+			"ca0020f4a582f7f7f792c5de0571b0",
+			"b2182bef8a62fddbc7d1e5f067a9cf2166ac",
+			"b2182bef8a62fddbc7f7f7f7d1e5f067a9cf2166ac",
+			"b2182bd6604df4a582fddbc7d1e5f092c5de4393c32166ac",
+			"b2182bd6604df4a582fddbc7f7f7f7d1e5f092c5de4393c32166ac",
+			"67001fb2182bd6604df4a582fddbc7d1e5f092c5de4393c32166ac053061",
+			"67001fb2182bd6604df4a582fddbc7f7f7f7d1e5f092c5de4393c32166ac053061"),
+		brewer("RdGy", Diverging,
+			"ef8a62ffffff999999",
+			"ca0020f4a582bababa404040",
+			"ca0020f4a582ffffffbababa404040",
+			"b2182bef8a62fddbc7e0e0e09999994d4d4d",
+			"b2182bef8a62fddbc7ffffffe0e0e09999994d4d4d",
+			"b2182bd6604df4a582fddbc7e0e0e0bababa8787874d4d4d",
+			"b2182bd6604df4a582fddbc7ffffffe0e0e0bababa8787874d4d4d",
+			"67001fb2182bd6604df4a582fddbc7e0e0e0bababa8787874d4d4d1a1a1a",
+			"67001fb2182bd6604df4a582fddbc7ffffffe0e0e0bababa8787874d4d4d1a1a1a"),
+		brewer("RdYlBu", Diverging,
+			"fc8d59ffffbf91bfdb",
+			"d7191cfdae61abd9e92c7bb6",
+			"d7191cfdae61ffffbfabd9e92c7bb6",
+			"d73027fc8d59fee090e0f3f891bfdb4575b4",
+			"d73027fc8d59fee090ffffbfe0f3f891bfdb4575b4",
+			"d73027f46d43fdae61fee090e0f3f8abd9e974add14575b4",
+			"d73027f46d43fdae61fee090ffffbfe0f3f8abd9e974add14575b4",
+			"a50026d73027f46d43fdae61fee090e0f3f8abd9e974add14575b4313695",
+			"a50026d73027f46d43fdae61fee090ffffbfe0f3f8abd9e974add14575b4313695"),
+		brewer("RdYlGn", Diverging,
+			"fc8d59ffffbf91cf60",
+			"d7191cfdae61a6d96a1a9641",
+			"d7191cfdae61ffffbfa6d96a1a9641",
+			"d73027fc8d59fee08bd9ef8b91cf601a9850",
+			"d73027fc8d59fee08bffffbfd9ef8b91cf601a9850",
+			"d73027f46d43fdae61fee08bd9ef8ba6d96a66bd631a9850",
+			"d73027f46d43fdae61fee08bffffbfd9ef8ba6d96a66bd631a9850",
+			"a50026d73027f46d43fdae61fee08bd9ef8ba6d96a66bd631a9850006837",
+			"a50026d73027f46d43fdae61fee08bffffbfd9ef8ba6d96a66bd631a9850006837"),
+		brewer("Spectral", Diverging,
+			"fc8d59ffffbf99d594",
+			"d7191cfdae61abdda42b83ba",
+			"d7191cfdae61ffffbfabdda42b83ba",
+			"d53e4ffc8d59fee08be6f59899d5943288bd",
+			"d53e4ffc8d59fee08bffffbfe6f59899d5943288bd",
+			"d53e4ff46d43fdae61fee08be6f598abdda466c2a53288bd",
+			"d53e4ff46d43fdae61fee08bffffbfe6f598abdda466c2a53288bd",
+			"9e0142d53e4ff46d43fdae61fee08be6f598abdda466c2a53288bd5e4fa2",
+			"9e0142d53e4ff46d43fdae61fee08bffffbfe6f598abdda466c2a53288bd5e4fa2"),
+
+		// Qualitative
+		ramp("Accent", Qualitative, "7fc97f", "beaed4", "fdc086", "ffff99", "386cb0", "f0027f", "bf5b17", "666666"),
+		ramp("Dark2", Qualitative, "1b9e77", "d95f02", "7570b3", "e7298a", "66a61e", "e6ab02", "a6761d", "666666"),
+		ramp("Paired", Qualitative, "a6cee3", "1f78b4", "b2df8a", "33a02c", "fb9a99", "e31a1c", "fdbf6f", "ff7f00", "cab2d6", "6a3d9a", "ffff99", "b15928"),
+		ramp("Pastel1", Qualitative, "fbb4ae", "b3cde3", "ccebc5", "decbe4", "fed9a6", "ffffcc", "e5d8bd", "fddaec", "f2f2f2"),
+		ramp("Pastel2", Qualitative, "b3e2cd", "fdcdac", "cbd5e8", "f4cae4", "e6f5c9", "fff2ae", "f1e2cc", "cccccc"),
+		ramp("Set1", Qualitative, "e41a1c", "377eb8", "4daf4a", "984ea3", "ff7f00", "ffff33", "a65628", "f781bf", "999999"),
+		ramp("Set2", Qualitative, "66c2a5", "fc8d62", "8da0cb", "e78ac3", "a6d854", "ffd92f", "e5c494", "b3b3b3"),
+		ramp("Set3", Qualitative, "8dd3c7", "ffffb3", "bebada", "fb8072", "80b1d3", "fdb462", "b3de69", "fccde5", "d9d9d9", "bc80bd", "ccebc5", "ffed6f"),
+	} {
+		BrewerPalettes[r.Name] = r
+	}
+}
+
+// brewer constructs a ColorBrewer ramp from its n-class sets, starting
+// with 3 classes, each written as concatenated hex colors. The largest
+// set is the ramp.
+func brewer(name string, kind PaletteKind, sets ...string) ColorRamp {
+	r := ColorRamp{Name: name, Kind: kind, Classes: make(map[int][]color.Color)}
+	for _, set := range sets {
+		colors := make([]color.Color, len(set)/6)
+		for i := range colors {
+			colors[i] = String2Color("#" + set[6*i:6*i+6])
+		}
+		r.Classes[len(colors)] = colors
+		r.Values = colors
+	}
+	return r
+}
+
+// Brewer returns the ColorBrewer palette name and whether it exists.
+func Brewer(name string) (ColorRamp, bool) {
+	r, ok := BrewerPalettes[name]
+	return r, ok
+}
+
+// -------------------------------------------------------------------------
+// Interpolation of colors
+
+// sample returns n colors evenly spread over the continuous palette p.
+func sample(p Palette, n int) []color.Color {
+	colors := make([]color.Color, n)
+	for i := range colors {
+		t := 0.5
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+		colors[i] = p.Color(t)
+	}
+	return colors
+}
+
+func clamp01(t float64) float64 {
+	if t < 0 || math.IsNaN(t) {
+		return 0
+	} else if t > 1 {
+		return 1
+	}
+	return t
+}
+
+// interpolate returns the color a fraction t between a and b in space.
+func interpolate(a, b color.Color, t float64, space ColorSpace) color.Color {
+	ra, ga, ba, aa := nrgba(a)
+	rb, gb, bb, ab := nrgba(b)
+	alpha := aa + t*(ab-aa)
+	if space == RGBSpace {
+		return toNRGBA(ra+t*(rb-ra), ga+t*(gb-ga), ba+t*(bb-ba), alpha)
+	}
+
+	l1, a1, b1 := rgbToLab(ra, ga, ba)
+	l2, a2, b2 := rgbToLab(rb, gb, bb)
+	if space == LabSpace {
+		r, g, b := labToRGB(l1+t*(l2-l1), a1+t*(a2-a1), b1+t*(b2-b1))
+		return toNRGBA(r, g, b, alpha)
+	}
+
+	// HCL: Interpolate hue along the shorter arc. Achromatic colors
+	// have no meaningful hue and take the hue of the other color.
+	c1, h1 := math.Hypot(a1, b1), math.Atan2(b1, a1)
+	c2, h2 := math.Hypot(a2, b2), math.Atan2(b2, a2)
+	if c1 < 1e-6 {
+		h1 = h2
+	} else if c2 < 1e-6 {
+		h2 = h1
+	}
+	dh := h2 - h1
+	if dh > math.Pi {
+		dh -= 2 * math.Pi
+	} else if dh < -math.Pi {
+		dh += 2 * math.Pi
+	}
+	c, h := c1+t*(c2-c1), h1+t*dh
+	r, g, bl := labToRGB(l1+t*(l2-l1), c*math.Cos(h), c*math.Sin(h))
+	return toNRGBA(r, g, bl, alpha)
+}
+
+// nrgba returns the non-alpha-premultiplied components of c in [0,1].
+func nrgba(c color.Color) (r, g, b, a float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return float64(n.R) / 255, float64(n.G) / 255, float64(n.B) / 255, float64(n.A) / 255
+}
+
+func toNRGBA(r, g, b, a float64) color.NRGBA {
+	c := func(x float64) uint8 { return uint8(math.Floor(255*clamp01(x) + 0.5)) }
+	return color.NRGBA{c(r), c(g), c(b), c(a)}
+}
+
+// D65 white point.
+const whiteX, whiteY, whiteZ = 0.95047, 1.0, 1.08883
+
+// rgbToLab converts sRGB components in [0,1] to CIE L*a*b*.
+func rgbToLab(r, g, b float64) (l, aa, bb float64) {
+	lin := func(c float64) float64 {
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	r, g, b = lin(r), lin(g), lin(b)
+	x := 0.4124564*r + 0.3575761*g + 0.1804375*b
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := 0.0193339*r + 0.1191920*g + 0.9503041*b
+
+	f := func(t float64) float64 {
+		const d = 6.0 / 29
+		if t > d*d*d {
+			return math.Cbrt(t)
+		}
+		return t/(3*d*d) + 4.0/29
+	}
+	fx, fy, fz := f(x/whiteX), f(y/whiteY), f(z/whiteZ)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// labToRGB converts CIE L*a*b* to sRGB components (not clamped).
+func labToRGB(l, aa, bb float64) (r, g, b float64) {
+	finv := func(t float64) float64 {
+		const d = 6.0 / 29
+		if t > d {
+			return t * t * t
+		}
+		return 3 * d * d * (t - 4.0/29)
+	}
+	fy := (l + 16) / 116
+	x := whiteX * finv(fy+aa/500)
+	y := whiteY * finv(fy)
+	z := whiteZ * finv(fy-bb/200)
+
+	gamma := func(c float64) float64 {
+		if c <= 0.0031308 {
+			return 12.92 * c
+		}
+		return 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	r = gamma(3.2404542*x - 1.5371385*y - 0.4985314*z)
+	g = gamma(-0.9692660*x + 1.8760108*y + 0.0415560*z)
+	b = gamma(0.0556434*x - 0.2040259*y + 1.0572252*z)
+	return r, g, b
+}
@@ -0,0 +1,126 @@
+package plot
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLabRoundTrip(t *testing.T) {
+	for _, hex := range []string{"#000000", "#ffffff", "#ff0000", "#00ff00", "#0000ff", "#336699", "#fde725"} {
+		r, g, b, _ := nrgba(String2Color(hex))
+		l, aa, bb := rgbToLab(r, g, b)
+		r, g, b = labToRGB(l, aa, bb)
+		if got := Color2String(toNRGBA(r, g, b, 1)); got != hex {
+			t.Errorf("Lab round trip of %s gave %s", hex, got)
+		}
+	}
+
+	// Reference values for sRGB red.
+	l, aa, bb := rgbToLab(1, 0, 0)
+	if math.Abs(l-53.24) > 0.05 || math.Abs(aa-80.09) > 0.05 || math.Abs(bb-67.20) > 0.05 {
+		t.Errorf("Lab of red is %.2f %.2f %.2f", l, aa, bb)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	black, white := color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	g := Gradient{Low: black, High: white}
+	if got := Color2String(g.Color(0)); got != "#000000" {
+		t.Errorf("Got %s at 0", got)
+	}
+	if got := Color2String(g.Color(1)); got != "#ffffff" {
+		t.Errorf("Got %s at 1", got)
+	}
+	// L* = 50 is a medium gray which is darker than RGB 0x80.
+	if got := Color2String(g.Color(0.5)); got != "#777777" {
+		t.Errorf("Got %s at 0.5", got)
+	}
+	g.Space = RGBSpace
+	if got := Color2String(g.Color(0.5)); got != "#808080" {
+		t.Errorf("Got %s at 0.5 in RGB", got)
+	}
+
+	// HCL keeps the chroma between red and blue.
+	red, blue := String2Color("#ff0000"), String2Color("#0000ff")
+	_, a1, b1 := rgbToLab(nrgbaNoAlpha(interpolate(red, blue, 0.5, LabSpace)))
+	_, a2, b2 := rgbToLab(nrgbaNoAlpha(interpolate(red, blue, 0.5, HCLSpace)))
+	if math.Hypot(a2, b2) <= math.Hypot(a1, b1) {
+		t.Errorf("HCL midpoint not more saturated than Lab midpoint")
+	}
+
+	// The midpoint of a diverging gradient is mapped to Mid.
+	s := NewScale("fill", "f", Float)
+	s.Palette = Gradient{Low: black, Mid: String2Color("#ff0000"), High: white, Midpoint: 10}
+	s.DomainMin, s.DomainMax = 0, 40
+	s.FinalizeContinous()
+	if got := Color2String(s.Color(10)); got != "#ff0000" {
+		t.Errorf("Got %s at midpoint", got)
+	}
+	if got := Color2String(s.Color(40)); got != "#ffffff" {
+		t.Errorf("Got %s at max", got)
+	}
+}
+
+func nrgbaNoAlpha(c color.Color) (r, g, b float64) {
+	r, g, b, _ = nrgba(c)
+	return r, g, b
+}
+
+func TestColorRamps(t *testing.T) {
+	set1, ok := Brewer("Set1")
+	if !ok {
+		t.Fatalf("No Set1 palette")
+	}
+	colors := set1.Colors(11)
+	if got := Color2String(colors[0]); got != "#e41a1c" {
+		t.Errorf("Got %s for first Set1 color", got)
+	}
+	if Color2String(colors[9]) != Color2String(colors[0]) {
+		t.Errorf("Qualitative colors should repeat")
+	}
+
+	// Discrete colors are the published n-class sets.
+	hex := func(colors []color.Color) string {
+		s := []string{}
+		for _, c := range colors {
+			s = append(s, Color2String(c))
+		}
+		return strings.Join(s, " ")
+	}
+	for _, tc := range []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"Blues", 3, "#deebf7 #9ecae1 #3182bd"},
+		{"Blues", 9, "#f7fbff #deebf7 #c6dbef #9ecae1 #6baed6 #4292c6 #2171b5 #08519c #08306b"},
+		{"RdBu", 5, "#ca0020 #f4a582 #f7f7f7 #92c5de #0571b0"},
+		{"Set2", 3, "#66c2a5 #fc8d62 #8da0cb"},
+		{"Dark2", 4, "#1b9e77 #d95f02 #7570b3 #e7298a"},
+		{"PuBuGn", 3, "#ece2f0 #a6bddb #1c9099"},
+		{"PRGn", 5, "#7b3294 #c2a5cf #f7f7f7 #a6dba0 #008837"},
+		{"Pastel2", 3, "#b3e2cd #fdcdac #cbd5e8"},
+	} {
+		r, _ := Brewer(tc.name)
+		if got := hex(r.Colors(tc.n)); got != tc.want {
+			t.Errorf("%s %d: got %s, want %s", tc.name, tc.n, got, tc.want)
+		}
+	}
+
+	// More colors than published are interpolated.
+	blues, _ := Brewer("Blues")
+	if got := blues.Colors(12); Color2String(got[0]) != "#f7fbff" || Color2String(got[11]) != "#08306b" {
+		t.Errorf("Got %s ... %s", Color2String(got[0]), Color2String(got[11]))
+	}
+	if got := Color2String(Viridis.Color(0.5)); got != "#21908c" {
+		t.Errorf("Got %s for viridis(0.5)", got)
+	}
+	if n := len(BrewerPalettes); n != 35 {
+		t.Errorf("Got %d Brewer palettes, want 35", n)
+	}
+	if _, ok := Brewer("no-such-palette"); ok {
+		t.Errorf("Unknown palette reported as existing")
+	}
+}
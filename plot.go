@@ -30,6 +30,19 @@ type Plot struct {
 	// Faceting describes the used Faceting.
 	Faceting Faceting
 
+	// MarginalX and MarginalY control the display of the marginal
+	// distributions of x (on top of the panels) and y (right of the
+	// panels). Nil means no marginal distribution.
+	MarginalX, MarginalY *Marginal
+
+	// Coord is the coordinate system. Nil means CoordCartesian.
+	Coord Coord
+
+	// Aspect fixes the aspect ratio (height/width) of the panels if
+	// positive. It takes precedence over the aspect ratio of Coord.
+	// Panels with a fixed aspect ratio are centered in the plot.
+	Aspect float64
+
 	// Mapping describes how fieleds in data are mapped to Aesthetics.
 	Aes AesMapping
 
@@ -70,6 +83,9 @@ type Plot struct {
 // maps fields in data to plot aesthetics.
 func NewPlot(data interface{}, aesthetics AesMapping) (*Plot, error) {
 	pool := NewStringPool()
+	if df, ok := data.(*DataFrame); ok && df.Pool != nil {
+		pool = df.Pool // keep the strings in df valid
+	}
 	df, err := NewDataFrameFrom(data, pool)
 	if err != nil {
 		return nil, err
@@ -102,13 +118,31 @@ func (plot *Plot) Compute() {
 	plot.CreatePanels()
 
 	for r := range plot.Panels {
-		for c := range plot.Panels[r] {
-			panel := plot.Panels[r][c]
+		for _, panel := range plot.Panels[r] {
+			if panel.blank {
+				continue
+			}
 
 			// Prepare data: map aestetics, add scales, clean data frame and
 			// apply scale transformations. Mapped scales are pre-trained.
 			// Step 2
 			panel.PrepareData()
+			if plot.MarginalX != nil || plot.MarginalY != nil {
+				panel.collectMarginals()
+			}
+		}
+	}
+
+	// Geoms like GeomHistogram provide the stat of layers without one.
+	// Step 3 preparation
+	plot.setDefaultStats()
+
+	for r := range plot.Panels {
+		for c := range plot.Panels[r] {
+			panel := plot.Panels[r][c]
+			if panel.blank {
+				continue
+			}
 
 			// The second step: Compute statistics.
 			// If a layer has a statistical transform: Apply this transformation
@@ -131,6 +165,9 @@ func (plot *Plot) Compute() {
 
 	for r := range plot.Panels {
 		for _, panel := range plot.Panels[r] {
+			if panel.blank {
+				continue
+			}
 
 			// Finalize scales: Setup remaining fields.
 			// This can be done only after each panel completed
@@ -143,6 +180,9 @@ func (plot *Plot) Compute() {
 
 	for r := range plot.Panels {
 		for _, panel := range plot.Panels[r] {
+			if panel.blank {
+				continue
+			}
 			// Render the fundamental Geoms to Grobs using scales.
 			// Step 7
 			panel.RenderGeoms()
@@ -168,20 +208,25 @@ func (plot *Plot) DumpTo(canvas vg.Canvas, width, height vg.Length) {
 	plot.Layout(canvas, width, height)
 
 	// Actual drawing of the general stuff.
-	for _, element := range []string{"Title", "X-Label", "Y-Label", "Guides"} {
+	for _, element := range []string{"Title", "X-Label", "Y-Label", "X2-Label", "Y2-Label", "Guides"} {
 		if grob, ok := plot.Grobs[element]; ok {
 			grob.Draw(plot.Viewports[element])
 		}
 	}
 
-	// Drawing of the individual panels.
-	showX, showY := false, false
+	// Drawing of the individual panels. Axes are drawn on the outer
+	// panels only unless each wrapped panel has its own scale.
+	freeX, freeY := plot.scaleSharing("x") == "per-panel", plot.scaleSharing("y") == "per-panel"
 	for r := range plot.Panels {
-		showX = r == 0
 		for c, panel := range plot.Panels[r] {
-			showY = c == 0
+			if panel.blank {
+				continue
+			}
+			showX := r == 0 || plot.Panels[r-1][c].blank || freeX
+			showY := c == 0 || freeY
 			panelId := fmt.Sprintf("Panel-%d,%d", r, c)
 			panel.Draw(plot.Viewports[panelId], showX, showY)
+			panel.drawSecondaryAxes(r == len(plot.Panels)-1, c == len(plot.Panels[r])-1)
 		}
 	}
 }
@@ -243,6 +288,11 @@ type Layer struct {
 	// transform to the input fields used by the geom.
 	GeomMapping AesMapping
 
+	// HideLegend keeps the keys of this layer out of all legends.
+	// A legend is not drawn at all if all layers mapping its
+	// aesthetic hide their keys.
+	HideLegend bool
+
 	// The fundamental geoms to draw.
 	Fundamentals []Fundamental
 
@@ -285,39 +335,107 @@ type Panel struct {
 	// TODO: a bit ugly...
 	Tvp, Rvp, Bvp, Lvp Viewport
 	Tgr, Rgr, Bgr, Lgr []Grob
+
+	// Grobs of the marginal distributions. They are drawn in the part
+	// of Tvp and Rvp next to the panel, the strips get the rest.
+	Tmg, Rmg []Grob
+
+	// marginals are the values shown in the marginal distributions,
+	// collected from the prepared layer data.
+	marginals map[string][]float64
+
+	// blank panels fill the incomplete last row of wrapped facets.
+	// They have neither data nor layers and are not drawn.
+	blank bool
+
+	// total panels show the totals of a row and/or column.
+	total bool
 }
 
 // Facetting describes the facetting to use. The zero value indicates
 // no facetting.
 type Faceting struct {
 	// Columns and Rows are the faceting specification. Each may be a
-	// field in the Data. An empty string means no faceting in this
-	// dimension.
+	// field in the Data or several fields separated by commas, e.g.
+	// "Cut,Color", giving one facet per combination of their levels.
+	// An empty string means no faceting in this dimension.
 	Columns, Rows string
 
-	// Totals controlls display of row and column totals.
-	Totals bool // TODO: fancier control needed
+	// Totals adds margin panels showing the data of all facets: A total
+	// row below the panels containing all data of each column, a total
+	// column right of the panels containing all data of each row and
+	// the grand total. Totals are added only in a dimension which is
+	// faceted. The total panels are drawn with the TotalBG and
+	// TotalStrip of the Theme.
+	Totals bool
+
+	// TotalsIn restricts the totals to the total row ("row") or to the
+	// total column ("col"). The empty string means both. TotalsIn is
+	// ignored unless Totals is set.
+	TotalsIn string
+
+	// TotalLabel is the strip label of the total row and column.
+	// The empty string means "-all-".
+	TotalLabel string
 
 	// FreeScale determines which scales are free i.e. not shared
 	// between rows and/or columns:
-	//     ""    all scales shared
-	//     "x"   x is free (might be different on each column)
-	//     "y"   y is free (might be different on each row)
-	//     "xy"  both x and y are free
+	//     ""      all scales shared
+	//     "x"     x is free (might be different on each column)
+	//     "y"     y is free (might be different on each row)
+	//     "xy"    both x and y are free
+	//     "free"  each panel has its own x and y scale
 	// (This is different from ggplot2. Here each row will share a common
 	// x-sclae and each row will share a common y-scale.)
+	// Panels with their own scale draw their own axes. FreeScale is
+	// ignored for CoordFixed and CoordMap as all panels must have the
+	// same aspect ratio.
 	FreeScale string
 
 	// FreeSpace determines which dimension of a panel has fixed
-	// size and which are free on a per row and/or column base.
-	// Arguments like FreeScale.  BUG: Currently not implemented.
+	// size and which are free on a per row and/or column base:
+	// With "x" the width of each column is proportional to the range
+	// of its free x scale, with "y" the height of each row to the range
+	// of its free y scale and "xy" does both. FreeSpace is ignored for
+	// scales which are not free per column respectively row and for
+	// panels with a fixed aspect ratio.
 	FreeSpace string
 
+	// Wrap lays out one panel for each combination of the levels of
+	// the fields in Wrap. The panels are placed row by row, starting
+	// top left, in a grid of NCol columns (0 means about square). Each
+	// panel gets its own strip. Columns and Rows are ignored if Wrap
+	// is set. FreeScale works per panel for wrapped facets: Each
+	// panel gets its own x and/or y scale and axis.
+	Wrap []string
+	NCol int
+
+	// Labeller produces the strip labels from the levels of the faceting
+	// fields. Nil means LabelValue.
+	Labeller Labeller
+
+	// MultiLine puts the label of each faceting field on its own line
+	// in the strip instead of joining them with ", ".
+	MultiLine bool
+
+	// ColStripPos places the strips of the columns "top" (default) or
+	// "bottom" of the panels, RowStripPos the strips of the rows "right"
+	// (default) or "left". "none" switches the strips off. Wrapped
+	// panels have their strips on top unless switched off.
+	ColStripPos, RowStripPos string
+
 	// ColStrips and RowStrips contain the strip labels.
 	// TODO: decide how to set manually.
 	ColStrips, RowStrips []string
+
+	// WrapStrips contains the strip labels of the wrapped panels in
+	// the order they are placed.
+	WrapStrips []string
 }
 
+// wrapped reports whether the panels are wrapped.
+func (f Faceting) wrapped() bool { return len(f.Wrap) > 0 }
+
 // Fundamental is a simple geometrical object.
 type Fundamental struct {
 	// Geom is one if the few fundamental Geoms.
@@ -372,10 +490,16 @@ func (panel *Panel) PrepareData() {
 			delete(layer.Data.Columns, f)
 		}
 
-		// Rename mapped fields to their aestethic name
+		// Rename mapped fields to their aestethic name. A field
+		// mapped to several aesthetics (e.g. color and shape) is
+		// copied for each of them.
+		columns := make(map[string]Field, len(aes))
 		for a, f := range aes {
-			layer.Data.Rename(f, a)
+			if field, ok := layer.Data.Columns[f]; ok {
+				columns[a] = field.Copy()
+			}
 		}
+		layer.Data.Columns = columns
 
 		// Step 2b
 		layer.Panel.Plot.PrepareScales(layer.Data, aes)
@@ -392,7 +516,7 @@ func (panel *Panel) PrepareData() {
 
 // PrepareScales makes sure plot contains all scales needed for the
 // aesthetics in aes, the data is scale transformed if requested by the
-// scale and the scales are pre-trained.
+// scale and data outside the limits of the scale is handled.
 //
 // Only continuous, non-time-scales can be transformed.
 //
@@ -428,18 +552,31 @@ func (plot *Plot) PrepareScales(data *DataFrame, aes AesMapping) {
 		case plotOk && !panelOk:
 			// Must be a user set scale on plot; just distribute.
 			fmt.Printf("    PrepareScales() %q distributed from plot\n", a)
+			if plotScale.Time && plotScale.Origin == 0 {
+				plotScale.Origin = data.Columns[a].Origin
+			}
 			plot.distributeScale(plotScale, a)
 		case !plotOk && !panelOk:
 			// Auto-generated scale, first occurence of this scale.
 			name, typ := aes[a], data.Columns[a].Type
 			fmt.Printf("    PrepareScales() %q create new and distribute\n", a)
 			plotScale = NewScale(a, name, typ)
+			plotScale.Origin = data.Columns[a].Origin
 			plot.Scales[a] = plotScale
 			plot.distributeScale(plotScale, a)
 		case !plotOk && panelOk:
 			panic("This should never happen.")
 		}
 
+		// Time data is stored relative to the origin of its field:
+		// Make it relative to the origin of the scale.
+		if field := data.Columns[a]; plotScale.Time && field.Type == Time && field.Origin != plotScale.Origin {
+			delta := float64(field.Origin - plotScale.Origin)
+			field.Apply(func(x float64) float64 { return x + delta })
+			field.Origin = plotScale.Origin
+			data.Columns[a] = field
+		}
+
 		// Transform data if scale request such a transform.
 		if plotScale.Transform != nil && plotScale.Transform != &IdentityScale {
 			// TODO: This test should happen much earlier.
@@ -447,24 +584,20 @@ func (plot *Plot) PrepareScales(data *DataFrame, aes AesMapping) {
 				plot.Warnf("Cannot transform discrete or time scale %s %q",
 					plotScale.Aesthetic, plotScale.Name)
 				plotScale.Transform = &IdentityScale
-			} else {
-				field := data.Columns[a]
-				field.Apply(plotScale.Transform.Trans)
-				fmt.Printf("    Transformed data on scale %v", a)
+			} else if n := plotScale.transform(data, a); n > 0 {
+				plot.Warnf("Removed %d rows outside the domain of transformation %s of scale %s %q",
+					n, plotScale.Transform.Name, plotScale.Aesthetic, plotScale.Name)
 			}
 		}
 
-		/***********************
-				// Pre-train scales on all panels
-				// TODO: This is wrong, or?  Training is per panel!
-				for r := range plot.Panels {
-					for c := range plot.Panels[r] {
-						scale := plot.Panels[r][c].Scales[a]
-						scale.Train(data.Columns[a])
-						fmt.Printf("After training Scale %s on panel %s: [ %.2f, %.2f ]\n", a, plot.Panels[r][c].Name, scale.DomainMin, scale.DomainMax)
-					}
-				}
-		                ***********************/
+		// Handle data outside the limits of the scale.
+		if n := plotScale.oob(data, a); n > 0 {
+			plot.Warnf("Removed %d rows outside the limits of scale %s %q",
+				n, plotScale.Aesthetic, plotScale.Name)
+		}
+
+		// Scales are not trained here but by the callers on the scales
+		// of their panel: A free scale sees the data of its panels only.
 	}
 
 }
@@ -498,6 +631,14 @@ func (plot *Plot) distributeScale(scale *Scale, aes string) {
 				plot.Panels[r][c].Scales[aes] = &cpy
 			}
 		}
+	case "per-panel":
+		// Each panel gets its own copy.
+		for r := range plot.Panels {
+			for c := range plot.Panels[r] {
+				cpy := *scale
+				plot.Panels[r][c].Scales[aes] = &cpy
+			}
+		}
 	default:
 		panic("Ooops " + plot.scaleSharing(aes))
 	}
@@ -512,10 +653,15 @@ func (plot *Plot) scaleSharing(aes string) string {
 
 	free := plot.Faceting.FreeScale
 
-	if free == "" {
+	if free == "" || plot.dataAspect() {
 		return "all-panels"
 	}
 
+	if free == "free" || plot.Faceting.wrapped() && strings.Contains(free, aes) {
+		// Wrapped panels are not aligned in rows or columns.
+		return "per-panel"
+	}
+
 	if aes == "x" && strings.Index(free, "x") != -1 {
 		// Scale for x axis, but x is 'free' i.e. each column may have
 		// its own x-scale, but this one is shared along the whole
@@ -533,6 +679,46 @@ func (plot *Plot) scaleSharing(aes string) string {
 // -------------------------------------------------------------------------
 // Step 3: Satistical Transformation
 
+// setDefaultStats sets the default stat of a StatGeom on the panel copies
+// of each plot layer without own Stat. The default stat is determined
+// once from the prepared data of the layer in all panels, so that e.g. the
+// histograms in all facets use the same bins. The plot layers stay
+// untouched.
+func (plot *Plot) setDefaultStats() {
+	for i, orig := range plot.Layers {
+		sg, ok := orig.Geom.(StatGeom)
+		if !ok || orig.Stat != nil {
+			continue
+		}
+		var layers []*Layer
+		var all *DataFrame
+		for r := range plot.Panels {
+			for _, panel := range plot.Panels[r] {
+				if panel.blank {
+					continue
+				}
+				layer := panel.Layers[i]
+				layers = append(layers, layer)
+				if all == nil {
+					all = layer.Data.Copy()
+				} else {
+					all.Append(layer.Data)
+				}
+			}
+		}
+		if all == nil {
+			continue
+		}
+		stat, mapping := sg.DefaultStat(all)
+		for _, layer := range layers {
+			layer.Stat = stat
+			if len(layer.StatMapping) == 0 {
+				layer.StatMapping = mapping
+			}
+		}
+	}
+}
+
 func (p *Panel) ComputeStatistics() {
 	fmt.Printf("Panel %q: ComputeStatistics()\n", p.Name)
 
@@ -541,10 +727,23 @@ func (p *Panel) ComputeStatistics() {
 	}
 }
 
+// orientBoxplot makes a StatBoxplot and a GeomBoxplot of layer agree on
+// their orientation: Both are horizontal if one of them is.
+func (layer *Layer) orientBoxplot() {
+	stat, ok := layer.Stat.(StatBoxplot)
+	geom, ok2 := layer.Geom.(GeomBoxplot)
+	if !ok || !ok2 || stat.Horizontal == geom.Horizontal {
+		return
+	}
+	stat.Horizontal, geom.Horizontal = true, true
+	layer.Stat, layer.Geom = stat, geom
+}
+
 // ComputeStatistics computes the statistical transform. Might be the identity.
 //
 // Step 3 in design.
 func (layer *Layer) ComputeStatistics() {
+	layer.orientBoxplot()
 	if layer.Stat == nil {
 		fmt.Printf("  Layer %q: ComputeStatistics() nil stat\n", layer.Name)
 		return // The identity statistical transformation.
@@ -749,8 +948,27 @@ func (layer *Layer) ConstructGeoms() {
 // Step 6: Prepare Scales
 func (p *Panel) FinalizeScales() {
 	fmt.Printf("Panel %q: FinalizeScales()\n", p.Name)
+	if polar, ok := p.Plot.coord().(CoordPolar); ok {
+		// Theta covers the full circle: Discrete levels are spaced
+		// evenly, continuous ranges are not expanded.
+		if theta := p.Scales[polar.thetaAes()]; theta != nil && !theta.Finalized {
+			theta.ExpandRel, theta.ExpandAbs = 0, 0
+			if theta.Discrete {
+				theta.ExpandAbs = 0.5
+			}
+		}
+	}
+	if z, ok := p.Plot.coord().(zoomer); ok {
+		for _, aes := range []string{"x", "y"} {
+			if scale := p.Scales[aes]; scale != nil && !scale.Finalized {
+				scale.zoom = scale.transformLimits(z.zoom(aes))
+			}
+		}
+	}
 	for _, scale := range p.Scales {
-		scale.Finalize(p.Plot.Pool)
+		if err := scale.Finalize(p.Plot.Pool); err != nil {
+			p.Plot.Warnf("%s", err)
+		}
 	}
 }
 
@@ -767,6 +985,7 @@ func (p *Panel) RenderGeoms() {
 			data := fund.Data
 			aes := fund.Geom.Aes(p.Plot)
 			grobs := fund.Geom.Render(p, data, aes)
+			grobs = transformGrobs(grobs, p.coord())
 			layer.Grobs = append(layer.Grobs, grobs...)
 		}
 	}
@@ -786,7 +1005,8 @@ func (plot *Plot) RenderVisuals() {
 		_, h := g.BoundingBox()
 		plot.renderInfo["Title.Height"] = h
 	}
-	if name := plot.Scales["x"].Name; name != "" {
+	horizontal, vertical := plot.axes()
+	if name := plot.Scales[horizontal].Name; name != "" {
 		style := MergeStyles(plot.Theme.Label, DefaultTheme.Label)
 		size := String2Float(style["size"], 0, 100)
 		g := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5,
@@ -795,7 +1015,7 @@ func (plot *Plot) RenderVisuals() {
 		_, h := g.BoundingBox()
 		plot.renderInfo["X-Label.Height"] = h
 	}
-	if name := plot.Scales["y"].Name; name != "" {
+	if name := plot.Scales[vertical].Name; name != "" {
 		style := MergeStyles(plot.Theme.Label, DefaultTheme.Label)
 		size := String2Float(style["size"], 0, 100)
 		g := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5, text: name,
@@ -804,52 +1024,82 @@ func (plot *Plot) RenderVisuals() {
 		plot.renderInfo["Y-Label.Width"] = w
 		plot.Grobs["Y-Label"] = g
 	}
+	if sx := plot.Scales[horizontal]; sx.Secondary != nil && sx.secondaryName() != "" {
+		style := MergeStyles(plot.Theme.Label, DefaultTheme.Label)
+		size := String2Float(style["size"], 0, 100)
+		g := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5,
+			text: sx.secondaryName(), size: size}
+		plot.Grobs["X2-Label"] = g
+		_, h := g.BoundingBox()
+		plot.renderInfo["X2-Label.Height"] = h
+	}
+	if sy := plot.Scales[vertical]; sy.Secondary != nil && sy.secondaryName() != "" {
+		style := MergeStyles(plot.Theme.Label, DefaultTheme.Label)
+		size := String2Float(style["size"], 0, 100)
+		g := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5,
+			text: sy.secondaryName(), angle: -math.Pi / 2, size: size}
+		w, _ := g.BoundingBox()
+		plot.renderInfo["Y2-Label.Width"] = w
+		plot.Grobs["Y2-Label"] = g
+	}
 
 	// Strips for facetted plots.
-	strip := MergeStyles(plot.Theme.Strip, DefaultTheme.Strip)
-	stripBG := String2Color(strip["fill"])
-	stripCol := String2Color(strip["color"])
-	stripSize := String2Float(strip["size"], 4, 100)
-	if len(plot.Faceting.RowStrips) > 0 {
-		ncols := len(plot.Panels[0])
+	nrows, ncols := len(plot.Panels), len(plot.Panels[0])
+	if len(plot.Faceting.RowStrips) > 0 && plot.Faceting.RowStripPos != "none" {
 		maxWidth := vg.Length(0)
-		for r := range plot.Panels {
-			strip := plot.Faceting.RowStrips[r]
-			// TDOO: Add border.
-			rect := GrobRect{xmin: 0, ymin: 0, xmax: 1, ymax: 1, fill: stripBG}
-			text := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5,
-				text: strip, angle: math.Pi / 2,
-				size: stripSize, color: stripCol}
-			plot.Panels[r][ncols-1].Rgr = []Grob{rect, text}
-			w, _ := text.BoundingBox()
+		for r, strip := range plot.Faceting.RowStrips {
+			// The first column is never a total column.
+			grobs, w := stripLines(strip, true, plot.stripStyle(plot.Panels[r][0].total))
+			if plot.Faceting.RowStripPos == "left" {
+				plot.Panels[r][0].Lgr = grobs
+			} else {
+				plot.Panels[r][ncols-1].Rgr = grobs
+			}
 			if w > maxWidth {
 				maxWidth = w
 			}
-			fmt.Printf("Strip %q %.1f %.1f\n", strip, w, maxWidth)
 		}
 		plot.renderInfo["Row-Strip.Width"] = maxWidth
 	}
-	if len(plot.Faceting.ColStrips) > 0 {
-		nrows := len(plot.Panels)
+	if len(plot.Faceting.ColStrips) > 0 && plot.Faceting.ColStripPos != "none" {
 		maxHeight := vg.Length(0)
-		for c := range plot.Panels[0] {
-			strip := plot.Faceting.ColStrips[c]
-			// TDOO: Add border.
-			rect := GrobRect{xmin: 0, ymin: 0, xmax: 1, ymax: 1, fill: stripBG}
-			text := GrobText{x: 0.5, y: 0.5, vjust: 0.5, hjust: 0.5,
-				text: strip, angle: 0,
-				size: stripSize, color: stripCol}
-			plot.Panels[nrows-1][c].Tgr = []Grob{rect, text}
-			_, h := text.BoundingBox()
+		for c, strip := range plot.Faceting.ColStrips {
+			// The top row is never a total row.
+			grobs, h := stripLines(strip, false, plot.stripStyle(plot.Panels[nrows-1][c].total))
+			if plot.Faceting.ColStripPos == "bottom" {
+				plot.Panels[0][c].Bgr = grobs
+			} else {
+				plot.Panels[nrows-1][c].Tgr = grobs
+			}
 			if h > maxHeight {
 				maxHeight = h
 			}
 		}
 		plot.renderInfo["Col-Strip.Height"] = maxHeight
 	}
+	if len(plot.Faceting.WrapStrips) > 0 && plot.Faceting.ColStripPos != "none" {
+		maxHeight := vg.Length(0)
+		for i, strip := range plot.Faceting.WrapStrips {
+			grobs, h := stripLines(strip, false, plot.Theme.Strip)
+			plot.wrapPanel(i).Tgr = grobs
+			if h > maxHeight {
+				maxHeight = h
+			}
+		}
+		plot.renderInfo["Col-Strip.Height"] = maxHeight
+	}
+	plot.renderMarginals()
 	plot.RenderGuides()
 }
 
+// stripStyle returns the style of the strips of normal or total facets.
+func (plot *Plot) stripStyle(total bool) AesMapping {
+	if total {
+		return MergeStyles(plot.Theme.TotalStrip, DefaultTheme.TotalStrip, plot.Theme.Strip)
+	}
+	return plot.Theme.Strip
+}
+
 func (plot *Plot) RenderGuides() {
 	maxWidth := vg.Length(0)
 	yCum := vg.Length(0)
@@ -860,10 +1110,17 @@ func (plot *Plot) RenderGuides() {
 			// X and y axes are draw on a per-panel base.
 			continue
 		}
+		if scale.Identity {
+			continue
+		}
 
 		fmt.Printf("%s\n", scale.String())
 
-		grobs, width, height := scale.Render()
+		keys, hidden := plot.legendKeys(aes, scale)
+		if hidden {
+			continue
+		}
+		grobs, width, height := scale.render(keys)
 		if width > maxWidth {
 			maxWidth = width
 		}
@@ -876,6 +1133,37 @@ func (plot *Plot) RenderGuides() {
 	plot.renderInfo["Guides.Width"] = maxWidth
 }
 
+// legendKeys returns the templates of the keys in the legend of the scale
+// for aes: One for each layer which maps aes, shows its keys and whose geom
+// can draw keys. The keys are drawn in the order of the layers, each in the
+// fixed style of its geom. The legend is hidden if all layers mapping aes
+// hide their keys.
+func (plot *Plot) legendKeys(aes string, scale *Scale) (keys []LegendKey, hidden bool) {
+	mapped, shown := 0, 0
+	for _, layer := range plot.Layers {
+		mapping := MergeAes(layer.GeomMapping, layer.StatMapping, layer.DataMapping, plot.Aes)
+		if _, ok := mapping[aes]; !ok {
+			continue
+		}
+		mapped++
+		if layer.HideLegend {
+			continue
+		}
+		shown++
+		geom, ok := layer.Geom.(KeyGeom)
+		if !ok {
+			continue
+		}
+		keys = append(keys, LegendKey{
+			Geom:  geom,
+			Style: layer.Geom.Aes(plot),
+			Aes:   aes,
+			Scale: scale,
+		})
+	}
+	return keys, mapped > 0 && shown == 0
+}
+
 // -------------------------------------------------------------------------
 // Panel creation
 
@@ -883,9 +1171,21 @@ func (plot *Plot) RenderGuides() {
 //
 // Not only p.Data is facetted but p.Layers also (if they contain own data).
 func (plot *Plot) CreatePanels() {
-	if plot.Faceting.Columns == "" && plot.Faceting.Rows == "" {
+	if plot.Faceting.FreeScale != "" && plot.dataAspect() {
+		plot.Warnf("Ignoring FreeScale %q: Coord %T needs shared x and y scales",
+			plot.Faceting.FreeScale, plot.coord())
+	}
+	switch {
+	case plot.Faceting.wrapped():
+		if plot.Faceting.Columns != "" || plot.Faceting.Rows != "" {
+			plot.Warnf("Ignoring Columns %q and Rows %q of wrapped facets",
+				plot.Faceting.Columns, plot.Faceting.Rows)
+			plot.Faceting.Columns, plot.Faceting.Rows = "", ""
+		}
+		plot.createWrapPanels()
+	case plot.Faceting.Columns == "" && plot.Faceting.Rows == "":
 		plot.createSinglePanel()
-	} else {
+	default:
 		plot.createGridPanels()
 	}
 }
@@ -901,137 +1201,163 @@ func (plot *Plot) createSinglePanel() {
 	}
 
 	plot.Panels = [][]*Panel{[]*Panel{panel}}
-	for i, layer := range plot.Layers {
-		plot.Panels[0][0].Layers[i] = layer
-		plot.Panels[0][0].Layers[i].Panel = panel
+	for i, orig := range plot.Layers {
+		layer := orig.copyTo(panel)
+		layer.Data = orig.Data
+		panel.Layers[i] = layer
 	}
 
 	fmt.Printf("After createSinglePanel plot.Panels = %+v\n", plot.Panels)
 }
 
 func (p *Plot) createGridPanels() {
-	// println("createGridPanel()")
 	// Process faceting: How many facets are there, how are they named
-	rows, cols := 1, 1
-	var cunq []float64
-	var runq []float64
-
-	// Make sure facetting can be done and determine number of
-	// rows and columns.
-	if p.Faceting.Columns != "" {
-		f := p.Data.Columns[p.Faceting.Columns]
-		if !f.Discrete() {
-			panic(fmt.Sprintf("Cannot facet over %s (type %s)",
-				p.Faceting.Columns, f.Type.String()))
-		}
-		cunq = Levels(p.Data, p.Faceting.Columns).Elements()
-		cols = len(cunq)
-		p.Faceting.ColStrips = make([]string, cols)
-
-		for c := 0; c < cols; c++ {
-			p.Faceting.ColStrips[c] = f.String(cunq[c])
+	colFields := facetFields(p.Faceting.Columns)
+	rowFields := facetFields(p.Faceting.Rows)
+	cfacets, rfacets := p.totals(p.facets(colFields), p.facets(rowFields),
+		colFields, rowFields)
+	rows, cols := len(rfacets), len(cfacets)
+	p.Faceting.ColStrips, p.Faceting.RowStrips = nil, nil
+	if len(colFields) > 0 {
+		for _, cf := range cfacets {
+			p.Faceting.ColStrips = append(p.Faceting.ColStrips, cf.strip)
 		}
 	}
-	if p.Faceting.Rows != "" {
-		f := p.Data.Columns[p.Faceting.Rows]
-		if !f.Discrete() {
-			panic(fmt.Sprintf("Cannot facet over %s (type %s)",
-				p.Faceting.Columns, f.Type.String()))
-		}
-		runq = Levels(p.Data, p.Faceting.Rows).Elements()
-		rows = len(runq)
-		p.Faceting.RowStrips = make([]string, rows)
-		for r := 0; r < rows; r++ {
-			p.Faceting.RowStrips[r] = f.String(runq[r])
+	if len(rowFields) > 0 {
+		for _, rf := range rfacets {
+			p.Faceting.RowStrips = append(p.Faceting.RowStrips, rf.strip)
 		}
 	}
 
-	p.Panels = make([][]*Panel, rows, rows+1)
-	for r := 0; r < rows; r++ {
-		p.Panels[r] = make([]*Panel, cols, cols+1)
-		rowData := Filter(p.Data, p.Faceting.Rows, runq[r])
-		for c := 0; c < cols; c++ {
+	p.Panels = make([][]*Panel, rows)
+	for r, rf := range rfacets {
+		p.Panels[r] = make([]*Panel, cols)
+		rowData := rf.data
+		for c, cf := range cfacets {
 			panel := &Panel{
-				Name:   fmt.Sprintf("%d/%d %s/%s", r, c, p.Faceting.RowStrips[r], p.Faceting.ColStrips[c]),
+				Name:   fmt.Sprintf("%d/%d %s/%s", r, c, rf.strip, cf.strip),
 				Plot:   p,
 				Scales: make(map[string]*Scale),
-				Data:   Filter(rowData, p.Faceting.Columns, cunq[c]),
+				Data:   cf.filter(rowData, colFields),
+				total:  rf.total || cf.total,
 			}
 			for _, orig := range p.Layers {
 				// Copy plot layers to panel, make sure layer data is filtered.
-				layer := &Layer{
-					Panel:       panel,
-					Name:        orig.Name,
-					Stat:        orig.Stat,
-					Geom:        orig.Geom,
-					DataMapping: orig.DataMapping,
-					StatMapping: orig.StatMapping,
-					GeomMapping: orig.GeomMapping,
-				}
+				layer := orig.copyTo(panel)
 				if orig.Data != nil {
-					layer.Data = Filter(orig.Data, p.Faceting.Rows, runq[r])
-					layer.Data = Filter(layer.Data, p.Faceting.Columns, cunq[c])
+					layer.Data = rf.filter(orig.Data, rowFields)
+					layer.Data = cf.filter(layer.Data, colFields)
 				}
 				panel.Layers = append(panel.Layers, layer)
 			}
 			p.Panels[r][c] = panel
-
-			if p.Faceting.Totals {
-				// Add a total columns containing all data of this row.
-				panel := &Panel{
-					Name: fmt.Sprintf("%d/%d %s/-all-",
-						r, c+1, p.Faceting.RowStrips[r]),
-					Plot:   p,
-					Data:   rowData,
-					Scales: make(map[string]*Scale),
-				}
-				for _, layer := range p.Layers {
-					if layer.Data != nil {
-						layer.Data = Filter(layer.Data, p.Faceting.Rows, runq[r])
-					}
-					layer.Panel = panel
-					panel.Layers = append(panel.Layers, layer)
-				}
-				p.Panels[r] = append(p.Panels[r], panel)
-			}
 		}
 	}
-	if p.Faceting.Totals {
-		// Add a total row containing all column data.
-		p.Panels = append(p.Panels, make([]*Panel, cols+1))
-		for c := 0; c < cols; c++ {
-			colData := Filter(p.Data, p.Faceting.Columns, cunq[c])
-			panel := &Panel{
-				Name: fmt.Sprintf("%d/%d -all-/%s",
-					rows, c, p.Faceting.ColStrips[c]),
+}
+
+// totals adds the total facets requested by Faceting.Totals and
+// Faceting.TotalsIn to the facets
+// cfacets of the columns and rfacets of the rows: The total column is
+// appended, the total row prepended, i.e. placed at the bottom.
+func (p *Plot) totals(cfacets, rfacets []facet, colFields, rowFields []string) ([]facet, []facet) {
+	label := p.Faceting.TotalLabel
+	if label == "" {
+		label = "-all-"
+	}
+	total := facet{strip: label, data: p.Data, total: true}
+	if !p.Faceting.Totals {
+		return cfacets, rfacets
+	}
+	in := p.Faceting.TotalsIn
+	switch in {
+	case "", "row", "col":
+	default:
+		p.Warnf("Ignoring unknown Faceting.TotalsIn %q", in)
+		in = ""
+	}
+	if in != "col" && len(rowFields) > 0 {
+		rfacets = append([]facet{total}, rfacets...)
+	}
+	if in != "row" && len(colFields) > 0 {
+		cfacets = append(cfacets, total)
+	}
+	return cfacets, rfacets
+}
+
+// createWrapPanels creates one panel for each combination of the levels
+// of the Faceting.Wrap fields present in the data. The panels are placed
+// row by row from the top left, blank panels fill the last row.
+func (p *Plot) createWrapPanels() {
+	facets := p.facets(p.Faceting.Wrap)
+	n := len(facets)
+	if n == 0 {
+		// No levels (e.g. no data): A single panel without strip.
+		p.Faceting.WrapStrips = nil
+		p.createSinglePanel()
+		return
+	}
+	cols := p.Faceting.NCol
+	if cols <= 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	if cols > n {
+		cols = n
+	}
+	rows := (n + cols - 1) / cols
+
+	p.Faceting.WrapStrips = make([]string, n)
+	p.Panels = make([][]*Panel, rows)
+	for r := range p.Panels {
+		p.Panels[r] = make([]*Panel, cols)
+	}
+	for i := 0; i < rows*cols; i++ {
+		r, c := rows-1-i/cols, i%cols
+		if i >= n {
+			p.Panels[r][c] = &Panel{
+				Name:   fmt.Sprintf("%d/%d blank", r, c),
 				Plot:   p,
-				Data:   colData,
 				Scales: make(map[string]*Scale),
+				blank:  true,
 			}
-			for _, layer := range p.Layers {
-				if layer.Data != nil {
-					layer.Data = Filter(layer.Data, p.Faceting.Columns, cunq[c])
-				}
-				layer.Panel = panel
-				panel.Layers = append(panel.Layers, layer)
-			}
-			p.Panels[rows][c] = panel
+			continue
 		}
+		fc := facets[i]
+		p.Faceting.WrapStrips[i] = fc.strip
 		panel := &Panel{
-			Name:   fmt.Sprintf("%d/%d -all-/-all-", rows, cols),
+			Name:   fmt.Sprintf("%d/%d %s", r, c, fc.strip),
 			Plot:   p,
-			Data:   p.Data,
 			Scales: make(map[string]*Scale),
+			Data:   fc.data,
 		}
-		for _, layer := range p.Layers {
-			layer.Panel = panel
+		for _, orig := range p.Layers {
+			layer := orig.copyTo(panel)
+			if orig.Data != nil {
+				layer.Data = fc.filter(orig.Data, p.Faceting.Wrap)
+			}
 			panel.Layers = append(panel.Layers, layer)
 		}
-		p.Panels[rows][cols] = panel
-		cols++
-		rows++
+		p.Panels[r][c] = panel
 	}
+}
 
+// wrapPanel returns the i'th of the wrapped panels.
+func (p *Plot) wrapPanel(i int) *Panel {
+	cols := len(p.Panels[0])
+	return p.Panels[len(p.Panels)-1-i/cols][i%cols]
+}
+
+// copyTo returns a copy of the layer without data in panel.
+func (layer *Layer) copyTo(panel *Panel) *Layer {
+	return &Layer{
+		Panel:       panel,
+		Name:        layer.Name,
+		Stat:        layer.Stat,
+		Geom:        layer.Geom,
+		DataMapping: layer.DataMapping,
+		StatMapping: layer.StatMapping,
+		GeomMapping: layer.GeomMapping,
+		HideLegend:  layer.HideLegend,
+	}
 }
 
 // -------------------------------------------------------------------------
@@ -1088,148 +1414,445 @@ func (plot *Plot) Layout(canvas vg.Canvas, width, height vg.Length) {
 		xlabelh += 2 * vg.Millimeter // TODO: make configurable
 	}
 
+	// Titles of the secondary axes.
+	var x2labelh, y2labelw vg.Length
+	if _, ok := plot.Grobs["X2-Label"]; ok {
+		x2labelh = plot.renderInfo["X2-Label.Height"]
+		x2labelh += 2 * vg.Millimeter // TODO: make configurable
+	}
+	if _, ok := plot.Grobs["Y2-Label"]; ok {
+		y2labelw = plot.renderInfo["Y2-Label.Width"]
+		y2labelw += 2 * vg.Millimeter // TODO: make configurable
+	}
+
 	guidesSep := 2 * vg.Millimeter // TODO: make configurable
 	guidesw := plot.renderInfo["Guides.Width"] + 2*guidesSep
 
+	// Col- and Row-Labels, X- and Y-Tics
+	var xticsh, yticsw vg.Length
+	var collabh, rowlabw vg.Length
+	yticsw, xticsh = plot.ticsExtents()
+	y2ticsw, x2ticsh := plot.secondaryTicsExtents()
+	plot.renderInfo["Secondary-X.Height"] = x2ticsh
+	plot.renderInfo["Secondary-Y.Width"] = y2ticsw
+	collabh = plot.renderInfo["Col-Strip.Height"] + 2*vg.Millimeter // TODO: make configurabel
+	rowlabw = plot.renderInfo["Row-Strip.Width"] + 2*vg.Millimeter  // TODO: make configurabel
+
+	// Strips below (left of) the panels are placed outside the tics.
+	var colstripb, rowstripl vg.Length
+	if plot.Faceting.ColStripPos == "bottom" && !plot.Faceting.wrapped() {
+		colstripb, collabh = collabh, 2*vg.Millimeter
+	}
+	if plot.Faceting.RowStripPos == "left" {
+		rowstripl, rowlabw = rowlabw, 2*vg.Millimeter
+	}
+
+	// Marginal distributions are drawn between panels and strips.
+	margh := plot.renderInfo["Marginal-X.Height"]
+	margw := plot.renderInfo["Marginal-Y.Width"]
+
+	sepx := 2 * vg.Millimeter // TODO: make configurabel
+	sepy := 2 * vg.Millimeter // TODO: make configurabel
+	nrows := len(plot.Panels)
+	ncols := len(plot.Panels[0])
+
+	// Wrapped panels have their strip (and marginals) on top of each
+	// panel and per-panel scales have axes on each panel.
+	if plot.Faceting.wrapped() {
+		sepy += collabh + margh + x2ticsh
+	}
+	if plot.scaleSharing("x") == "per-panel" {
+		sepy += xticsh
+	}
+	if plot.scaleSharing("y") == "per-panel" {
+		sepx += yticsw
+	}
+
+	tw := width - ylabelw - rowstripl - guidesw - yticsw - rowlabw - margw - y2ticsw - y2labelw
+	th := height - titleh - xlabelh - colstripb - collabh - xticsh - margh - x2ticsh - x2labelh
+	pwidth := (tw - sepx*vg.Length(ncols-1)) / vg.Length(ncols)
+	pheight := (th - sepy*vg.Length(nrows-1)) / vg.Length(nrows)
+
+	// Panels with a fixed aspect ratio are centered: Everything left
+	// of (below) the panels is shifted right (up) by dx (dy) and
+	// everything right of (above) the panels left (down).
+	var dx, dy vg.Length
+	if aspect := plot.aspect(); aspect > 0 {
+		if h := vg.Length(aspect) * pwidth; h < pheight {
+			dy = vg.Length(nrows) * (pheight - h) / 2
+			pheight = h
+		} else {
+			w := pheight / vg.Length(aspect)
+			dx = vg.Length(ncols) * (pwidth - w) / 2
+			pwidth = w
+		}
+	}
+	x0, y0 := ylabelw+rowstripl+yticsw+dx, xlabelh+colstripb+xticsh+dy
+
 	plot.Viewports["Title"] = Viewport{
 		Canvas: canvas,
-		X0:     0, Y0: height - titleh,
+		X0:     0, Y0: height - titleh - dy,
 		Width: width, Height: titleh,
 	}
 	plot.Viewports["Y-Label"] = Viewport{
 		Canvas: canvas,
-		X0:     0, Y0: xlabelh,
+		X0:     dx, Y0: xlabelh,
 		Width: ylabelw, Height: height - titleh - xlabelh,
 	}
 	plot.Viewports["X-Label"] = Viewport{
 		Canvas: canvas,
-		X0:     ylabelw, Y0: 0,
+		X0:     ylabelw, Y0: dy,
 		Width: width - ylabelw - guidesw, Height: xlabelh,
 	}
 
+	plot.Viewports["X2-Label"] = Viewport{
+		Canvas: canvas,
+		X0:     ylabelw, Y0: height - titleh - x2labelh - dy,
+		Width: width - ylabelw - guidesw - y2labelw, Height: x2labelh,
+	}
+	plot.Viewports["Y2-Label"] = Viewport{
+		Canvas: canvas,
+		X0:     width - guidesw - y2labelw - dx, Y0: xlabelh,
+		Width: y2labelw, Height: height - titleh - xlabelh,
+	}
+
 	plot.Viewports["Guides"] = Viewport{
 		Canvas: canvas,
-		X0:     width - guidesw + guidesSep, Y0: xlabelh,
+		X0:     width - guidesw + guidesSep - dx, Y0: xlabelh,
 		Width: guidesw, Height: height - titleh - xlabelh,
 		Direct: true,
 	}
 
-	// Col- and Row-Labels, X- and Y-Tics
-	var xticsh, yticsw vg.Length
-	var collabh, rowlabw vg.Length
-	yticsw, xticsh = plot.ticsExtents()
-	collabh = plot.renderInfo["Col-Strip.Height"] + 2*vg.Millimeter // TODO: make configurabel
-	rowlabw = plot.renderInfo["Row-Strip.Width"] + 2*vg.Millimeter  // TODO: make configurabel
-
-	sepx := 2 * vg.Millimeter // TODO: make configurabel
-	sepy := 2 * vg.Millimeter // TODO: make configurabel
-	nrows := len(plot.Panels)
-	ncols := len(plot.Panels[0])
-	tw := width - ylabelw - guidesw - yticsw - rowlabw
-	th := height - titleh - xlabelh - collabh - xticsh
-	pwidth := (tw - sepx*vg.Length(ncols-1)) / vg.Length(ncols)
-	pheight := (th - sepy*vg.Length(nrows-1)) / vg.Length(nrows)
-	x0, y0 := ylabelw+yticsw, xlabelh+xticsh
+	// Columns and rows of panels, possibly of different size.
+	xs, widths := panelExtents(ncols, x0, sepx, pwidth, plot.spaceWeights("x"))
+	ys, heights := panelExtents(nrows, y0, sepy, pheight, plot.spaceWeights("y"))
 
 	// Viewports for the panels themself.
 	for r := 0; r < nrows; r++ {
 		for c := 0; c < ncols; c++ {
 			panelId := fmt.Sprintf("Panel-%d,%d", r, c)
-			x := x0 + vg.Length(c)*(pwidth+sepx)
-			y := y0 + vg.Length(r)*(pheight+sepy)
-			// fmt.Printf("  %s r=%d c=%d:  x=%.2f  y=%.2f\n", panelId, r, c, x, y)
 			plot.Viewports[panelId] = Viewport{
 				Canvas: canvas,
-				X0:     x, Y0: y,
-				Width: pwidth, Height: pheight,
+				X0:     xs[c], Y0: ys[r],
+				Width: widths[c], Height: heights[r],
 			}
 		}
 	}
 
-	// Viewports for y-tics and row-strips.
+	// Viewports for y-tics and row-strips. Lvp and Bvp contain the
+	// strips if placed left respectively below the panels.
 	for r := 0; r < nrows; r++ {
-		y := y0 + vg.Length(r)*(pheight+sepy)
 		plot.Panels[r][0].Lvp = Viewport{
 			Canvas: canvas,
-			X0:     ylabelw, Y0: y,
-			Width: yticsw, Height: pheight,
+			X0:     ylabelw + dx, Y0: ys[r],
+			Width: yticsw, Height: heights[r],
+		}
+		if rowstripl > 0 {
+			plot.Panels[r][0].Lvp.Width = rowstripl
 		}
 		plot.Panels[r][ncols-1].Rvp = Viewport{
 			Canvas: canvas,
-			X0:     width - guidesw - rowlabw, Y0: y,
-			Width: rowlabw, Height: pheight,
+			X0:     width - guidesw - y2labelw - rowlabw - margw - y2ticsw - dx, Y0: ys[r],
+			Width: rowlabw + margw + y2ticsw, Height: heights[r],
 		}
 	}
 
 	// Viewports for x-tics and col-strips.
 	for c := 0; c < ncols; c++ {
-		x := x0 + vg.Length(c)*(pwidth+sepx)
 		plot.Panels[0][c].Bvp = Viewport{
 			Canvas: canvas,
-			X0:     x, Y0: xlabelh,
-			Width: pwidth, Height: xticsh,
+			X0:     xs[c], Y0: xlabelh + dy,
+			Width: widths[c], Height: xticsh,
+		}
+		if colstripb > 0 {
+			plot.Panels[0][c].Bvp.Height = colstripb
 		}
 		plot.Panels[nrows-1][c].Tvp = Viewport{
 			Canvas: canvas,
-			X0:     x, Y0: height - titleh - collabh,
-			Width: pwidth, Height: collabh,
+			X0:     xs[c], Y0: height - titleh - x2labelh - collabh - margh - x2ticsh - dy,
+			Width: widths[c], Height: collabh + margh + x2ticsh,
+		}
+		if !plot.Faceting.wrapped() {
+			continue
 		}
+		// The strips of wrapped panels in the lower rows.
+		for r := 0; r < nrows-1; r++ {
+			plot.Panels[r][c].Tvp = Viewport{
+				Canvas: canvas,
+				X0:     xs[c], Y0: ys[r] + heights[r],
+				Width: widths[c], Height: collabh + margh + x2ticsh,
+			}
+		}
+	}
+}
+
+// spaceWeights returns the relative widths of the columns (dim "x") or the
+// relative heights of the rows (dim "y") of panels if Faceting.FreeSpace
+// frees this dimension and nil if all columns or rows are of equal size.
+func (plot *Plot) spaceWeights(dim string) []float64 {
+	if !strings.Contains(plot.Faceting.FreeSpace, dim) || plot.aspect() > 0 {
+		return nil
+	}
+	horizontal, vertical := plot.axes()
+	var scales []*Scale
+	switch {
+	case dim == "x" && plot.scaleSharing(horizontal) == "col-shared":
+		for _, panel := range plot.Panels[0] {
+			scales = append(scales, panel.Scales[horizontal])
+		}
+	case dim == "y" && plot.scaleSharing(vertical) == "row-shared":
+		for _, row := range plot.Panels {
+			scales = append(scales, row[0].Scales[vertical])
+		}
+	default:
+		return nil
 	}
+	weights := make([]float64, len(scales))
+	for i, scale := range scales {
+		weights[i] = scale.Max - scale.Min
+	}
+	return weights
+}
 
+// panelExtents distributes n*size to n panels separated by sep starting
+// at start: Each panel gets a share proportional to its weight. Nil
+// weights give all panels the same size.
+func panelExtents(n int, start, sep, size vg.Length, weights []float64) (pos, ext []vg.Length) {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	pos, ext = make([]vg.Length, n), make([]vg.Length, n)
+	for i := range ext {
+		ext[i] = size
+		if sum > 0 {
+			ext[i] = vg.Length(float64(n)*weights[i]/sum) * size
+		}
+		pos[i] = start
+		start += ext[i] + sep
+	}
+	return pos, ext
 }
 
 // ticsExtents computes the width of the y-tics and the height of the x-tics
 // needed to display the tics.
 func (plot *Plot) ticsExtents() (ywidth, xheight vg.Length) {
-	label := MergeStyles(plot.Theme.TicLabel, DefaultTheme.TicLabel)
-	size := String2Float(label["size"], 4, 36)
-	angle := String2Float(label["angle"], 0, 2*math.Pi) // TODO: Should be different for x and y.
-	sep := vg.Length(String2Float(label["sep"], 0, 100))
-	tic := MergeStyles(plot.Theme.Tic, DefaultTheme.Tic)
-	length := vg.Length(String2Float(tic["length"], 0, 100))
+	horizontal, vertical := plot.axes()
+	plot.eachPanel(func(panel *Panel) {
+		if w, _ := plot.ticLabelExtents(panel.Scales[vertical].Labels); w > ywidth {
+			ywidth = w
+		}
+		if _, h := plot.ticLabelExtents(panel.Scales[horizontal].Labels); h > xheight {
+			xheight = h
+		}
+	})
+	return ywidth + plot.ticSpace(), xheight + plot.ticSpace()
+}
 
-	// Look for longest y label.
-	for r := range plot.Panels {
-		sy := plot.Panels[r][0].Scales["y"]
-		for _, label := range sy.Labels {
-			w, _ := GrobText{text: label, size: size, angle: angle}.BoundingBox()
-			if w > ywidth {
+// secondaryTicsExtents is like ticsExtents for the tics of the secondary
+// axes on the right and top. Both are zero if there is no secondary axis.
+func (plot *Plot) secondaryTicsExtents() (ywidth, xheight vg.Length) {
+	horizontal, vertical := plot.axes()
+	if plot.Scales[vertical].Secondary != nil {
+		plot.eachPanel(func(panel *Panel) {
+			if w, _ := plot.ticLabelExtents(panel.Scales[vertical].secLabels); w > ywidth {
 				ywidth = w
 			}
-		}
+		})
+		ywidth += plot.ticSpace()
 	}
-	ywidth += length + sep
-
-	// Look for highest x label.
-	for c := range plot.Panels[0] {
-		sx := plot.Panels[0][c].Scales["x"]
-		for _, label := range sx.Labels {
-			_, h := GrobText{text: label, size: size, angle: angle}.BoundingBox()
-			if h > xheight {
+	if plot.Scales[horizontal].Secondary != nil {
+		plot.eachPanel(func(panel *Panel) {
+			if _, h := plot.ticLabelExtents(panel.Scales[horizontal].secLabels); h > xheight {
 				xheight = h
 			}
+		})
+		xheight += plot.ticSpace()
+	}
+	return ywidth, xheight
+}
+
+// eachPanel calls f for all panels of plot except blank ones.
+func (plot *Plot) eachPanel(f func(panel *Panel)) {
+	for r := range plot.Panels {
+		for _, panel := range plot.Panels[r] {
+			if !panel.blank {
+				f(panel)
+			}
 		}
 	}
-	xheight += length + sep
+}
 
-	return ywidth, xheight
+// ticLabelExtents returns the maximal width and height of the tic labels.
+func (plot *Plot) ticLabelExtents(labels []string) (width, height vg.Length) {
+	label := MergeStyles(plot.Theme.TicLabel, DefaultTheme.TicLabel)
+	size := String2Float(label["size"], 4, 36)
+	angle := String2Float(label["angle"], 0, 2*math.Pi) // TODO: Should be different for x and y.
+	for _, l := range labels {
+		w, h := GrobText{text: l, size: size, angle: angle}.BoundingBox()
+		if w > width {
+			width = w
+		}
+		if h > height {
+			height = h
+		}
+	}
+	return width, height
+}
+
+// ticSpace is the space taken by a tic and the separation to its label.
+func (plot *Plot) ticSpace() vg.Length {
+	label := MergeStyles(plot.Theme.TicLabel, DefaultTheme.TicLabel)
+	tic := MergeStyles(plot.Theme.Tic, DefaultTheme.Tic)
+	return vg.Length(String2Float(tic["length"], 0, 100)) +
+		vg.Length(String2Float(label["sep"], 0, 100))
+}
+
+// axisStyle contains the theme settings of the tics and their labels.
+type axisStyle struct {
+	ticLT   LineType
+	ticCol  color.Color
+	ticLen  vg.Length
+	ticSize float64
+
+	labelAngle float64
+	labelCol   color.Color
+	labelSep   vg.Length
+	labelSize  float64
+}
+
+// axisStyle returns the style of the tics and tic labels of plot.
+func (plot *Plot) axisStyle() axisStyle {
+	tic := MergeStyles(plot.Theme.Tic, DefaultTheme.Tic)
+	label := MergeStyles(plot.Theme.TicLabel, DefaultTheme.TicLabel)
+	return axisStyle{
+		ticLT:      String2LineType(tic["linetype"]),
+		ticCol:     String2Color(tic["color"]),
+		ticLen:     vg.Length(String2Float(tic["length"], 0, 1000)),
+		ticSize:    String2Float(tic["size"], 0, 100),
+		labelAngle: String2Float(label["angle"], 0, 2*math.Pi),
+		labelCol:   String2Color(label["color"]),
+		labelSep:   vg.Length(String2Float(label["sep"], 0, 1000)),
+		labelSize:  String2Float(label["size"], 0, 100),
+	}
+}
+
+// drawHorizontalAxis draws tics with the given labels at the horizontal
+// positions pos below vp.
+func (style axisStyle) drawHorizontalAxis(vp Viewport, pos []float64, labels []string) {
+	h, sep := vp.YI(style.ticLen), vp.YI(style.labelSep)
+	for i, xv := range pos {
+		GrobLine{x0: xv, y0: 0, x1: xv, y1: -h,
+			linetype: style.ticLT, size: style.ticSize, color: style.ticCol}.Draw(vp)
+		GrobText{x: xv, y: -h - sep, hjust: 0.5, vjust: 1, text: labels[i],
+			size: style.labelSize, angle: style.labelAngle, color: style.labelCol}.Draw(vp)
+	}
+}
+
+// drawVerticalAxis draws tics with the given labels at the vertical
+// positions pos on the left side of vp.
+func (style axisStyle) drawVerticalAxis(vp Viewport, pos []float64, labels []string) {
+	w, sep := vp.XI(style.ticLen), vp.XI(style.labelSep)
+	for i, yv := range pos {
+		GrobLine{x0: 0, y0: yv, x1: -w, y1: yv,
+			linetype: style.ticLT, size: style.ticSize, color: style.ticCol}.Draw(vp)
+		GrobText{x: -w - sep, y: yv, hjust: 1, vjust: 0.5, text: labels[i],
+			size: style.labelSize, color: style.labelCol}.Draw(vp)
+	}
+}
+
+// secondaryViewports returns the viewports of the secondary axes above
+// and right of the panel: The lowest part of Tvp and the leftmost part of
+// Rvp reserved by Layout.
+func (panel *Panel) secondaryViewports() (top, right Viewport) {
+	top, right = panel.Tvp, panel.Rvp
+	top.Height = panel.Plot.renderInfo["Secondary-X.Height"]
+	right.Width = panel.Plot.renderInfo["Secondary-Y.Width"]
+	return top, right
+}
+
+// drawSecondaryAxes draws the tics and labels of the secondary axes of the
+// panel on top (if top) and on the right (if right).
+func (panel *Panel) drawSecondaryAxes(top, right bool) {
+	if !panel.Plot.coord().Linear() {
+		return
+	}
+	style := panel.Plot.axisStyle()
+	tvp, rvp := panel.secondaryViewports()
+
+	horizontal, vertical := panel.Plot.axes()
+	if sx := panel.Scales[horizontal]; top && sx.Secondary != nil {
+		h, sep := tvp.YI(style.ticLen), tvp.YI(style.labelSep)
+		for i, x := range sx.secBreaks {
+			xv := sx.Pos(x)
+			GrobLine{x0: xv, y0: 0, x1: xv, y1: h,
+				linetype: style.ticLT, size: style.ticSize, color: style.ticCol}.Draw(tvp)
+			GrobText{x: xv, y: h + sep, hjust: 0.5, vjust: 0,
+				text: sx.secLabels[i], size: style.labelSize, angle: style.labelAngle,
+				color: style.labelCol}.Draw(tvp)
+		}
+	}
+	if sy := panel.Scales[vertical]; right && sy.Secondary != nil {
+		w, sep := rvp.XI(style.ticLen), rvp.XI(style.labelSep)
+		for i, y := range sy.secBreaks {
+			yv := sy.Pos(y)
+			GrobLine{x0: 0, y0: yv, x1: w, y1: yv,
+				linetype: style.ticLT, size: style.ticSize, color: style.ticCol}.Draw(rvp)
+			GrobText{x: w + sep, y: yv, hjust: 0, vjust: 0.5,
+				text: sy.secLabels[i], size: style.labelSize, color: style.labelCol}.Draw(rvp)
+		}
+	}
 }
 
 // Draw the whole content of this panel to vp.
 // show{X,Y} are used to control display of X and Y scale.
 func (panel *Panel) Draw(vp Viewport, showX, showY bool) {
 
-	// Draw strips first.
+	// Draw marginal distributions and strips first, outside the
+	// secondary axes.
+	tvp, rvp := panel.Tvp, panel.Rvp
+	sectop, secright := panel.secondaryViewports()
+	tvp.Y0 += sectop.Height
+	tvp.Height -= sectop.Height
+	rvp.X0 += secright.Width
+	rvp.Width -= secright.Width
+	if margh := panel.Plot.renderInfo["Marginal-X.Height"]; margh > 0 {
+		marg := tvp
+		marg.Height = margh
+		for _, grob := range panel.Tmg {
+			grob.Draw(marg)
+		}
+		tvp.Y0 += margh
+		tvp.Height -= margh
+	}
+	if margw := panel.Plot.renderInfo["Marginal-Y.Width"]; margw > 0 {
+		marg := rvp
+		marg.Width = margw
+		for _, grob := range panel.Rmg {
+			grob.Draw(marg)
+		}
+		rvp.X0 += margw
+		rvp.Width -= margw
+	}
 	for _, grob := range panel.Tgr {
-		grob.Draw(panel.Tvp)
+		grob.Draw(tvp)
 	}
 	for _, grob := range panel.Rgr {
 		fmt.Printf("Right: %s\n", grob.String())
-		grob.Draw(panel.Rvp)
+		grob.Draw(rvp)
+	}
+	for _, grob := range panel.Bgr {
+		grob.Draw(panel.Bvp)
+	}
+	for _, grob := range panel.Lgr {
+		grob.Draw(panel.Lvp)
 	}
 
 	// Draw the panel background second.
 	panelBG := MergeStyles(panel.Plot.Theme.PanelBG, DefaultTheme.PanelBG)
+	if panel.total {
+		panelBG = MergeStyles(panel.Plot.Theme.TotalBG, DefaultTheme.TotalBG, panelBG)
+	}
 	// TODO: Decide how to _not_ draw something, e.g. the background?
 	GrobRect{
 		xmin: 0, ymin: 0,
@@ -1241,9 +1864,17 @@ func (panel *Panel) Draw(vp Viewport, showX, showY bool) {
 		size:     String2Float(panelBG["size"], 0, 20),
 		color:    String2Color(panelBG["color"])}.Draw(vp)
 
-	// Draw grid lines.
+	// Draw grid lines. They are set up in scale space and transformed
+	// by the coordinate system, the axes are drawn for the scales shown
+	// horizontally (sh) and vertically (sv).
+	coord := panel.coord()
 	sx := panel.Scales["x"]
 	sy := panel.Scales["y"]
+	horizontal, vertical := panel.Plot.axes()
+	sh, sv := panel.Scales[horizontal], panel.Scales[vertical]
+	grid := func(line GrobLine) {
+		transformGrob(line, coord).Draw(vp)
+	}
 	if showX {
 		fmt.Printf("\nX-Scale for panel %q:\n%s\n", panel.Name, sx.String())
 	}
@@ -1257,52 +1888,70 @@ func (panel *Panel) Draw(vp Viewport, showX, showY bool) {
 	majorSize := String2Float(major["size"], 0, 20)
 	majorCol := String2Color(major["color"])
 
-	tic := MergeStyles(panel.Plot.Theme.Tic, DefaultTheme.Tic)
-	ticLT := String2LineType(tic["linetype"])
-	ticCol := String2Color(tic["color"])
-	ticLen := vg.Length(String2Float(tic["length"], 0, 1000))
-	ticSize := String2Float(tic["size"], 0, 100)
-
-	label := MergeStyles(panel.Plot.Theme.TicLabel, DefaultTheme.TicLabel)
-	labelAngle := String2Float(label["angle"], 0, 2*math.Pi)
-	labelCol := String2Color(label["color"])
-	labelSep := vg.Length(String2Float(label["sep"], 0, 1000))
-	labelSize := String2Float(label["size"], 0, 100)
-
-	// TODO minor := MergeStyles(panel.Plot.Theme.GridMinor, DefaultTheme.GridMinor)
-	for i, x := range sx.Breaks {
-		xv := sx.Pos(x)
-		GrobLine{x0: xv, y0: 0, x1: xv, y1: 1,
-			linetype: majorLT, size: majorSize, color: majorCol}.Draw(vp)
-		if !showX {
-			continue
+	style := panel.Plot.axisStyle()
+
+	minor := MergeStyles(panel.Plot.Theme.GridMinor, DefaultTheme.GridMinor)
+	minorLT := String2LineType(minor["linetype"])
+	minorSize := String2Float(minor["size"], 0, 20)
+	minorCol := SetAlpha(String2Color(minor["color"]), String2Float(minor["alpha"], 0, 1))
+	if minorLT != BlankLine {
+		for _, x := range sx.MinorBreaks {
+			xv := sx.Pos(x)
+			grid(GrobLine{x0: xv, y0: 0, x1: xv, y1: 1,
+				linetype: minorLT, size: minorSize, color: minorCol})
 		}
-		h, sep := vp.YI(vg.Length(ticLen)), vp.YI(labelSep)
-		GrobLine{x0: xv, y0: 0, x1: xv, y1: -h,
-			linetype: ticLT, size: ticSize, color: ticCol}.Draw(vp)
-		GrobText{x: xv, y: -h - sep, hjust: 0.5, vjust: 1,
-			text: sx.Labels[i], size: labelSize, angle: labelAngle,
-			color: labelCol}.Draw(vp)
+		for _, y := range sy.MinorBreaks {
+			yv := sy.Pos(y)
+			grid(GrobLine{x0: 0, y0: yv, x1: 1, y1: yv,
+				linetype: minorLT, size: minorSize, color: minorCol})
+		}
+	}
+	for _, x := range sx.Breaks {
+		xv := sx.Pos(x)
+		grid(GrobLine{x0: xv, y0: 0, x1: xv, y1: 1,
+			linetype: majorLT, size: majorSize, color: majorCol})
 	}
-	for i, y := range sy.Breaks {
+	for _, y := range sy.Breaks {
 		yv := sy.Pos(y)
-		GrobLine{x0: 0, y0: yv, x1: 1, y1: yv,
-			linetype: majorLT, size: majorSize, color: majorCol}.Draw(vp)
-		if !showY {
-			continue
+		grid(GrobLine{x0: 0, y0: yv, x1: 1, y1: yv,
+			linetype: majorLT, size: majorSize, color: majorCol})
+	}
+
+	if polar, ok := coord.(CoordPolar); ok {
+		polar.drawAxes(panel, vp, style, showY)
+	} else {
+		// Maps label the graticule where it meets the lower and
+		// the left border of the mapped area.
+		_, isMap := coord.(CoordMap)
+		if showX {
+			pos := make([]float64, len(sh.Breaks))
+			for i, x := range sh.Breaks {
+				pos[i] = sh.Pos(x)
+				if isMap {
+					pos[i], _ = coord.Transform(pos[i], 0)
+				}
+			}
+			style.drawHorizontalAxis(vp, pos, sh.Labels)
+		}
+		if showY {
+			pos := make([]float64, len(sv.Breaks))
+			for i, y := range sv.Breaks {
+				pos[i] = sv.Pos(y)
+				if isMap {
+					_, pos[i] = coord.Transform(0, pos[i])
+				}
+			}
+			style.drawVerticalAxis(vp, pos, sv.Labels)
 		}
-		w, sep := vp.XI(vg.Length(ticLen)), vp.XI(labelSep)
-		GrobLine{x0: 0, y0: yv, x1: -w, y1: yv,
-			linetype: ticLT, size: ticSize, color: ticCol}.Draw(vp)
-		GrobText{x: -w - sep, y: yv, hjust: 1, vjust: 0.5, text: sy.Labels[i],
-			size: labelSize, color: labelCol}.Draw(vp)
 	}
 
-	// Draw the layers.
+	// Draw the layers, clipped to the panel.
+	clipped := vp
+	clipped.Clip = true
 	for _, layer := range panel.Layers {
 		for _, g := range layer.Grobs {
 			// fmt.Printf("Drawing on layer %s: %d %s\n", layer.Name, gi, g.String())
-			g.Draw(vp)
+			g.Draw(clipped)
 		}
 	}
 }
@@ -2,12 +2,17 @@ package plot
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"math"
 	"math/rand"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/vgimg"
 )
 
@@ -16,6 +21,18 @@ func same(s []string, t []string) bool {
 	return ss.Equals(t)
 }
 
+// newTestPlot creates a plot of data with the aesthetic mapping aes, the
+// given title and layers. The test stops if the plot cannot be created.
+func newTestPlot(t *testing.T, data interface{}, aes AesMapping, title string, layers ...*Layer) *Plot {
+	plot, err := NewPlot(data, aes)
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = title
+	plot.Layers = append(plot.Layers, layers...)
+	return plot
+}
+
 func TestStatBin(t *testing.T) {
 	pool := NewStringPool()
 	df, _ := NewDataFrameFrom(measurement, pool)
@@ -491,3 +508,1917 @@ func TestBoxplot(t *testing.T) {
 
 	plot.WritePNG("boxplot.png", 800, 600)
 }
+
+func TestHeatmap(t *testing.T) {
+	type latency struct {
+		Hour    int
+		Weekday string
+		Day     int
+		Latency float64
+	}
+	days := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	data := []latency{}
+	for d, day := range days {
+		for h := 0; h < 24; h++ {
+			l := 50 + 40*math.Sin(float64(h)/24*2*math.Pi) + 5*float64(d)
+			data = append(data, latency{h, day, d, l})
+		}
+	}
+
+	// Tiles on a discrete y scale.
+	plot, err := NewPlot(data, AesMapping{
+		"x":    "Hour",
+		"y":    "Weekday",
+		"fill": "Latency",
+	})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Tiles"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Tiles",
+		Geom: GeomTile{},
+	})
+	plot.WritePNG("tiles.png", 800, 600)
+	if n := len(plot.Panels[0][0].Layers[0].Grobs); n != len(data) {
+		t.Errorf("Got %d grobs, want %d", n, len(data))
+	}
+
+	// Raster on continuous x and y.
+	plot, err = NewPlot(data, AesMapping{
+		"x":    "Hour",
+		"y":    "Day",
+		"fill": "Latency",
+	})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Raster"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Raster",
+		Geom: GeomRaster{},
+	})
+	plot.WritePNG("raster.png", 800, 600)
+	grobs := plot.Panels[0][0].Layers[0].Grobs
+	if len(grobs) != 1 {
+		t.Fatalf("Got %d grobs, want 1", len(grobs))
+	}
+	raster, ok := grobs[0].(GrobRaster)
+	if !ok {
+		t.Fatalf("Got %T, want GrobRaster", grobs[0])
+	}
+	if b := raster.image.Bounds(); b.Dx() != 24 || b.Dy() != 7 {
+		t.Errorf("Got %dx%d raster, want 24x7", b.Dx(), b.Dy())
+	}
+}
+
+func TestRugAndMarginals(t *testing.T) {
+	aes := AesMapping{
+		"x": "Height",
+		"y": "Weight",
+	}
+	plot, err := NewPlot(measurement, aes)
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Rug and Marginals"
+	plot.MarginalX = &Marginal{Type: MarginalHistogram, Bins: 10}
+	plot.MarginalY = &Marginal{Type: MarginalDensity}
+
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Raw Data",
+		Geom: GeomPoint{},
+	})
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Rug",
+		Geom: GeomRug{Sides: "bl", Style: AesMapping{"size": "1"}},
+	})
+	plot.WritePNG("marginals.png", 800, 600)
+
+	panel := plot.Panels[0][0]
+	if n := len(panel.Layers[1].Grobs); n != 2*len(measurement) {
+		t.Errorf("Got %d rug ticks, want %d", n, 2*len(measurement))
+	}
+	if len(panel.Tmg) == 0 {
+		t.Errorf("Missing marginal histogram")
+	}
+	if len(panel.Rmg) != 1 {
+		t.Errorf("Got %d grobs for marginal density, want 1", len(panel.Rmg))
+	}
+}
+
+func TestMarginalValues(t *testing.T) {
+	type obs struct{ X, X2, Y float64 }
+	data := []obs{}
+	for i := 0; i < 10; i++ {
+		data = append(data, obs{float64(i), float64(i + 10), float64(i % 3)})
+	}
+	plot, err := NewPlot(data, AesMapping{"x": "X", "y": "Y"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Marginal values"
+	xs := NewScale("x", "X", Float)
+	xs.ExpandRel = 0
+	plot.Scales["x"] = xs
+	plot.MarginalX = &Marginal{Bins: 19}
+	plot.Layers = append(plot.Layers,
+		&Layer{Name: "Points", Geom: GeomPoint{}},
+		&Layer{Name: "Rug", Geom: GeomRug{}},
+		&Layer{Name: "Shifted", Geom: GeomPoint{}, DataMapping: AesMapping{"x": "X2"}})
+	plot.WritePNG("marginal-values.png", 500, 400)
+
+	// The rug shares data and field with the points, the shifted points
+	// map their own field.
+	panel := plot.Panels[0][0]
+	if n := len(panel.marginals["x"]); n != 20 {
+		t.Errorf("Got %d marginal values, want 20", n)
+	}
+	// Bins of width 1 from 0 to 19: The last bin contains 18 and 19.
+	full := 0
+	for _, g := range panel.Tmg {
+		if r := g.(GrobRect); r.ymax > 0.8 {
+			full++
+		}
+	}
+	if len(panel.Tmg) != 19 || full != 1 {
+		t.Errorf("Got %d bars, %d of full height; want 19 and 1", len(panel.Tmg), full)
+	}
+
+	// A degenerated scale shows nothing.
+	constant := NewScale("x", "X", Float)
+	constant.Min, constant.Max = 5, 5
+	if grobs := (&Marginal{}).render([]float64{5, 5}, constant, plot.Theme, false); len(grobs) != 0 {
+		t.Errorf("Got %d grobs for constant values", len(grobs))
+	}
+}
+
+func TestLabels(t *testing.T) {
+	for _, repel := range []bool{false, true} {
+		aes := AesMapping{
+			"x": "Height",
+			"y": "Weight",
+		}
+		plot, err := NewPlot(measurement, aes)
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = fmt.Sprintf("Labels, repel=%t", repel)
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Raw Data",
+			Geom: GeomPoint{},
+		})
+		plot.Layers = append(plot.Layers, &Layer{
+			Name:        "Age Label",
+			DataMapping: AesMapping{"value": "Age"},
+			Stat:        &StatLabel{Format: "%.0f years"},
+			Geom: GeomLabel{
+				Repel: repel,
+				Seed:  1,
+				Style: AesMapping{"fill": "#ffffcc"},
+			},
+		})
+		plot.WritePNG(fmt.Sprintf("labels-%t.png", repel), 800, 600)
+
+		grobs := plot.Panels[0][0].Layers[1].Grobs
+		if !repel {
+			if len(grobs) != len(measurement) {
+				t.Fatalf("Got %d grobs, want %d", len(grobs), len(measurement))
+			}
+			for i, g := range grobs {
+				if _, ok := g.(GrobLabel); !ok {
+					t.Errorf("Grob %d: got %T, want GrobLabel", i, g)
+				}
+			}
+			continue
+		}
+		if len(grobs) != 1 {
+			t.Fatalf("Got %d grobs, want 1", len(grobs))
+		}
+		r, ok := grobs[0].(GrobRepel)
+		if !ok {
+			t.Fatalf("Got %T, want GrobRepel", grobs[0])
+		}
+		if len(r.labels) != len(measurement) {
+			t.Fatalf("Got %d labels, want %d", len(r.labels), len(measurement))
+		}
+
+		// Place the labels in a 600x400 points region and check that
+		// no two boxes overlap.
+		n := len(r.labels)
+		ax, ay := make([]float64, n), make([]float64, n)
+		ws, hs := make([]float64, n), make([]float64, n)
+		for i, label := range r.labels {
+			ax[i], ay[i] = 600*label.x, 400*label.y
+			w, h := label.Extent()
+			ws[i], hs[i] = float64(w), float64(h)
+		}
+		dx, dy := repelBoxes(ax, ay, ws, hs, 0, 0, 600, 400, r.seed, r.iterations)
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				ox := (ws[i]+ws[j])/2 - math.Abs(ax[i]+dx[i]-ax[j]-dx[j])
+				oy := (hs[i]+hs[j])/2 - math.Abs(ay[i]+dy[i]-ay[j]-dy[j])
+				if ox > 0 && oy > 0 {
+					t.Errorf("Labels %q and %q overlap",
+						r.labels[i].text.text, r.labels[j].text.text)
+				}
+			}
+		}
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	type summary struct {
+		Dose      string
+		Supp      string
+		Len       float64
+		Low, High float64
+	}
+	data := []summary{
+		{"0.5", "OJ", 13.2, 10.0, 16.4},
+		{"0.5", "VC", 8.0, 6.2, 9.8},
+		{"1.0", "OJ", 22.7, 19.9, 25.5},
+		{"1.0", "VC", 16.8, 15.0, 18.6},
+		{"2.0", "OJ", 26.1, 24.2, 28.0},
+		{"2.0", "VC", 26.1, 22.2, 30.0},
+	}
+	aes := AesMapping{
+		"x":     "Dose",
+		"y":     "Len",
+		"ymin":  "Low",
+		"ymax":  "High",
+		"color": "Supp",
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	for _, geom := range []Geom{
+		GeomErrorbar{Position: PosDodge, Width: 0.5},
+		GeomLinerange{Position: PosDodge},
+		GeomPointrange{Position: PosDodge},
+		GeomCrossbar{Position: PosDodge},
+	} {
+		plot, err := NewPlot(data, aes)
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = geom.Name()
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: geom.Name(),
+			Geom: geom,
+		})
+		plot.WritePNG(strings.ToLower(geom.Name())+".png", 600, 400)
+
+		// OJ and VC of the same dose are dodged side by side, each
+		// half as wide as the undodged interval.
+		panel := plot.Panels[0][0]
+		layer := panel.Layers[0]
+		fd := layer.Fundamentals[0].Data
+		x, w := fd.Columns["x"].Data, fd.Columns["width"].Data
+		wantW := 0.9 / 2
+		if _, ok := geom.(GeomErrorbar); ok {
+			wantW = 0.5 / 2
+		}
+		for i := 0; i < len(data); i += 2 {
+			if !near(w[i], wantW) || !near(w[i+1], wantW) {
+				t.Errorf("%s: row %d: got widths %.3f, %.3f, want %.3f",
+					geom.Name(), i, w[i], w[i+1], wantW)
+			}
+			if !near(x[i+1]-x[i], wantW) {
+				t.Errorf("%s: row %d: got offset %.3f, want %.3f",
+					geom.Name(), i, x[i+1]-x[i], wantW)
+			}
+		}
+
+		// The legend of color shows the interval glyph for OJ and VC.
+		keys := guides(plot)
+		lines, points, paths := len(keys["plot.GrobLine"]), len(keys["plot.GrobPoint"]),
+			len(keys["plot.GrobPath"])
+		switch geom.(type) {
+		case GeomErrorbar:
+			if lines != 2*3 || points != 0 {
+				t.Errorf("%s: got %d lines and %d points as keys", geom.Name(), lines, points)
+			}
+		case GeomLinerange:
+			if lines != 2 || points != 0 {
+				t.Errorf("%s: got %d lines and %d points as keys", geom.Name(), lines, points)
+			}
+		case GeomPointrange:
+			if lines != 2 || points != 2 {
+				t.Errorf("%s: got %d lines and %d points as keys", geom.Name(), lines, points)
+			}
+		case GeomCrossbar:
+			if lines != 2 || paths != 2 {
+				t.Errorf("%s: got %d lines and %d boxes as keys", geom.Name(), lines, paths)
+			}
+		}
+
+		xf, yf := panel.Scales["x"].Pos, panel.Scales["y"].Pos
+		grobs := layer.Grobs
+		line := func(g Grob, x0, y0, x1, y1 float64) bool {
+			l, ok := g.(GrobLine)
+			return ok && near(l.x0, xf(x0)) && near(l.y0, yf(y0)) &&
+				near(l.x1, xf(x1)) && near(l.y1, yf(y1))
+		}
+		for i, d := range data {
+			xl, xr := x[i]-w[i]/2, x[i]+w[i]/2
+			switch geom.(type) {
+			case GeomErrorbar:
+				if len(grobs) != 3*len(data) {
+					t.Fatalf("%s: got %d grobs", geom.Name(), len(grobs))
+				}
+				if !line(grobs[3*i], x[i], d.Low, x[i], d.High) ||
+					!line(grobs[3*i+1], xl, d.Low, xr, d.Low) ||
+					!line(grobs[3*i+2], xl, d.High, xr, d.High) {
+					t.Errorf("%s: row %d: bad geometry %v", geom.Name(), i, grobs[3*i:3*i+3])
+				}
+			case GeomPointrange:
+				if len(grobs) != 2*len(data) {
+					t.Fatalf("%s: got %d grobs", geom.Name(), len(grobs))
+				}
+				p, ok := grobs[2*i+1].(GrobPoint)
+				if !line(grobs[2*i], x[i], d.Low, x[i], d.High) || !ok ||
+					!near(p.x, xf(x[i])) || !near(p.y, yf(d.Len)) {
+					t.Errorf("%s: row %d: bad geometry %v", geom.Name(), i, grobs[2*i:2*i+2])
+				}
+			case GeomCrossbar:
+				// Unfilled: only the outline and the middle line.
+				if len(grobs) != 2*len(data) {
+					t.Fatalf("%s: got %d grobs", geom.Name(), len(grobs))
+				}
+				box, ok := grobs[2*i].(GrobPath)
+				if !ok || len(box.points) != 5 ||
+					!near(box.points[0].x, xf(xl)) || !near(box.points[0].y, yf(d.Low)) ||
+					!near(box.points[2].x, xf(xr)) || !near(box.points[2].y, yf(d.High)) ||
+					!line(grobs[2*i+1], xl, d.Len, xr, d.Len) {
+					t.Errorf("%s: row %d: bad geometry %v", geom.Name(), i, grobs[2*i:2*i+2])
+				}
+			}
+		}
+	}
+
+	// Horizontal error bars.
+	plot, err := NewPlot(data, AesMapping{
+		"y":     "Dose",
+		"x":     "Len",
+		"xmin":  "Low",
+		"xmax":  "High",
+		"color": "Supp",
+	})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "GeomErrorbarH"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Errorbars",
+		Geom: GeomErrorbarH{Position: PosDodge, Height: 0.4},
+	})
+	plot.WritePNG("geomerrorbarh.png", 600, 400)
+	if n := len(plot.Panels[0][0].Layers[0].Grobs); n != 3*len(data) {
+		t.Errorf("Got %d grobs, want %d", n, 3*len(data))
+	}
+	if n := len(guides(plot)["plot.GrobLine"]); n != 2*3 {
+		t.Errorf("Got %d lines as keys, want 6", n)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	data := make([]Obs, 500)
+	origins := []string{"de", "ch", "at", "it"}
+	for i := range data {
+		data[i].Height = rand.NormFloat64()*0.1 + 1.75
+		data[i].Weight = rand.NormFloat64()*10 + 80
+		data[i].Origin = origins[i%7%4]
+	}
+
+	for _, tc := range []struct {
+		name string
+		aes  AesMapping
+		geom Geom
+	}{
+		{"histogram", AesMapping{"x": "Height"}, GeomHistogram{}},
+		{"histogram-discrete", AesMapping{"x": "Origin"}, GeomHistogram{}},
+		{"histogram-horizontal", AesMapping{"y": "Weight"},
+			GeomHistogram{Horizontal: true, BinWidth: 2.5}},
+		{"histogram-stacked", AesMapping{"x": "Height", "fill": "Origin"},
+			GeomHistogram{Position: PosStack}},
+	} {
+		plot, err := NewPlot(data, tc.aes)
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = tc.name
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Histogram",
+			Geom: tc.geom,
+		})
+		plot.WritePNG(tc.name+".png", 600, 400)
+		if len(plot.Panels[0][0].Layers[0].Grobs) == 0 {
+			t.Errorf("%s: no grobs rendered", tc.name)
+		}
+	}
+
+	// Columns with identity heights and a direct call of Render.
+	plot, err := NewPlot(measurement, AesMapping{"x": "Origin", "y": "Weight"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Columns"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Columns",
+		Geom: GeomCol{Width: 0.5, Position: PosDodge},
+	})
+	plot.WritePNG("columns.png", 600, 400)
+	panel := plot.Panels[0][0]
+	df := NewDataFrame("bars", plot.Pool)
+	df.N = 2
+	df.Columns["x"] = NewField(2, Float, plot.Pool)
+	df.Columns["y"] = NewField(2, Float, plot.Pool)
+	df.Columns["x"].Data[1] = 1
+	df.Columns["y"].Data[0], df.Columns["y"].Data[1] = 80, 90
+	bar := GeomBar{}
+	if n := len(bar.Render(panel, df, bar.Aes(plot))); n != 2 {
+		t.Errorf("Got %d grobs, want 2", n)
+	}
+}
+
+func TestHistogramFacets(t *testing.T) {
+	// The heights of the two origins do not overlap.
+	data := make([]Obs, 200)
+	for i := range data {
+		if i%2 == 0 {
+			data[i] = Obs{Origin: "de", Height: 1.5 + 0.2*rand.Float64()}
+		} else {
+			data[i] = Obs{Origin: "ch", Height: 1.8 + 0.3*rand.Float64()}
+		}
+	}
+	plot, err := NewPlot(data, AesMapping{"x": "Height"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Faceted histogram"
+	plot.Faceting = Faceting{Columns: "Origin"}
+	layer := &Layer{Name: "Histogram", Geom: GeomHistogram{}}
+	plot.Layers = append(plot.Layers, layer)
+	plot.WritePNG("histogram-facets.png", 600, 300)
+
+	if layer.Stat != nil || layer.StatMapping != nil {
+		t.Errorf("Plot layer modified: stat %v, mapping %v", layer.Stat, layer.StatMapping)
+	}
+	var bins []StatBin
+	for _, panel := range plot.Panels[0] {
+		bin, ok := panel.Layers[0].Stat.(StatBin)
+		if !ok || bin.Origin == nil {
+			t.Fatalf("Panel %s: got stat %v", panel.Name, panel.Layers[0].Stat)
+		}
+		bins = append(bins, bin)
+	}
+	if len(bins) != 2 || bins[0].BinWidth != bins[1].BinWidth || *bins[0].Origin != *bins[1].Origin {
+		t.Fatalf("Panels use different bins: %v", bins)
+	}
+	// Bins from the full range of about 0.6, not from the 0.2 or 0.3 of
+	// a single panel.
+	if w := bins[0].BinWidth; w < 0.5/30 || w > 0.6/30 {
+		t.Errorf("Got bin width %.4f, want about %.4f", w, 0.6/30)
+	}
+
+	// All bin centers lie on the same grid.
+	width, origin := bins[0].BinWidth, *bins[0].Origin
+	for _, panel := range plot.Panels[0] {
+		for _, x := range panel.Layers[0].Data.Columns["x"].Data {
+			k := (x - origin) / width
+			if math.Abs(k-math.Floor(k)-0.5) > 1e-6 {
+				t.Errorf("Panel %s: bin center %.4f off grid", panel.Name, x)
+			}
+		}
+	}
+
+	// Rendering again yields the same bins.
+	plot.WritePNG("histogram-facets.png", 600, 300)
+	if bin := plot.Panels[0][0].Layers[0].Stat.(StatBin); *bin.Origin != origin {
+		t.Errorf("Got origin %.4f on second rendering, want %.4f", *bin.Origin, origin)
+	}
+}
+
+func TestBoxplotVariants(t *testing.T) {
+	data := make([]Obs, 300)
+	origins := []string{"de", "ch", "at"}
+	for i := range data {
+		o := i % 3
+		data[i].Origin = origins[o]
+		data[i].Weight = rand.NormFloat64()*float64(5+5*o) + 80
+		if i%10 == 0 {
+			data[i] = Obs{Origin: "it", Weight: rand.NormFloat64()*5 + 70}
+		}
+	}
+	data[1].Weight = 140
+
+	for _, tc := range []struct {
+		name string
+		aes  AesMapping
+		stat StatBoxplot
+		geom GeomBoxplot
+	}{
+		{"boxplot-notched", AesMapping{"x": "Origin", "y": "Weight"},
+			StatBoxplot{}, GeomBoxplot{Notch: true, VarWidth: true}},
+		{"boxplot-percentile", AesMapping{"x": "Origin", "y": "Weight"},
+			StatBoxplot{Percentile: 5}, GeomBoxplot{Width: 0.5}},
+		{"boxplot-horizontal", AesMapping{"y": "Origin", "x": "Weight"},
+			StatBoxplot{Coef: 1, Horizontal: true},
+			GeomBoxplot{Notch: true,
+				OutlierStyle: AesMapping{"shape": "circle", "color": "blue"}}},
+		{"boxplot-horizontal-geom", AesMapping{"y": "Origin", "x": "Weight"},
+			StatBoxplot{}, GeomBoxplot{Horizontal: true}},
+	} {
+		plot, err := NewPlot(data, tc.aes)
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = tc.name
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Boxplot",
+			Stat: tc.stat,
+			Geom: tc.geom,
+		})
+		plot.WritePNG(tc.name+".png", 600, 400)
+		layer := plot.Panels[0][0].Layers[0]
+		if len(layer.Grobs) == 0 {
+			t.Errorf("%s: no grobs rendered", tc.name)
+		}
+		horizontal := tc.aes["y"] == "Origin"
+		if layer.Stat.(StatBoxplot).Horizontal != horizontal ||
+			layer.Geom.(GeomBoxplot).Horizontal != horizontal {
+			t.Errorf("%s: got stat %+v and geom %+v", tc.name, layer.Stat, layer.Geom)
+		}
+	}
+
+	// Dodged boxes of variable width stay centered in their slots.
+	type obs struct {
+		Group, Sex string
+		Value      float64
+	}
+	dodged := []obs{}
+	for i := 0; i < 60; i++ {
+		sex := "m"
+		if i%3 == 0 {
+			sex = "f" // Fewer observations, narrower boxes.
+		}
+		dodged = append(dodged, obs{[]string{"a", "b"}[i%2], sex, float64(i % 7)})
+	}
+	plot, err := NewPlot(dodged, AesMapping{"x": "Group", "y": "Value", "fill": "Sex"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Dodged variable width"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Boxplot",
+		Stat: StatBoxplot{},
+		Geom: GeomBoxplot{Position: PosDodge, VarWidth: true},
+	})
+	plot.WritePNG("boxplot-dodged.png", 600, 400)
+	layer := plot.Panels[0][0].Layers[0]
+	rects := layer.Fundamentals[0].Data
+	xmin, xmax := rects.Columns["xmin"].Data, rects.Columns["xmax"].Data
+	x := layer.Data.Columns["x"].Data
+	seen := map[float64]int{}
+	narrow := 0
+	for i := range xmin {
+		n := seen[x[i]]
+		seen[x[i]]++
+		want := x[i] + (float64(2*n)-1)*0.9/4
+		if got := (xmin[i] + xmax[i]) / 2; math.Abs(got-want) > 1e-9 {
+			t.Errorf("Box %d: got center %.3f, want %.3f", i, got, want)
+		}
+		if w := xmax[i] - xmin[i]; w > 0.9/2+1e-9 {
+			t.Errorf("Box %d: width %.3f exceeds slot", i, w)
+		} else if w < 0.9/2-1e-9 {
+			narrow++
+		}
+	}
+	if narrow == 0 {
+		t.Errorf("No box of reduced width")
+	}
+
+	// Percentile whiskers end at the 10th and 90th percentile.
+	values := make([]float64, 101)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	b := StatBoxplot{Percentile: 10}.compute(values)
+	if b.low != 10 || b.high != 90 || len(b.outliers) != 20 {
+		t.Errorf("Got low=%g high=%g %d outliers, want 10, 90 and 20",
+			b.low, b.high, len(b.outliers))
+	}
+}
+
+func TestArrowsAndJoins(t *testing.T) {
+	type flow struct {
+		X, Y, XEnd, YEnd float64
+		Kind             string
+	}
+	data := []flow{
+		{1, 1, 3, 2, "in"},
+		{3, 2, 5, 1, "out"},
+		{1, 3, 5, 3, "in"},
+		{5, 4, 1, 4, "out"},
+	}
+	plot, err := NewPlot(data, AesMapping{
+		"x":     "X",
+		"y":     "Y",
+		"xend":  "XEnd",
+		"yend":  "YEnd",
+		"color": "Kind",
+	})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Arrows and Joins"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Flow",
+		Geom: GeomSegment{
+			Arrow: &Arrow{Closed: true, Ends: ArrowBoth},
+			Style: AesMapping{"size": "3"},
+		},
+	})
+	plot.Layers = append(plot.Layers, &Layer{
+		Name:        "Zigzag",
+		DataMapping: AesMapping{"color": ""},
+		Geom: GeomLine{
+			Arrow:    &Arrow{Angle: 20, Length: vg.Length(20)},
+			LineCap:  RoundCap,
+			LineJoin: MiterJoin,
+			Style:    AesMapping{"size": "8", "color": "gray40"},
+		},
+	})
+	plot.WritePNG("arrows.png", 600, 400)
+
+	grobs := plot.Panels[0][0].Layers[0].Grobs
+	if len(grobs) != len(data) {
+		t.Fatalf("Got %d grobs, want %d", len(grobs), len(data))
+	}
+	if line := grobs[0].(GrobLine); line.arrow == nil || line.arrow.Ends != ArrowBoth {
+		t.Errorf("Missing arrow on segment: %v", line.arrow)
+	}
+
+	// Pieces of a line keep only the heads at their own ends.
+	arrow := &Arrow{Ends: ArrowBoth}
+	if a := arrow.At(true, false); a == nil || a.Ends != ArrowFirst {
+		t.Errorf("Got %v, want first", a)
+	}
+	if a := arrow.At(false, false); a != nil {
+		t.Errorf("Got %v, want nil", a)
+	}
+	if a := (&Arrow{Ends: ArrowFirst}).At(false, true); a != nil {
+		t.Errorf("Got %v, want nil", a)
+	}
+}
+
+func TestLogScale(t *testing.T) {
+	type growth struct{ Day, Count float64 }
+	data := []growth{}
+	for d := 0.0; d < 30; d++ {
+		data = append(data, growth{d, 3 * math.Pow(1.4, d) * (1 + rand.Float64()/5)})
+	}
+	plot, err := NewPlot(data, AesMapping{"x": "Day", "y": "Count"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Log10 y-scale"
+	ys := NewScale("y", "Count", Float)
+	ys.Transform = &Log10Scale
+	plot.Scales["y"] = ys
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{},
+	})
+	plot.WritePNG("logscale.png", 600, 400)
+
+	if len(ys.MinorBreaks) == 0 {
+		t.Errorf("No minor breaks")
+	}
+	for i, l := range ys.Labels {
+		if l != []string{"10", "100", "1000", "10000", "100000"}[i] {
+			t.Errorf("Got labels %v", ys.Labels)
+			break
+		}
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	type measurement struct {
+		Time     time.Time
+		Temp     float64
+		Duration int64
+	}
+	start := time.Date(2013, 3, 4, 21, 7, 0, 0, time.UTC)
+	data := []measurement{}
+	for i := 0; i < 100; i++ {
+		data = append(data, measurement{
+			Time:     start.Add(time.Duration(i) * 11 * time.Minute),
+			Temp:     10 + 5*math.Sin(float64(i)/15),
+			Duration: int64(time.Duration(i*i) * time.Second),
+		})
+	}
+
+	plot, err := NewPlot(data, AesMapping{"x": "Time", "y": "Temp"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Time series"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Line",
+		Geom: GeomLine{},
+	})
+	plot.WritePNG("timeseries.png", 600, 400)
+	xs := plot.Scales["x"]
+	if want := "00:00|06:00|12:00"; strings.Join(xs.Labels, "|") != want {
+		t.Errorf("Got labels %q, want %s", xs.Labels, want)
+	}
+	if len(xs.MinorBreaks) == 0 {
+		t.Errorf("No minor breaks")
+	}
+
+	plot, err = NewPlot(data, AesMapping{"x": "Time", "y": "Duration"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Durations"
+	ys := NewScale("y", "Duration", Int)
+	ys.Duration = time.Nanosecond
+	plot.Scales["y"] = ys
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{},
+	})
+	plot.WritePNG("durations.png", 600, 400)
+	if strings.Join(ys.Labels, "|") != "0s|1h|2h" {
+		t.Errorf("Got labels %q", ys.Labels)
+	}
+}
+
+func TestManualScales(t *testing.T) {
+	type fruit struct {
+		Name   string
+		Weight float64
+		Price  float64
+		Color  string
+	}
+	data := []fruit{}
+	for i, name := range []string{"apple", "banana", "cherry", "apple", "banana", "cherry", "kiwi"} {
+		data = append(data, fruit{name, float64(10 + 7*i), float64(2 + i%3), []string{"#cc0000", "#ddcc00", "#880000", "#00aa00"}[i%4]})
+	}
+
+	plot, err := NewPlot(data, AesMapping{"x": "Weight", "y": "Price", "color": "Name", "shape": "Name"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Manual color and shape scales"
+	colors := NewScale("color", "Fruit", String)
+	colors.Values = map[string]string{"apple": "#00aa00", "banana": "#ddcc00", "cherry": "#aa0000"}
+	colors.Fallback = "gray50"
+	shapes := NewScale("shape", "Fruit", String)
+	shapes.Values = map[string]string{"apple": "circle", "banana": "delta", "cherry": "solidcircle", "kiwi": "star"}
+	plot.Scales["color"] = colors
+	plot.Scales["shape"] = shapes
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{Style: AesMapping{"size": "8"}},
+	})
+	plot.WritePNG("manual.png", 600, 400)
+
+	kiwi := float64(plot.Pool.Find("kiwi"))
+	if got := Color2String(colors.Color(kiwi)); got != "#7f7f7f" {
+		t.Errorf("Got fallback color %s", got)
+	}
+	if got := PointShape(shapes.Style(kiwi)); got != StarPoint {
+		t.Errorf("Got shape %s for kiwi", got)
+	}
+
+	// Identity scale: The colors are taken from the data.
+	plot, err = NewPlot(data, AesMapping{"x": "Weight", "y": "Price", "color": "Color"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Identity color scale"
+	identity := NewScale("color", "Color", String)
+	identity.Identity = true
+	plot.Scales["color"] = identity
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{Style: AesMapping{"size": "8", "shape": "solidcircle"}},
+	})
+	plot.WritePNG("identity.png", 600, 400)
+	if got := Color2String(identity.Color(float64(plot.Pool.Find("#ddcc00")))); got != "#ddcc00" {
+		t.Errorf("Got identity color %s", got)
+	}
+}
+
+func TestPalettes(t *testing.T) {
+	type obs struct {
+		X, Y    int
+		Anomaly float64
+		Group   string
+	}
+	data := []obs{}
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 20; y++ {
+			a := 3*math.Sin(float64(x)/5)*math.Cos(float64(y)/4) + 1
+			data = append(data, obs{x, y, a, fmt.Sprintf("G%d", (x/6+y/5)%5)})
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		fill    string
+		palette Palette
+	}{
+		{"viridis", "Anomaly", Viridis},
+		{"diverging", "Anomaly", Gradient{
+			Low: String2Color("#2166ac"), Mid: String2Color("#f7f7f7"),
+			High: String2Color("#b2182b"), Midpoint: 0,
+		}},
+		{"brewer-set2", "Group", BrewerPalettes["Set2"]},
+	} {
+		plot, err := NewPlot(data, AesMapping{"x": "X", "y": "Y", "fill": tc.fill})
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = "Palette " + tc.name
+		fill := NewScale("fill", tc.fill, Float)
+		if tc.fill == "Group" {
+			fill = NewScale("fill", tc.fill, String)
+		}
+		fill.Palette = tc.palette
+		plot.Scales["fill"] = fill
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Tiles",
+			Geom: GeomTile{},
+		})
+		plot.WritePNG("palette-"+tc.name+".png", 600, 400)
+	}
+}
+
+func TestOOBAndZoom(t *testing.T) {
+	type sample struct {
+		Group string
+		Value float64
+	}
+	data := []sample{}
+	for i := 0; i < 200; i++ {
+		g := []string{"a", "b"}[i%2]
+		v := float64(i % 100) // upper hinge about 75
+		if i%25 == 0 {
+			v += 100 // outlier
+		}
+		data = append(data, sample{g, v})
+	}
+
+	newPlot := func(title string) *Plot {
+		return newTestPlot(t, data, AesMapping{"x": "Group", "y": "Value"}, title,
+			&Layer{Name: "Boxplot", Stat: StatBoxplot{}, Geom: GeomBoxplot{}})
+	}
+	upperHinge := func(plot *Plot) float64 {
+		return plot.Panels[0][0].Layers[0].Data.Columns["q3"].Data[0]
+	}
+
+	// Zooming does not change the stat.
+	plot := newPlot("Zoom to [40,60]")
+	plot.Compute()
+	full := upperHinge(plot)
+	plot = newPlot("Zoom to [40,60]")
+	plot.Coord = CoordCartesian{YLim: [2]float64{40, 60}}
+	plot.WritePNG("zoom.png", 400, 400)
+	if got := upperHinge(plot); got != full {
+		t.Errorf("Zoom changed upper hinge from %.2f to %.2f", full, got)
+	}
+	ys := plot.Panels[0][0].Scales["y"]
+	if ys.Min >= 40 || ys.Max <= 60 || ys.Max > 62 {
+		t.Errorf("Got y range [%.2f,%.2f]", ys.Min, ys.Max)
+	}
+
+	// Zoom limits are given in data units of transformed scales.
+	plot = newPlot("Zoom to [40,60] on log10 scale")
+	plot.Coord = CoordFlip{YLim: [2]float64{40, 60}}
+	ys = NewScale("y", "Value", Float)
+	ys.Transform = &Log10Scale
+	ys.ExpandRel = 0
+	plot.Scales["y"] = ys
+	plot.WritePNG("zoom-log.png", 400, 400)
+	if math.Abs(ys.Min-math.Log10(40)) > 1e-9 || math.Abs(ys.Max-math.Log10(60)) > 1e-9 {
+		t.Errorf("Got log y range [%.3f,%.3f]", ys.Min, ys.Max)
+	}
+
+	// Limits keep the data by default as they always did.
+	plot = newPlot("Limits [40,60]")
+	ys = NewScale("y", "Value", Float)
+	ys.FixMin, ys.FixMax = 40, 60
+	plot.Scales["y"] = ys
+	plot.Compute()
+	if got := upperHinge(plot); ys.OOB != Keep || got != full {
+		t.Errorf("Default OOB %d: Got upper hinge %.2f, want %.2f", ys.OOB, got, full)
+	}
+
+	// Censoring and squishing change the stat.
+	for _, oob := range []OOBPolicy{Censor, Squish, Keep} {
+		plot = newPlot(fmt.Sprintf("Limits [40,60], OOB %d", oob))
+		ys = NewScale("y", "Value", Float)
+		ys.FixMin, ys.FixMax = 40, 60
+		ys.OOB = oob
+		plot.Scales["y"] = ys
+		plot.WritePNG(fmt.Sprintf("oob-%d.png", oob), 400, 400)
+		got := upperHinge(plot)
+		switch oob {
+		case Censor, Squish:
+			if got > 60 || got == full {
+				t.Errorf("OOB %d: Got upper hinge %.2f", oob, got)
+			}
+		case Keep:
+			if got != full {
+				t.Errorf("OOB %d: Got upper hinge %.2f, want %.2f", oob, got, full)
+			}
+		}
+	}
+}
+
+func TestReverseAndSecondaryAxes(t *testing.T) {
+	type reading struct {
+		Depth, Temperature float64
+	}
+	data := []reading{}
+	for d := 0.0; d <= 100; d += 5 {
+		data = append(data, reading{d, 4 + 16*math.Exp(-d/30)})
+	}
+	plot, err := NewPlot(data, AesMapping{"x": "Temperature", "y": "Depth"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Reversed y, secondary x in °F"
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Profile",
+		Geom: GeomLine{},
+	})
+	xs := NewScale("x", "Temperature [°C]", Float)
+	xs.Secondary = &SecondaryAxis{
+		Name:  "Temperature [°F]",
+		Trans: func(c float64) float64 { return 1.8*c + 32 },
+	}
+	plot.Scales["x"] = xs
+	ys := NewScale("y", "Depth [m]", Float)
+	ys.Transform = &ReverseScale
+	ys.Secondary = DupAxis()
+	plot.Scales["y"] = ys
+	plot.WritePNG("secondary.png", 500, 400)
+
+	// Depth increases downwards but is labeled positive.
+	if len(ys.Labels) < 2 {
+		t.Fatalf("Got y breaks %v", ys.Breaks)
+	}
+	if ys.Labels[0] != "100" || ys.Labels[len(ys.Labels)-1] != "0" {
+		t.Errorf("Got y labels %q", ys.Labels)
+	}
+	if len(ys.secLabels) != len(ys.Labels) || plot.Grobs["Y2-Label"] == nil {
+		t.Errorf("Duplicated axis: got labels %q", ys.secLabels)
+	}
+
+	// The °F tics are at the positions of the corresponding °C values.
+	if len(xs.secBreaks) < 2 {
+		t.Fatalf("Got secondary x breaks %v", xs.secBreaks)
+	}
+	for i, x := range xs.secBreaks {
+		f, err := strconv.ParseFloat(xs.secLabels[i], 64)
+		if err != nil {
+			t.Fatalf("Bad label %q: %s", xs.secLabels[i], err)
+		}
+		if c := (f - 32) / 1.8; math.Abs(c-x) > 1e-6 {
+			t.Errorf("Label %q at %.4f, want %.4f", xs.secLabels[i], x, c)
+		}
+	}
+
+	// The secondary axes are drawn into the space reserved between the
+	// panel and the titles of the secondary axes.
+	near := func(a, b vg.Length) bool { return math.Abs(float64(a-b)) < 1e-6 }
+	pvp := plot.Viewports["Panel-0,0"]
+	top, right := plot.Panels[0][0].secondaryViewports()
+	if !near(top.Y0, pvp.Y0+pvp.Height) || top.Height <= 0 ||
+		top.Y0+top.Height > plot.Viewports["X2-Label"].Y0+1e-6 {
+		t.Errorf("Secondary x axis at %.1f+%.1f, panel ends at %.1f",
+			top.Y0, top.Height, pvp.Y0+pvp.Height)
+	}
+	if !near(right.X0, pvp.X0+pvp.Width) || right.Width <= 0 ||
+		right.X0+right.Width > plot.Viewports["Y2-Label"].X0+1e-6 {
+		t.Errorf("Secondary y axis at %.1f+%.1f, panel ends at %.1f",
+			right.X0, right.Width, pvp.X0+pvp.Width)
+	}
+
+	// Limits and breaks of the reversed scale are given in data units.
+	plot, err = NewPlot(data, AesMapping{"x": "Temperature", "y": "Depth"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Reversed y with limits and breaks"
+	plot.Layers = append(plot.Layers, &Layer{Name: "Profile", Geom: GeomLine{}})
+	ys = NewScale("y", "Depth [m]", Float)
+	ys.Transform = &ReverseScale
+	ys.FixMin, ys.FixMax = 0, 120
+	ys.Breaks = []float64{0, 50, 100}
+	ys.ExpandRel = 0
+	plot.Scales["y"] = ys
+	plot.WritePNG("reverse-limits.png", 500, 400)
+	if ys.Min != -120 || ys.Max != 0 {
+		t.Errorf("Got y range [%g,%g], want [-120,0]", ys.Min, ys.Max)
+	}
+	if got := strings.Join(ys.Labels, " "); got != "100 50 0" {
+		t.Errorf("Got y labels %q", got)
+	}
+}
+
+func TestTransformedScales(t *testing.T) {
+	type point struct{ X, Y float64 }
+	data := []point{}
+	for i := -40; i <= 40; i++ {
+		x := math.Pow(1.2, math.Abs(float64(i))) * float64(i) / 4
+		data = append(data, point{x, float64(i*i) - 4})
+	}
+	plot, err := NewPlot(data, AesMapping{"x": "X", "y": "Y"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Pseudo-log x and log10 y"
+	xs := NewScale("x", "X", Float)
+	xs.Transform = Transforms["pseudo_log"]
+	plot.Scales["x"] = xs
+	ys := NewScale("y", "Y", Float)
+	ys.Transform = Transforms["log10"]
+	plot.Scales["y"] = ys
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{},
+	})
+	plot.WritePNG("transforms.png", 600, 400)
+
+	// The five points with Y <= 0 are removed by the log10 scale.
+	if n := plot.Panels[0][0].Layers[0].Data.N; n != len(data)-5 {
+		t.Errorf("Got %d points, want %d", n, len(data)-5)
+	}
+	if math.IsInf(ys.Min, 0) || math.IsNaN(ys.Min) {
+		t.Errorf("Bad y range [%g,%g]", ys.Min, ys.Max)
+	}
+	if got := strings.Join(xs.Labels, " "); got != "-10000 -1000 -100 -10 0 10 100 1000 10000" {
+		t.Errorf("Got x labels %s", got)
+	}
+}
+
+func TestBinnedScales(t *testing.T) {
+	type latency struct {
+		Hour, Day int
+		Latency   float64
+	}
+	data := []latency{}
+	for d := 0; d < 7; d++ {
+		for h := 0; h < 24; h++ {
+			l := 50 + 40*math.Sin(float64(h)/24*2*math.Pi) + 5*float64(d)
+			data = append(data, latency{h, d, l})
+		}
+	}
+	newPlot := func(title string, fill *Scale) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "Hour", "y": "Day", "fill": "Latency"}, title,
+			&Layer{Name: "Tiles", Geom: GeomTile{}})
+		plot.Scales["fill"] = fill
+		return plot
+	}
+
+	// Nice breaks: Each bin gets one color.
+	fill := NewScale("fill", "Latency", Float)
+	fill.Binned = true
+	fill.Palette = Viridis
+	newPlot("Binned fill", fill).WritePNG("binned.png", 800, 400)
+	if !sameFloats(fill.Breaks, []float64{25, 50, 75, 100}) {
+		t.Errorf("Got breaks %v", fill.Breaks)
+	}
+	if fill.Color(26) != fill.Color(49) || fill.Color(49) == fill.Color(50) {
+		t.Errorf("Colors do not change at bin boundaries")
+	}
+	if got := strings.Join(fill.binLabels(), "|"); got != "< 25|25 – 50|50 – 75|75 – 100|≥ 100" {
+		t.Errorf("Got bin labels %s", got)
+	}
+
+	// Unsorted breaks are sorted before labeling.
+	fill = NewScale("fill", "Latency", Float)
+	fill.Binned = true
+	fill.Breaks = []float64{80, 40, 60}
+	newPlot("Unsorted breaks", fill).Compute()
+	if got := strings.Join(fill.binLabels(), "|"); got != "< 40|40 – 60|60 – 80|≥ 80" {
+		t.Errorf("Got bin labels %s for unsorted breaks", got)
+	}
+	fill = NewScale("fill", "Latency", Float)
+	fill.Binned = true
+	fill.Breaks = []float64{80, 40, 60}
+	fill.Labels = []string{"high", "low", "medium"}
+	newPlot("Unsorted labeled breaks", fill).Compute()
+	if got := strings.Join(fill.Labels, "|"); got != "low|medium|high" {
+		t.Errorf("Got labels %s for unsorted breaks", got)
+	}
+
+	// Quantile bins contain about the same number of values.
+	fill = NewScale("fill", "Latency", Float)
+	fill.Binned, fill.Quantile, fill.NBins = true, true, 4
+	fill.Palette = Viridis
+	newPlot("Quantile binned fill", fill).WritePNG("binned-quantile.png", 800, 400)
+	if len(fill.Breaks) != 3 {
+		t.Fatalf("Got breaks %v", fill.Breaks)
+	}
+	counts := make([]int, 4)
+	for _, d := range data {
+		counts[fill.bin(d.Latency)]++
+	}
+	for i, c := range counts {
+		if c < len(data)/4-3 || c > len(data)/4+3 {
+			t.Errorf("Bin %d contains %d values, want about %d", i, c, len(data)/4)
+		}
+	}
+}
+
+func TestCoordFlip(t *testing.T) {
+	type sample struct {
+		Group string
+		Value float64
+	}
+	data := []sample{}
+	for i := 0; i < 150; i++ {
+		g := []string{"alpha", "beta", "gamma"}[i%3]
+		data = append(data, sample{g, float64(10*(i%3)) + 5*rand.NormFloat64()})
+	}
+	newPlot := func(coord Coord) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "Group", "y": "Value"}, "Boxplot and rug",
+			&Layer{Name: "Boxplot", Stat: StatBoxplot{}, Geom: GeomBoxplot{}},
+			&Layer{Name: "Rug", Geom: GeomRug{}})
+		plot.Coord = coord
+		plot.MarginalY = &Marginal{Type: MarginalDensity}
+		return plot
+	}
+
+	plain := newPlot(nil)
+	plain.WritePNG("coord-cartesian.png", 500, 400)
+	flipped := newPlot(CoordFlip{})
+	flipped.WritePNG("coord-flip.png", 500, 400)
+
+	// Flipping exchanges x and y of all grobs.
+	pg := plain.Panels[0][0].Layers[0].Grobs
+	fg := flipped.Panels[0][0].Layers[0].Grobs
+	if len(pg) != len(fg) || len(pg) == 0 {
+		t.Fatalf("Got %d and %d grobs", len(pg), len(fg))
+	}
+	for i := range pg {
+		if got, want := fg[i].String(), transformGrob(pg[i], CoordFlip{}).String(); got != want {
+			t.Errorf("Grob %d: got %s, want %s", i, got, want)
+		}
+	}
+
+	// The y scale is the horizontal axis.
+	if got := flipped.Grobs["X-Label"].(GrobText).text; got != "Value" {
+		t.Errorf("Got horizontal axis title %q", got)
+	}
+	if len(flipped.Panels[0][0].Tmg) == 0 || len(flipped.Panels[0][0].Rmg) != 0 {
+		t.Errorf("Marginal of y not on top")
+	}
+
+	// Rasters are transposed.
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255}) // upper left: xmin, ymax
+	img.Set(2, 1, color.NRGBA{0, 0, 255, 255}) // lower right: xmax, ymin
+	raster := transformGrob(GrobRaster{xmin: 0, ymin: 0, xmax: 0.3, ymax: 0.2, image: img}, CoordFlip{}).(GrobRaster)
+	if b := raster.image.Bounds(); b.Dx() != 2 || b.Dy() != 3 || raster.xmax != 0.2 || raster.ymax != 0.3 {
+		t.Fatalf("Got %s", raster)
+	}
+	// Red (x=xmin, y=ymax) is now at x=ymax, y=xmin: lower right.
+	if r, _, _, _ := raster.image.At(1, 2).RGBA(); r != 0xffff {
+		t.Errorf("Red pixel misplaced")
+	}
+	if _, _, b, _ := raster.image.At(0, 0).RGBA(); b != 0xffff {
+		t.Errorf("Blue pixel misplaced")
+	}
+}
+
+func TestCoordPolar(t *testing.T) {
+	type share struct {
+		Chart, Browser string
+		Percent        float64
+	}
+	shares := []share{
+		{"all", "Chrome", 64}, {"all", "Safari", 19},
+		{"all", "Edge", 5}, {"all", "Firefox", 3}, {"all", "Other", 9},
+	}
+	for _, inner := range []float64{0, 0.5} {
+		plot, err := NewPlot(shares, AesMapping{"x": "Chart", "y": "Percent", "fill": "Browser"})
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Title = fmt.Sprintf("Pie, inner radius %.1f", inner)
+		plot.Coord = CoordPolar{Theta: "y", InnerRadius: inner}
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Bars",
+			Geom: GeomBar{Position: PosStack},
+		})
+		plot.WritePNG(fmt.Sprintf("pie-%.1f.png", inner), 500, 400)
+
+		// The wedges fill the whole ring between their radii.
+		sum, r0, r1 := 0.0, 1.0, 0.0
+		for _, g := range plot.Panels[0][0].Layers[0].Grobs {
+			poly, ok := g.(GrobPolygon)
+			if !ok {
+				continue
+			}
+			sum += polygonArea(poly.points)
+			for _, p := range poly.points {
+				r := math.Hypot(p.x-0.5, p.y-0.5)
+				r0, r1 = math.Min(r0, r), math.Max(r1, r)
+			}
+		}
+		if r0 < inner*polarRadius-1e-9 || r1 > polarRadius+1e-9 {
+			t.Errorf("Inner radius %.1f: Got radii %.3f to %.3f", inner, r0, r1)
+		}
+		if want := math.Pi * (r1*r1 - r0*r0); math.Abs(sum-want) > 0.01*want {
+			t.Errorf("Inner radius %.1f: Got area %.4f, want %.4f", inner, sum, want)
+		}
+	}
+
+	// Radar chart: Arcs through the scores on a discrete theta.
+	type score struct {
+		Skill string
+		Score float64
+	}
+	scores := []score{{"Go", 9}, {"SQL", 6}, {"CSS", 3}, {"Math", 7}, {"Ops", 5}}
+	plot, err := NewPlot(scores, AesMapping{"x": "Skill", "y": "Score"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Title = "Radar"
+	plot.Coord = CoordPolar{}
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Arcs",
+		Geom: GeomLine{Style: AesMapping{"color": "#4682b4", "size": "2"}},
+	})
+	plot.Layers = append(plot.Layers, &Layer{
+		Name: "Points",
+		Geom: GeomPoint{},
+	})
+	plot.WritePNG("radar.png", 500, 500)
+	for _, g := range plot.Panels[0][0].Layers[1].Grobs {
+		p := g.(GrobPoint)
+		if r := math.Hypot(p.x-0.5, p.y-0.5); r > polarRadius+1e-9 {
+			t.Errorf("Point %s outside circle", p)
+		}
+	}
+}
+
+// polygonArea returns the area of the simple polygon points.
+func polygonArea(points []struct{ x, y float64 }) float64 {
+	a := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return math.Abs(a) / 2
+}
+
+func TestAspectRatio(t *testing.T) {
+	type obs struct {
+		Lab, Sample string
+		Measured    float64
+		True        float64
+	}
+	data := []obs{}
+	for i := 0; i < 20; i++ {
+		x := float64(i) / 2
+		data = append(data,
+			obs{"A", "1", x + 0.2*math.Sin(x), x / 2},
+			obs{"B", "1", x - 0.3*math.Cos(x), x / 2},
+			obs{"A", "2", x + 0.1, x / 2},
+			obs{"B", "2", x, x / 2})
+	}
+
+	newPlot := func(title string, coord Coord, aspect float64) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "Measured", "y": "True"}, title,
+			&Layer{Name: "Points", Geom: GeomPoint{}})
+		plot.Coord = coord
+		plot.Aspect = aspect
+		plot.Faceting = Faceting{Columns: "Lab", Rows: "Sample"}
+		xs := NewScale("x", "Measured", Float)
+		xs.ExpandRel, xs.FixMin, xs.FixMax = 0, 0, 10
+		plot.Scales["x"] = xs
+		ys := NewScale("y", "True", Float)
+		ys.ExpandRel, ys.FixMin, ys.FixMax = 0, 0, 5
+		plot.Scales["y"] = ys
+		plot.WritePNG(title+".png", 600, 500)
+		return plot
+	}
+	// center returns the center of the grid of panels.
+	center := func(plot *Plot) (float64, float64) {
+		ll, ur := plot.Viewports["Panel-0,0"], plot.Viewports["Panel-1,1"]
+		return float64(ll.X0+ur.X0+ur.Width) / 2, float64(ll.Y0+ur.Y0+ur.Height) / 2
+	}
+
+	cx, cy := center(newPlot("aspect-free", nil, 0))
+	for _, tc := range []struct {
+		name   string
+		coord  Coord
+		aspect float64
+		want   float64
+	}{
+		{"aspect-equal", CoordFixed{}, 0, 0.5},
+		{"aspect-ratio", CoordFixed{Ratio: 3}, 0, 1.5},
+		{"aspect-fixed", nil, 0.25, 0.25},
+	} {
+		plot := newPlot(tc.name, tc.coord, tc.aspect)
+		vp := plot.Viewports["Panel-0,0"]
+		if got := float64(vp.Height / vp.Width); math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("%s: Got aspect %.4f, want %.4f", tc.name, got, tc.want)
+		}
+		if x, y := center(plot); math.Abs(x-cx) > 0.01 || math.Abs(y-cy) > 0.01 {
+			t.Errorf("%s: Got center (%.2f,%.2f), want (%.2f,%.2f)", tc.name, x, y, cx, cy)
+		}
+	}
+
+	// Free scales are ignored for a fixed ratio: Panels share the scales
+	// and all have the same aspect.
+	plot, err := NewPlot(data, AesMapping{"x": "Measured", "y": "True"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Coord = CoordFixed{}
+	plot.Faceting = Faceting{Columns: "Lab", Rows: "Sample", FreeScale: "free"}
+	plot.Layers = append(plot.Layers, &Layer{Name: "Points", Geom: GeomPoint{}})
+	plot.WritePNG("aspect-free-scales.png", 600, 500)
+	want := plot.Panels[0][0].Scales["x"]
+	for r := range plot.Panels {
+		for c, panel := range plot.Panels[r] {
+			if panel.Scales["x"] != want {
+				t.Errorf("Panel %d,%d has own x scale", r, c)
+			}
+			vp := plot.Viewports[fmt.Sprintf("Panel-%d,%d", r, c)]
+			if got := float64(vp.Height / vp.Width); math.Abs(got-0.5) > 0.05 {
+				t.Errorf("Panel %d,%d: Got aspect %.4f, want about 0.5", r, c, got)
+			}
+		}
+	}
+
+	// Degenerated scales do not fix the aspect ratio.
+	panel := &Panel{Scales: map[string]*Scale{
+		"x": &Scale{Min: 1, Max: 1}, "y": &Scale{Min: 0, Max: 5},
+	}}
+	if got := (CoordFixed{}).aspect(panel); got != 0 {
+		t.Errorf("Got aspect %g for zero range x scale", got)
+	}
+}
+
+const testGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"name": "North"},
+     "geometry": {"type": "Polygon", "coordinates": [
+       [[5, 50], [15, 50], [15, 55], [5, 55], [5, 50]],
+       [[8, 51], [10, 51], [10, 53], [8, 51]]]}},
+    {"type": "Feature", "id": 7, "properties": {},
+     "geometry": {"type": "MultiPolygon", "coordinates": [
+       [[[5, 45], [15, 45], [10, 49], [5, 45]]],
+       [[[16, 46], [19, 46], [19, 48], [16, 46]]]]}}
+  ]
+}`
+
+func TestReadGeoJSON(t *testing.T) {
+	df, err := ReadGeoJSON(strings.NewReader(testGeoJSON), NewStringPool())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if df.N != 5+4+4+4 {
+		t.Errorf("Got %d rows, want 17", df.N)
+	}
+	feature := df.Columns["Feature"]
+	if got := feature.String(feature.Data[0]); got != "North" {
+		t.Errorf("Got feature %q, want North", got)
+	}
+	if got := feature.String(feature.Data[df.N-1]); got != "7" {
+		t.Errorf("Got feature %q, want 7", got)
+	}
+	group, piece := df.Columns["Group"].Data, df.Columns["Piece"].Data
+	if group[df.N-1] != 3 || piece[df.N-1] != 1 || piece[5] != 1 {
+		t.Errorf("Got groups %v, pieces %v", group, piece)
+	}
+
+	_, err = ReadGeoJSON(strings.NewReader(`{"type": "Circle"}`), NewStringPool())
+	if err == nil || !strings.Contains(err.Error(), "Circle") {
+		t.Errorf("Got error %v for unsupported type", err)
+	}
+}
+
+func TestCoordMap(t *testing.T) {
+	outlines, err := ReadGeoJSON(strings.NewReader(testGeoJSON), NewStringPool())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	type fix struct{ Lon, Lat float64 }
+	track := []fix{}
+	for i := 0; i <= 10; i++ {
+		track = append(track, fix{4 + 1.5*float64(i), 44 + 1.2*float64(i)})
+	}
+
+	for _, tc := range []struct {
+		name       string
+		projection Projection
+	}{
+		{"mercator", nil},
+		{"equirectangular", Equirectangular{Auto: true}},
+		{"lambert", LambertConformalConic{Auto: true}},
+		{"orthographic", Orthographic{Auto: true}},
+	} {
+		plot, err := NewPlot(outlines, AesMapping{"x": "Lon", "y": "Lat", "group": "Group"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		plot.Title = "Map, " + tc.name
+		trackData, err := NewDataFrameFrom(track, outlines.Pool)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		plot.Coord = CoordMap{Projection: tc.projection}
+		plot.Layers = append(plot.Layers, &Layer{
+			Name: "Outlines",
+			Geom: GeomPolygon{Style: AesMapping{"fill": "#c8dcb4", "color": "gray30"}},
+		})
+		plot.Layers = append(plot.Layers, &Layer{
+			Name:        "Track",
+			Data:        trackData,
+			DataMapping: AesMapping{"x": "Lon", "y": "Lat", "group": ""},
+			Geom:        GeomLine{Style: AesMapping{"color": "red"}},
+		})
+		plot.WritePNG("map-"+tc.name+".png", 500, 500)
+
+		panel := plot.Panels[0][0]
+		coord := panel.coord()
+		// The plotted area fills the panel.
+		for _, p := range [][4]float64{{0, 0, 0, 0}, {1, 1, 1, 1}} {
+			if x, y := coord.Transform(p[0], p[1]); tc.name != "lambert" && tc.name != "orthographic" &&
+				(math.Abs(x-p[2]) > 1e-9 || math.Abs(y-p[3]) > 1e-9) {
+				t.Errorf("%s: Transform(%g,%g)=(%g,%g), want (%g,%g)", tc.name, p[0], p[1], x, y, p[2], p[3])
+			}
+		}
+		// Straight edges are densified.
+		poly := panel.Layers[0].Grobs[0].(GrobPolygon)
+		if len(poly.points) < 50 {
+			t.Errorf("%s: Got %d points in polygon", tc.name, len(poly.points))
+		}
+		// Panels keep the aspect ratio of the projection.
+		vp := plot.Viewports["Panel-0,0"]
+		if got, want := float64(vp.Height/vp.Width), plot.aspect(); math.Abs(got-want) > 1e-6 {
+			t.Errorf("%s: Got aspect %.4f, want %.4f", tc.name, got, want)
+		}
+	}
+
+	// Explicit parameters are kept, even if zero.
+	if p := (Equirectangular{}).fit(5, 15, 40, 60).(Equirectangular); p.Lat0 != 0 {
+		t.Errorf("Got Lat0 %g, want 0", p.Lat0)
+	}
+	if p := (Equirectangular{Auto: true}).fit(5, 15, 40, 60).(Equirectangular); p.Lat0 != 50 {
+		t.Errorf("Got Lat0 %g, want 50", p.Lat0)
+	}
+	if p := (Orthographic{}).fit(5, 15, 40, 60).(Orthographic); p.Lon0 != 0 || p.Lat0 != 0 {
+		t.Errorf("Got center (%g,%g), want (0,0)", p.Lon0, p.Lat0)
+	}
+	lcc := LambertConformalConic{Lon0: 0, Lat1: 0, Lat2: 30}
+	if p := lcc.fit(5, 15, 40, 60).(LambertConformalConic); p.Lon0 != 0 || p.Lat1 != 0 {
+		t.Errorf("Got Lon0 %g, Lat1 %g, want 0, 0", p.Lon0, p.Lat1)
+	}
+}
+
+func TestWrappedFacets(t *testing.T) {
+	type reading struct {
+		Station, Sensor string
+		Hour            int
+		Temp            float64
+	}
+	stations := []string{"Alp", "Bern", "Chur", "Davos", "Erl", "Fiesch", "Genf"}
+	data := []reading{}
+	for s, station := range stations {
+		for h := 0; h < 24; h++ {
+			temp := float64(5*s) + 4*math.Sin(float64(h)/24*2*math.Pi)
+			data = append(data,
+				reading{station, "inside", h, temp + 10},
+				reading{station, "outside", h, temp})
+		}
+	}
+
+	newPlot := func(title string) *Plot {
+		return newTestPlot(t, data, AesMapping{"x": "Hour", "y": "Temp"}, title,
+			&Layer{Name: "Lines", Geom: GeomLine{}})
+	}
+
+	plot := newPlot("Wrapped, free y")
+	plot.Aes["group"] = "Sensor"
+	plot.Faceting = Faceting{Wrap: []string{"Station"}, NCol: 3, FreeScale: "y"}
+	plot.WritePNG("wrap-free.png", 700, 600)
+	if len(plot.Panels) != 3 || len(plot.Panels[0]) != 3 {
+		t.Fatalf("Got %dx%d panels, want 3x3", len(plot.Panels), len(plot.Panels[0]))
+	}
+	for c, blank := range []bool{false, true, true} {
+		if plot.Panels[0][c].blank != blank {
+			t.Errorf("Panel 0/%d: Got blank=%t", c, plot.Panels[0][c].blank)
+		}
+	}
+	if got := strings.Join(plot.Faceting.WrapStrips, " "); got != strings.Join(stations, " ") {
+		t.Errorf("Got strips %q", got)
+	}
+	if first := plot.Panels[2][0]; plot.wrapPanel(0) != first || !strings.Contains(first.Name, "Alp") {
+		t.Errorf("Got first panel %q", plot.wrapPanel(0).Name)
+	}
+	alp, bern := plot.Panels[2][0].Scales, plot.Panels[2][1].Scales
+	if alp["y"] == bern["y"] || alp["y"].Max >= bern["y"].Max {
+		t.Errorf("y scales not free: %s %s", alp["y"], bern["y"])
+	}
+	if alp["x"] != bern["x"] {
+		t.Errorf("x scale not shared")
+	}
+	// Each panel has its strip directly on top.
+	for r := 0; r < 3; r++ {
+		vp, tvp := plot.Viewports[fmt.Sprintf("Panel-%d,0", r)], plot.Panels[r][0].Tvp
+		if math.Abs(float64(tvp.Y0-(vp.Y0+vp.Height))) > 1e-6 || len(plot.Panels[r][0].Tgr) == 0 {
+			t.Errorf("Row %d: Bad strip viewport %+v for panel %+v", r, tvp, vp)
+		}
+	}
+
+	// Combinations of two fields, automatic number of columns.
+	plot = newPlot("Wrapped, two fields")
+	plot.Faceting = Faceting{Wrap: []string{"Sensor", "Station"}}
+	plot.WritePNG("wrap-two.png", 800, 600)
+	if len(plot.Panels) != 4 || len(plot.Panels[0]) != 4 {
+		t.Fatalf("Got %dx%d panels, want 4x4", len(plot.Panels), len(plot.Panels[0]))
+	}
+	if got := plot.Faceting.WrapStrips[7]; got != "outside, Alp" {
+		t.Errorf("Got strip %q, want \"outside, Alp\"", got)
+	}
+
+	// Columns and Rows are ignored for wrapped facets.
+	plot = newPlot("Wrapped, with columns")
+	plot.Faceting = Faceting{Wrap: []string{"Station"}, Columns: "Sensor"}
+	plot.WritePNG("wrap-columns.png", 700, 600)
+	if len(plot.Faceting.WrapStrips) != len(stations) || plot.Faceting.Columns != "" {
+		t.Errorf("Got strips %q, columns %q", plot.Faceting.WrapStrips, plot.Faceting.Columns)
+	}
+
+	// Data without levels is drawn in one panel.
+	empty, err := NewDataFrameFrom(data[:0], NewStringPool())
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot = newPlot("Wrapped, no data")
+	plot.Data = empty
+	plot.Faceting = Faceting{Wrap: []string{"Station"}}
+	plot.WritePNG("wrap-empty.png", 400, 300)
+	if len(plot.Panels) != 1 || len(plot.Panels[0]) != 1 {
+		t.Errorf("Got %dx%d panels, want 1x1", len(plot.Panels), len(plot.Panels[0]))
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	type obs struct {
+		Site, Year string
+		Day        float64
+		Count      float64
+	}
+	data := []obs{}
+	for d := 0; d <= 30; d++ {
+		day := float64(d)
+		data = append(data,
+			obs{"Short", "2020", day / 3, day},
+			obs{"Short", "2021", day / 3, 4 * day},
+			obs{"Long", "2020", day, day / 2},
+			obs{"Long", "2021", day, 2 * day})
+	}
+	newPlot := func(title string, faceting Faceting) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "Day", "y": "Count"}, title,
+			&Layer{Name: "Points", Geom: GeomPoint{}})
+		plot.Faceting = faceting
+		return plot
+	}
+	size := func(plot *Plot, r, c int) (float64, float64) {
+		vp := plot.Viewports[fmt.Sprintf("Panel-%d,%d", r, c)]
+		return float64(vp.Width), float64(vp.Height)
+	}
+
+	plot := newPlot("Free space", Faceting{Columns: "Site", Rows: "Year",
+		FreeScale: "xy", FreeSpace: "xy"})
+	plot.WritePNG("free-space.png", 600, 500)
+	// Columns Short and Long have x ranges 10 and 30, rows 2020 and
+	// 2021 have the y ranges 30 and 120.
+	wShort, h2020 := size(plot, 0, 0)
+	wLong, h2021 := size(plot, 1, 1)
+	if r := wLong / wShort; math.Abs(r-3) > 1e-6 {
+		t.Errorf("Got width ratio %.3f, want 3", r)
+	}
+	if r := h2021 / h2020; math.Abs(r-4) > 1e-6 {
+		t.Errorf("Got height ratio %.3f, want 4", r)
+	}
+
+	plot = newPlot("Free panels", Faceting{Columns: "Site", Rows: "Year", FreeScale: "free"})
+	plot.WritePNG("free-panels.png", 600, 500)
+	seen := map[*Scale]bool{}
+	for _, row := range plot.Panels {
+		for _, panel := range row {
+			seen[panel.Scales["x"]] = true
+			seen[panel.Scales["y"]] = true
+		}
+	}
+	if len(seen) != 8 {
+		t.Errorf("Got %d different x and y scales, want 8", len(seen))
+	}
+	if max := plot.Panels[0][0].Scales["y"].Max; max > 40 {
+		t.Errorf("Scale of panel Short/2020 trained on other data: max %.1f", max)
+	}
+	// The free scales do not change the size of the panels.
+	w0, h0 := size(plot, 0, 0)
+	w1, h1 := size(plot, 1, 1)
+	if w0 != w1 || h0 != h1 {
+		t.Errorf("Got different panel sizes %.1fx%.1f and %.1fx%.1f", w0, h0, w1, h1)
+	}
+}
+
+func TestFacetLabelsAndStrips(t *testing.T) {
+	type run struct {
+		Machine, Shift, Material string
+		Speed, Yield             float64
+	}
+	data := []run{}
+	for i := 0; i < 60; i++ {
+		m := []string{"M1", "M2"}[i%2]
+		s := []string{"day", "night"}[(i/2)%2]
+		if m == "M2" && s == "night" {
+			s = "day" // M2 never runs at night
+		}
+		mat := []string{"steel", "alu", "brass"}[(i/4)%3]
+		data = append(data, run{m, s, mat, float64(i), 50 + float64(i%7)})
+	}
+	newPlot := func(title string, faceting Faceting) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "Speed", "y": "Yield"}, title,
+			&Layer{Name: "Points", Geom: GeomPoint{}})
+		plot.Faceting = faceting
+		plot.WritePNG(strings.Replace(title, " ", "-", -1)+".png", 700, 500)
+		return plot
+	}
+
+	// Only existing combinations of Machine and Shift become columns.
+	plot := newPlot("facets both", Faceting{Columns: "Machine, Shift", Rows: "Material",
+		Labeller: LabelBoth, MultiLine: true})
+	if got := strings.Join(plot.Faceting.ColStrips, "|"); got != "Machine: M1\nShift: day|Machine: M1\nShift: night|Machine: M2\nShift: day" {
+		t.Errorf("Got column strips %q", got)
+	}
+	if got := strings.Join(plot.Faceting.RowStrips, "|"); got != "Material: steel|Material: alu|Material: brass" {
+		t.Errorf("Got row strips %q", got)
+	}
+	if n := len(plot.Panels[2][0].Tgr); n != 3 {
+		t.Errorf("Got %d grobs in two-line strip, want 3", n)
+	}
+
+	// Strips below and left of the panels.
+	plot = newPlot("facets placed", Faceting{Columns: "Machine", Rows: "Material",
+		Labeller:    LabelMap(map[string]string{"M1": "Old machine", "M2": "New machine"}),
+		ColStripPos: "bottom", RowStripPos: "left"})
+	if got := strings.Join(plot.Faceting.ColStrips, "|"); got != "Old machine|New machine" {
+		t.Errorf("Got column strips %q", got)
+	}
+	panel := plot.Panels[0][0]
+	if len(panel.Bgr) == 0 || len(panel.Lgr) == 0 || len(plot.Panels[2][1].Tgr) != 0 || len(plot.Panels[0][1].Rgr) != 0 {
+		t.Errorf("Strips not placed bottom and left")
+	}
+	vp := plot.Viewports["Panel-0,0"]
+	if panel.Bvp.Y0+panel.Bvp.Height > vp.Y0 || panel.Lvp.X0+panel.Lvp.Width > vp.X0 {
+		t.Errorf("Strips %+v %+v overlap panel %+v", panel.Bvp, panel.Lvp, vp)
+	}
+
+	// A function as labeller and no row strips.
+	upper := func(field, value string) string { return strings.ToUpper(value) }
+	plot = newPlot("facets none", Faceting{Columns: "Material", Rows: "Machine",
+		Labeller: upper, RowStripPos: "none"})
+	if got := strings.Join(plot.Faceting.ColStrips, "|"); got != "STEEL|ALU|BRASS" {
+		t.Errorf("Got column strips %q", got)
+	}
+	if len(plot.Panels[0][2].Rgr) != 0 || plot.renderInfo["Row-Strip.Width"] != 0 {
+		t.Errorf("Row strips not switched off")
+	}
+}
+
+func TestFacetTotals(t *testing.T) {
+	type obs struct {
+		Group, Site string
+		X, Y        float64
+	}
+	data := []obs{}
+	for i := 0; i < 24; i++ {
+		data = append(data, obs{[]string{"A", "B", "C"}[i%3], []string{"north", "south"}[(i/3)%2],
+			float64(i), float64(i % 5)})
+	}
+	newPlot := func(title string, faceting Faceting) *Plot {
+		plot := newTestPlot(t, data, AesMapping{"x": "X", "y": "Y"}, title,
+			&Layer{Name: "Points", Geom: GeomPoint{}})
+		plot.Faceting = faceting
+		// A layer with its own data must not be altered by the facets.
+		extra, err := NewDataFrameFrom(data[:6], plot.Pool)
+		if err != nil {
+			t.Fatalf("Unxpected error: %s", err)
+		}
+		plot.Layers = append(plot.Layers, &Layer{Name: "Extra", Data: extra,
+			DataMapping: AesMapping{"x": "X", "y": "Y"}, Geom: GeomLine{}})
+		plot.WritePNG(strings.Replace(title, " ", "-", -1)+".png", 600, 450)
+		for _, layer := range plot.Layers {
+			if layer.Panel != nil {
+				t.Errorf("%s: layer %s was bound to panel %s", title, layer.Name, layer.Panel.Name)
+			}
+		}
+		if extra.N != 6 || !extra.Has("Group") {
+			t.Errorf("%s: extra layer data modified: %v", title, extra.Columns)
+		}
+		return plot
+	}
+	count := func(panel *Panel) int { return panel.Data.N }
+
+	plot := newPlot("totals both", Faceting{Columns: "Group", Rows: "Site", Totals: true,
+		TotalLabel: "all"})
+	if len(plot.Panels) != 3 || len(plot.Panels[0]) != 4 {
+		t.Fatalf("Got %dx%d panels, want 3x4", len(plot.Panels), len(plot.Panels[0]))
+	}
+	if got := strings.Join(plot.Faceting.ColStrips, "|"); got != "A|B|C|all" {
+		t.Errorf("Got column strips %q", got)
+	}
+	if got := strings.Join(plot.Faceting.RowStrips, "|"); got != "all|north|south" {
+		t.Errorf("Got row strips %q", got)
+	}
+	for r, want := range [][]int{{8, 8, 8, 24}, {4, 4, 4, 12}, {4, 4, 4, 12}} {
+		for c, n := range want {
+			panel := plot.Panels[r][c]
+			if got := count(panel); got != n {
+				t.Errorf("Panel %s: got %d rows, want %d", panel.Name, got, n)
+			}
+			if total := r == 0 || c == 3; panel.total != total {
+				t.Errorf("Panel %s: got total=%t", panel.Name, panel.total)
+			}
+			if panel.Layers[0].Panel != panel || panel.Layers[1].Panel != panel {
+				t.Errorf("Panel %s: layers not bound to panel", panel.Name)
+			}
+		}
+	}
+	// Two of the six rows of the extra data are in A.
+	if n := plot.Panels[0][0].Layers[1].Data.N; n != 2 {
+		t.Errorf("Got %d rows of extra data in total of A, want 2", n)
+	}
+	if n := plot.Panels[0][3].Layers[1].Data.N; n != 6 {
+		t.Errorf("Got %d rows of extra data in grand total, want 6", n)
+	}
+
+	plot = newPlot("totals row", Faceting{Columns: "Group", Rows: "Site", Totals: true,
+		TotalsIn: "row"})
+	if len(plot.Panels) != 3 || len(plot.Panels[0]) != 3 || plot.Faceting.RowStrips[0] != "-all-" {
+		t.Errorf("Got %dx%d panels, row strips %q", len(plot.Panels), len(plot.Panels[0]),
+			plot.Faceting.RowStrips)
+	}
+
+	// No total row without faceting in rows.
+	plot = newPlot("totals col", Faceting{Columns: "Group", Totals: true})
+	if len(plot.Panels) != 1 || len(plot.Panels[0]) != 4 || count(plot.Panels[0][3]) != 24 {
+		t.Errorf("Got %dx%d panels", len(plot.Panels), len(plot.Panels[0]))
+	}
+
+	plot = newPlot("totals in col", Faceting{Columns: "Group", Rows: "Site", Totals: true,
+		TotalsIn: "col"})
+	if len(plot.Panels) != 2 || len(plot.Panels[0]) != 4 {
+		t.Errorf("Got %dx%d panels, want 2x4", len(plot.Panels), len(plot.Panels[0]))
+	}
+
+	// TotalsIn is ignored without Totals; unknown TotalsIn give both totals.
+	plot = newPlot("totals off", Faceting{Columns: "Group", Rows: "Site", TotalsIn: "row"})
+	if len(plot.Panels) != 2 || len(plot.Panels[0]) != 3 {
+		t.Errorf("Got %dx%d panels, want 2x3", len(plot.Panels), len(plot.Panels[0]))
+	}
+	plot = newPlot("totals unknown", Faceting{Columns: "Group", Rows: "Site", Totals: true,
+		TotalsIn: "all"})
+	if len(plot.Panels) != 3 || len(plot.Panels[0]) != 4 {
+		t.Errorf("Got %dx%d panels, want 3x4", len(plot.Panels), len(plot.Panels[0]))
+	}
+
+	// Marginals do not count the data of the total panels twice.
+	plot, err := NewPlot(data, AesMapping{"x": "X", "y": "Y"})
+	if err != nil {
+		t.Fatalf("Unxpected error: %s", err)
+	}
+	plot.Faceting = Faceting{Columns: "Group", Rows: "Site", Totals: true}
+	plot.MarginalX, plot.MarginalY = &Marginal{}, &Marginal{}
+	plot.Layers = append(plot.Layers, &Layer{Name: "Points", Geom: GeomPoint{}})
+	plot.WritePNG("totals-marginals.png", 600, 450)
+	for c, want := range []int{8, 8, 8, 24} {
+		if n := len(plot.columnValues(c, "x")); n != want {
+			t.Errorf("Column %d: got %d marginal values, want %d", c, n, want)
+		}
+	}
+	for r, want := range []int{24, 12, 12} {
+		if n := len(plot.rowValues(r, "y")); n != want {
+			t.Errorf("Row %d: got %d marginal values, want %d", r, n, want)
+		}
+	}
+}
+
+// guides returns the grobs in the legends of plot, grouped by type.
+func guides(plot *Plot) map[string][]Grob {
+	grobs := map[string][]Grob{}
+	var collect func(g Grob)
+	collect = func(g Grob) {
+		if group, ok := g.(GrobGroup); ok {
+			for _, e := range group.elements {
+				collect(e)
+			}
+			return
+		}
+		name := fmt.Sprintf("%T", g)
+		grobs[name] = append(grobs[name], g)
+	}
+	collect(plot.Grobs["Guides"])
+	return grobs
+}
+
+func TestLegendKeys(t *testing.T) {
+	type obs struct {
+		Kind string
+		X, Y float64
+	}
+	data := []obs{}
+	for i := 0; i < 30; i++ {
+		data = append(data, obs{[]string{"a", "b", "c"}[i%3], float64(i / 3), rand.Float64()})
+	}
+	newPlot := func(title string, aes AesMapping, layers ...*Layer) *Plot {
+		plot := newTestPlot(t, data, aes, title, layers...)
+		plot.WritePNG(strings.Replace(title, " ", "-", -1)+".png", 500, 350)
+		return plot
+	}
+	aes := AesMapping{"x": "X", "y": "Y", "color": "Kind"}
+
+	// Keys of all layers are combined in the fixed style of each layer.
+	plot := newPlot("keys combined", aes,
+		&Layer{Name: "Lines", Geom: GeomLine{Style: AesMapping{"linetype": "dashed"}}},
+		&Layer{Name: "Points", Geom: GeomPoint{Style: AesMapping{"shape": "delta"}}})
+	g := guides(plot)
+	if len(g["plot.GrobPoint"]) != 3 || len(g["plot.GrobLine"]) != 3 {
+		t.Fatalf("Got %d points and %d lines in legend, want 3 each",
+			len(g["plot.GrobPoint"]), len(g["plot.GrobLine"]))
+	}
+	scale := plot.Scales["color"]
+	for i, grob := range g["plot.GrobPoint"] {
+		point := grob.(GrobPoint)
+		if point.shape != DeltaPoint || point.color != SetAlpha(scale.Color(scale.Breaks[i]), 1) {
+			t.Errorf("Key %d: got point %s", i, point)
+		}
+		if line := g["plot.GrobLine"][i].(GrobLine); line.linetype != DashedLine {
+			t.Errorf("Key %d: got line %s", i, line)
+		}
+	}
+
+	// Hidden layers contribute no keys, no legend if all are hidden.
+	plot = newPlot("keys hidden", aes,
+		&Layer{Name: "Lines", Geom: GeomLine{}, HideLegend: true},
+		&Layer{Name: "Points", Geom: GeomPoint{}})
+	g = guides(plot)
+	if len(g["plot.GrobPoint"]) != 3 || len(g["plot.GrobLine"]) != 0 {
+		t.Errorf("Got %d points and %d lines in legend, want 3 and 0",
+			len(g["plot.GrobPoint"]), len(g["plot.GrobLine"]))
+	}
+	plot = newPlot("keys none", aes,
+		&Layer{Name: "Points", Geom: GeomPoint{}, HideLegend: true})
+	if g = guides(plot); len(g) != 0 {
+		t.Errorf("Got legend %v", g)
+	}
+
+	// Aesthetics wired by the GeomMapping count as mapped.
+	plot = &Plot{Aes: AesMapping{"x": "X", "y": "Y"}, Layers: []*Layer{
+		{Name: "Points", Geom: GeomPoint{}, GeomMapping: AesMapping{"color": "count"}},
+	}}
+	if keys, hidden := plot.legendKeys("color", scale); len(keys) != 1 || hidden {
+		t.Errorf("Got %d keys, hidden=%t, want 1 shown key", len(keys), hidden)
+	}
+	plot.Layers[0].HideLegend = true
+	if keys, hidden := plot.legendKeys("color", scale); len(keys) != 0 || !hidden {
+		t.Errorf("Got %d keys, hidden=%t, want hidden legend", len(keys), hidden)
+	}
+
+	// Filled geoms draw boxes, boxplots their glyph.
+	plot = newPlot("keys boxplot", AesMapping{"x": "Kind", "y": "Y", "fill": "Kind"},
+		&Layer{Name: "Boxplot", Stat: StatBoxplot{}, Geom: GeomBoxplot{}})
+	g = guides(plot)
+	// Per key the background, the box and its border, whiskers and median.
+	if len(g["plot.GrobRect"]) != 6 || len(g["plot.GrobPath"]) != 3 || len(g["plot.GrobLine"]) != 9 {
+		t.Errorf("Got %d rects, %d paths and %d lines in legend",
+			len(g["plot.GrobRect"]), len(g["plot.GrobPath"]), len(g["plot.GrobLine"]))
+	}
+	plot = newPlot("keys bar", AesMapping{"x": "Kind", "y": "Y", "fill": "Kind"},
+		&Layer{Name: "Bars", Geom: GeomCol{Style: AesMapping{"alpha": "0.5"}}})
+	g = guides(plot)
+	for i, grob := range g["plot.GrobRect"] {
+		rect := grob.(GrobRect)
+		if _, _, _, a := rect.fill.RGBA(); i%2 == 1 && a > 0x8000 {
+			t.Errorf("Key %d: got opaque fill", i)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"image/color"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"gonum.org/v1/plot/vg"
@@ -19,6 +21,25 @@ type Scale struct {
 	Discrete   bool
 	Time       bool
 
+	// Origin is the Unix time of the value 0 on a time scale. It is
+	// taken from the first data trained on the scale.
+	Origin int64
+
+	// Location is the time zone in which the breaks of a time scale are
+	// aligned to full hours, days, months etc. and in which the labels
+	// are formated. Nil means UTC.
+	Location *time.Location
+
+	// TimeFormat is the layout (see time.Time.Format) used to label
+	// the breaks of a time scale. Empty: automatic.
+	TimeFormat string
+
+	// Duration marks a continuous scale as a scale of durations: The
+	// data values are measured in multiples of Duration, e.g. time.Second
+	// for seconds or time.Nanosecond for time.Duration values. Breaks are
+	// placed on round durations and labeled like "1h30m".
+	Duration time.Duration
+
 	// pos (x/y), col/fill, size, type ... TODO: good like this?
 	Aesthetic string // should be same like the map key in Plot.Scales
 
@@ -31,20 +52,72 @@ type Scale struct {
 	// A empty FixLevels or FixMin==FixMax results in an automatical
 	// determination of the domain of this scale based on the data
 	// plotted. Otherwise if FixMin!=FixMax the given values are
-	// used.
+	// used. Limits of transformed scales are given in the untransformed
+	// data units.
 	FixMin    float64
 	FixMax    float64
 	FixLevels FloatSet
 
+	// OOB determines what happens to data outside of FixMin and FixMax.
+	OOB OOBPolicy
+
+	// Values turns the scale into a manual scale: The levels of the
+	// discrete scale are mapped (by their name) to the given values of
+	// the aesthetic, written like fixed styles, e.g. "#ff0000" or "red"
+	// for colors, "dashed" for linetypes or "4" for sizes.
+	Values map[string]string
+
+	// Fallback is used for levels not listed in Values. If Fallback is
+	// empty such unmapped levels are an error reported in Finalize.
+	Fallback string
+
+	// Identity scales use the data values themself as values of the
+	// aesthetic, e.g. a column containing "red" or "#ff0000" for the
+	// color aesthetic. Identity scales have no legend.
+	Identity bool
+
+	// Palette provides the colors of color and fill scales. Nil
+	// uses a HuePalette.
+	Palette Palette
+
+	// Secondary adds a second axis to a x or y scale, drawn on the top
+	// respectively right side of the plot.
+	Secondary *SecondaryAxis
+
+	// Binned turns a continuous scale into a binned scale: The Breaks
+	// cut the range into bins and all values in one bin are mapped to
+	// the same value of the aesthetic, e.g. one palette color per bin.
+	// Empty Breaks are chosen automatically to give about NBins bins
+	// (default 5). Binned scales cannot be used for x and y.
+	Binned bool
+	NBins  int
+
+	// Quantile places the automatic breaks of a binned scale at the
+	// quantiles of the data so that each bin contains about the same
+	// number of values. The breaks are rounded to three significant
+	// digits.
+	Quantile bool
+
 	// Relative and absolute expansion of scale.
 	ExpandRel, ExpandAbs float64
 
 	// Breaks controls the position of the tics. Empty: auto
+	// Breaks of transformed scales are given in the untransformed data
+	// units; Finalize transforms them like MinorBreaks.
 	Breaks []float64
 
 	// Labels are the labels for the tics. Empty: print Breaks
 	Labels []string
 
+	// Formatter produces the Labels from the (untransformed) Breaks
+	// if no Labels are given. Nil uses NumberFormat{}.Format for
+	// continuous scales. See PercentFormat, CurrencyFormat etc.
+	Formatter func(breaks []float64) []string
+
+	// MinorBreaks controls the position of the minor grid lines.
+	// Empty: auto
+	MinorBreaks []float64
+
 	// Empirical range of the Domain, as [DomainMin,DomainMax] interval
 	// for  continuous scales or as a set DomainLevels of values.
 	// These values are populated during the trainings.
@@ -63,9 +136,97 @@ type Scale struct {
 	Pos   func(x float64) float64     // x, y, size, alpha. In [0,1]
 	Style func(x float64) int         // point and line type. BUG: Range
 
+	// Value is set for manual and identity scales only and returns
+	// the fixed style value of the aesthetic for x.
+	Value func(x float64) string
+
+	// The trained values of a quantile binned scale.
+	values []float64
+
+	// Position (in the domain of this scale) and labels of the tics
+	// of the secondary axis.
+	secBreaks []float64
+	secLabels []string
+
+	// zoom is the visible range (in the transformed domain) requested
+	// by the coordinate system.
+	zoom [2]float64
+
 	Finalized bool
 }
 
+// OOBPolicy is the handling of data outside the limits of a scale.
+type OOBPolicy int
+
+const (
+	Keep   OOBPolicy = iota // Keep the data, it will be clipped when drawn.
+	Censor                  // Remove data outside the limits.
+	Squish                  // Move data outside to the nearest limit.
+)
+
+// oob applies the OOB policy of the continuous scale s with fixed limits
+// to the field aes of data and returns the number of removed rows.
+func (s *Scale) oob(data *DataFrame, aes string) int {
+	if s.Discrete || s.FixMin == s.FixMax {
+		return 0
+	}
+	field, ok := data.Columns[aes]
+	if !ok || field.Type == String {
+		return 0
+	}
+	lim := s.transformLimits([2]float64{s.FixMin, s.FixMax})
+	min, max := math.Min(lim[0], lim[1]), math.Max(lim[0], lim[1])
+	switch s.OOB {
+	case Censor:
+		d := field.Data
+		return data.FilterRows(func(i int) bool {
+			return math.IsNaN(d[i]) || (d[i] >= min && d[i] <= max)
+		})
+	case Squish:
+		field.Apply(func(x float64) float64 { return clamp(x, min, max) })
+	}
+	return 0
+}
+
+// transformLimits returns the limits lim, given in untransformed data
+// units, in the transformed domain of s in ascending order. Equal limits,
+// i.e. no limits, are returned unchanged.
+func (s *Scale) transformLimits(lim [2]float64) [2]float64 {
+	t := s.Transform
+	if lim[0] == lim[1] || t == nil || t == &IdentityScale || s.Discrete || s.Time {
+		return lim
+	}
+	a, b := t.Trans(lim[0]), t.Trans(lim[1])
+	if a > b {
+		a, b = b, a
+	}
+	return [2]float64{a, b}
+}
+
+// transformBreaks transforms the user given Breaks and MinorBreaks of a
+// transformed scale from the data units to the transformed domain.
+// Breaks outside the domain of the transformation are dropped.
+func (s *Scale) transformBreaks() {
+	t := s.Transform
+	if t == nil || t == &IdentityScale || s.Time {
+		return
+	}
+	transform := func(breaks []float64) []float64 {
+		if len(breaks) == 0 {
+			return breaks
+		}
+		tb := []float64{}
+		for _, b := range breaks {
+			if x := t.Trans(b); !math.IsNaN(x) && !math.IsInf(x, 0) {
+				tb = append(tb, x)
+			}
+		}
+		sort.Float64s(tb)
+		return tb
+	}
+	s.Breaks, s.MinorBreaks = transform(s.Breaks), transform(s.MinorBreaks)
+}
+
 // NewScale sets up a new scale for the given aesthetic, suitable for
 // the given data in field.
 func NewScale(aesthetic string, name string, ft FieldType) *Scale {
@@ -94,7 +255,7 @@ func NewScale(aesthetic string, name string, ft FieldType) *Scale {
 // String pretty prints s.
 func (s *Scale) String() string {
 	f2t := func(x float64) string {
-		return time.Unix(int64(x), 0).Format("2006-01-02 15:04:05")
+		return s.TimeOf(x).Format("2006-01-02 15:04:05")
 	}
 
 	t := fmt.Sprintf("Scale %q %p named %q: ", s.Aesthetic, s, s.Name)
@@ -164,6 +325,13 @@ func (s *Scale) Train(f Field) {
 		min, max, mini, maxi := f.MinMax()
 		fmt.Printf("      data is continuous from %.2f to %.2f\n",
 			min, max)
+		if s.Binned && s.Quantile {
+			for _, v := range f.Data {
+				if !math.IsNaN(v) && !math.IsInf(v, 0) {
+					s.values = append(s.values, v)
+				}
+			}
+		}
 		if mini != -1 {
 			if min < s.DomainMin {
 				s.DomainMin = min
@@ -189,7 +357,8 @@ func (s *Scale) TrainByValue(xs ...float64) {
 			}
 			if x < s.DomainMin {
 				s.DomainMin = x
-			} else if x > s.DomainMax {
+			}
+			if x > s.DomainMax {
 				s.DomainMax = x
 			}
 
@@ -201,34 +370,108 @@ func (s *Scale) TrainByValue(xs ...float64) {
 // Preparing a scale
 
 // Prepare initialises the remaining fields after training.
-func (s *Scale) Finalize(pool *StringPool) {
+func (s *Scale) Finalize(pool *StringPool) error {
 	if s.Finalized {
-		return
+		return nil
 	}
 
+	var err error
+	if s.Binned && (s.Discrete || s.Aesthetic == "x" || s.Aesthetic == "y") {
+		err = fmt.Errorf("cannot bin %s scale %q", s.Aesthetic, s.Name)
+		s.Binned = false
+	}
 	if s.Discrete {
 		s.FinalizeDiscrete(pool)
 	} else {
+		s.transformBreaks()
 		s.FinalizeContinous()
 	}
+	if e := s.finalizeManual(pool); e != nil {
+		err = e
+	}
 
 	s.Finalized = true
+	return err
+}
+
+// finalizeManual sets up the mapping functions of manual and identity
+// scales. Levels of a manual scale without a value are an error unless
+// a Fallback is given; such levels are drawn with the Fallback.
+func (s *Scale) finalizeManual(pool *StringPool) error {
+	if s.Values == nil && !s.Identity {
+		return nil
+	}
+	if s.Aesthetic == "x" || s.Aesthetic == "y" {
+		return fmt.Errorf("position scale %s %q cannot be manual or identity",
+			s.Aesthetic, s.Name)
+	}
+
+	name := func(x float64) string {
+		if s.DomainType == String {
+			return pool.Get(int(x))
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	if s.Identity {
+		s.Value = name
+	} else {
+		s.Value = func(x float64) string {
+			if v, ok := s.Values[name(x)]; ok {
+				return v
+			}
+			return s.Fallback
+		}
+	}
+	s.Color = func(x float64) color.Color {
+		return String2Color(s.Value(x))
+	}
+	s.Style = func(x float64) int {
+		if s.Aesthetic == "linetype" {
+			return int(String2LineType(s.Value(x)))
+		}
+		return int(String2PointShape(s.Value(x)))
+	}
+
+	if s.Identity || s.Fallback != "" {
+		return nil
+	}
+	missing := []string{}
+	for _, level := range s.DomainLevels.Elements() {
+		if _, ok := s.Values[name(level)]; !ok {
+			missing = append(missing, name(level))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("manual scale %s %q has no values for levels %s",
+			s.Aesthetic, s.Name, strings.Join(missing, ", "))
+	}
+	return nil
 }
 
-// Convert the discrete x value with possible adjustemnts in (-0.5,+0.5)
+// Convert the discrete x value with possible adjustemnts in [-0.5,+0.5]
 // to a continous value by looking up xi....   Arghh...
+// An adjustment of exactly +0.5 is ambiguous (xi or xi+1 with -0.5) and
+// resolved by choosing the one which is an actual level.
 func discreteToCont(x float64, levels []float64) float64 {
 	xi := math.Floor(x + 0.5)
+	i := levelIndex(xi, levels)
+	if i == -1 && x-xi == -0.5 {
+		xi--
+		i = levelIndex(xi, levels)
+	}
 	dx := x - xi
-	i := -1
+	w := float64(i+1) + dx
+	return w
+}
+
+// levelIndex returns the index of x in levels or -1 if not found.
+func levelIndex(x float64, levels []float64) int {
 	for j, v := range levels {
-		if v == xi {
-			i = j
-			break
+		if v == x {
+			return j
 		}
 	}
-	w := float64(i+1) + dx
-	return w
+	return -1
 }
 
 // FinalizeDiscrete
@@ -286,54 +529,24 @@ func (s *Scale) FinalizeDiscrete(pool *StringPool) {
 
 	// Produce mapping functions
 	s.Pos = func(x float64) float64 {
-		xi := math.Floor(x + 0.5)
-		dx := x - xi
-		i := -1
-		for j, v := range levels {
-			if v == xi {
-				i = j
-				break
-			}
-		}
-		w := float64(i+1) + dx
+		w := discreteToCont(x, levels)
 		// Scale to [0,1]
 		z := (w - s.Min) / fullRange
-		// fmt.Printf("s.Pos(%.2f) xi=%.0f dx=%.2f i=%d w=%.1f --> %.2f  (s.Min=%.1f s.Max=%.1f)\n",
-		//	x, xi, dx, i, w, z, s.Min, s.Max)
-
 		return z
 	}
+	colors := s.palette().Colors(n)
 	s.Color = func(x float64) color.Color {
-		// TODO: merge with code from continuous
-		// TODO: 1. this uses the expanded range. useful?
-		h := s.Pos(x) * (5.0 / 6.0) // rescale by 5/6 because hue is cyclic
-		hi := int(h * 6)
-		f := h*6 - float64(hi)
-		s := 1.0 // TODO: make configurable?
-		v := 0.8 // TODO: make configurable?
-		p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
-		vv, tt, pp, qq := uint8(v*255), uint8(t*255), uint8(p*255), uint8(q*255)
-		switch hi {
-		case 0, 6:
-			return color.RGBA{vv, tt, pp, 0xff}
-		case 1:
-			return color.RGBA{qq, vv, pp, 0xff}
-		case 2:
-			return color.RGBA{pp, vv, tt, 0xff}
-		case 3:
-			return color.RGBA{pp, qq, vv, 0xff}
-		case 4:
-			return color.RGBA{tt, pp, vv, 0xff}
-		case 5:
-			return color.RGBA{vv, pp, qq, 0xff}
-		}
-		return color.RGBA{}
+		if i := levelIndex(x, levels); i != -1 {
+			return colors[i]
+		}
+		return s.palette().Color(s.Pos(x))
 	}
 	s.Style = func(x float64) int {
 		c := s.Pos(x)
 		c *= float64(StarPoint) // TODO same as below
 		return int(c)
 	}
+	s.prepareSecondary()
 }
 
 // FinalizeContinous sets up the fields Breaks, Labels and the
@@ -342,7 +555,12 @@ func (s *Scale) FinalizeContinous() {
 	fmt.Printf("  Finalizing continuos scale %q %p\n", s.Name, s)
 	s.Min, s.Max = s.DomainMin, s.DomainMax
 	if s.FixMin != s.FixMax {
-		s.Min, s.Max = s.FixMin, s.FixMax
+		lim := s.transformLimits([2]float64{s.FixMin, s.FixMax})
+		s.Min, s.Max = lim[0], lim[1]
+	}
+	lo, hi := s.Min, s.Max // colors use the unexpanded range
+	if z := s.zoom; z[0] != z[1] {
+		s.Min, s.Max = z[0], z[1]
 	}
 	expand := (s.Max-s.Min)*s.ExpandRel + s.ExpandAbs
 	s.Min -= expand
@@ -350,10 +568,15 @@ func (s *Scale) FinalizeContinous() {
 	fullRange := s.Max - s.Min
 
 	// Set up breaks and labels
-	if len(s.Breaks) == 0 {
-		// All auto.
+	if s.Binned && len(s.Breaks) == 0 {
+		s.Breaks = s.binBreaks(lo, hi)
+	}
+	if len(s.Breaks) == 0 || len(s.MinorBreaks) == 0 {
 		s.PrepareBreaks(s.Min, s.Max, 5)
 	}
+	if s.Binned {
+		s.sortBreaks()
+	}
 	s.PrepareLabels()
 
 	// Produce mapping functions
@@ -361,67 +584,504 @@ func (s *Scale) FinalizeContinous() {
 		return (x - s.Min) / fullRange
 	}
 	s.Color = func(x float64) color.Color {
-		// TODO: 1. this uses the expanded range. useful?
-		h := s.Pos(x) * (5.0 / 6.0)
-		hi := int(h * 6)
-		f := h*6 - float64(hi)
-		s := 1.0 // TODO: make configurable?
-		v := 0.8 // TODO: make configurable?
-		p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
-		vv, tt, pp, qq := uint8(v*255), uint8(t*255), uint8(p*255), uint8(q*255)
-		switch hi {
-		case 0, 6:
-			return color.RGBA{vv, tt, pp, 0xff}
-		case 1:
-			return color.RGBA{qq, vv, pp, 0xff}
-		case 2:
-			return color.RGBA{pp, vv, tt, 0xff}
-		case 3:
-			return color.RGBA{pp, qq, vv, 0xff}
-		case 4:
-			return color.RGBA{tt, pp, vv, 0xff}
-		case 5:
-			return color.RGBA{vv, pp, qq, 0xff}
-		}
-		return color.RGBA{}
+		return s.palette().Color(s.colorPos(x, lo, hi))
 	}
 	s.Style = func(x float64) int {
 		c := s.Pos(x)
 		c *= float64(StarPoint) // TODO
 		return int(c)
 	}
+	if s.Binned {
+		s.finalizeBinned()
+	}
+	s.prepareSecondary()
+}
+
+// binBreaks returns the automatic breaks of the binned scale s with
+// range [lo,hi].
+func (s *Scale) binBreaks(lo, hi float64) []float64 {
+	n := s.NBins
+	if n <= 0 {
+		n = 5
+	}
+	t := s.Transform
+	if t == nil {
+		t = &IdentityScale
+	}
+
+	breaks := []float64{}
+	if s.Quantile && len(s.values) > 0 {
+		sorted := append([]float64(nil), s.values...)
+		sort.Float64s(sorted)
+		for i := 1; i < n; i++ {
+			q := t.Trans(roundSignificant(t.Inverse(quantile(sorted, float64(i)/float64(n))), 3))
+			if q > lo && q < hi && (len(breaks) == 0 || q > breaks[len(breaks)-1]) {
+				breaks = append(breaks, q)
+			}
+		}
+		return breaks
+	}
+
+	// Nice breaks inside the range.
+	nice := *s
+	nice.Breaks, nice.MinorBreaks = nil, nil
+	nice.PrepareContinousBreaks(lo, hi, n)
+	for _, b := range nice.Breaks {
+		if b > lo && b < hi {
+			breaks = append(breaks, b)
+		}
+	}
+	return breaks
+}
+
+// roundSignificant rounds x to n significant digits.
+func roundSignificant(x float64, n int) float64 {
+	v, err := strconv.ParseFloat(strconv.FormatFloat(x, 'g', n, 64), 64)
+	if err != nil {
+		return x
+	}
+	return v
+}
+
+// sortBreaks sorts the (user provided) breaks of the binned scale s in
+// increasing order. Labels given for each break are kept with their break.
+func (s *Scale) sortBreaks() {
+	if sort.Float64sAreSorted(s.Breaks) {
+		return
+	}
+	if len(s.Labels) != len(s.Breaks) {
+		sort.Float64s(s.Breaks)
+		return
+	}
+	idx := make([]int, len(s.Breaks))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return s.Breaks[idx[i]] < s.Breaks[idx[j]] })
+	breaks, labels := make([]float64, len(idx)), make([]string, len(idx))
+	for i, k := range idx {
+		breaks[i], labels[i] = s.Breaks[k], s.Labels[k]
+	}
+	s.Breaks, s.Labels = breaks, labels
+}
+
+// finalizeBinned sets up the mapping functions of the binned scale s:
+// Each of the len(s.Breaks)+1 bins gets one color and one position.
+// The breaks must be sorted.
+func (s *Scale) finalizeBinned() {
+	n := len(s.Breaks) + 1
+	colors := s.palette().Colors(n)
+	s.Pos = func(x float64) float64 {
+		return (float64(s.bin(x)) + 0.5) / float64(n)
+	}
+	s.Color = func(x float64) color.Color {
+		return colors[s.bin(x)]
+	}
+}
+
+// bin returns the index of the bin of the binned scale s containing x.
+// Bin i contains the values in [Breaks[i-1], Breaks[i]).
+func (s *Scale) bin(x float64) int {
+	return sort.Search(len(s.Breaks), func(i int) bool { return s.Breaks[i] > x })
+}
+
+// binLabels returns the labels of the bins of s like "< 10", "10 – 20"
+// and "≥ 20".
+func (s *Scale) binLabels() []string {
+	n := len(s.Labels)
+	if n == 0 {
+		return []string{""}
+	}
+	labels := make([]string, n+1)
+	labels[0] = "< " + s.Labels[0]
+	for i := 1; i < n; i++ {
+		labels[i] = s.Labels[i-1] + " – " + s.Labels[i]
+	}
+	labels[n] = "≥ " + s.Labels[n-1]
+	return labels
+}
+
+// binValues returns a representative value for each bin of s.
+func (s *Scale) binValues() []float64 {
+	n := len(s.Breaks)
+	if n == 0 {
+		return []float64{s.Min}
+	}
+	values := make([]float64, n+1)
+	values[0] = s.Breaks[0] - 1
+	for i := 1; i < n; i++ {
+		values[i] = (s.Breaks[i-1] + s.Breaks[i]) / 2
+	}
+	values[n] = s.Breaks[n-1]
+	return values
 }
 
-// PrepareBreaks populates s.breaks with suitable values.
+// palette returns the palette of s.
+func (s *Scale) palette() Palette {
+	if s.Palette == nil {
+		return HuePalette{}
+	}
+	return s.Palette
+}
+
+// colorPos maps x to [0,1] for the palette of the continuous scale s with
+// range [lo,hi]. Palettes with a midpoint map the midpoint to 0.5 and
+// stretch both sides equally.
+func (s *Scale) colorPos(x, lo, hi float64) float64 {
+	if mp, ok := s.palette().(midpointer); ok {
+		if m, ok := mp.midpoint(); ok {
+			if s.Transform != nil {
+				m = s.Transform.Trans(m)
+			}
+			r := math.Max(m-lo, hi-m)
+			if r <= 0 {
+				return 0.5
+			}
+			return 0.5 + (x-m)/(2*r)
+		}
+	}
+	if hi == lo {
+		return 0.5
+	}
+	return (x - lo) / (hi - lo)
+}
+
+// PrepareBreaks populates s.Breaks and s.MinorBreaks with suitable values.
 // Suitable values for a range of [55,125] are [60,80,100,120].
-// TODO: For a log10 transformed scale the breaks should be
-// plain integers:
-//   Raw data [0.01,100] --log10--> [-2,2] --break--> [-2,-1,0,1,2]
-// this should work, but what with
-// raw [12,88] --log10--> [1.08,1.94] --break--> [1.2,1.4,1.6,1.8]
-// which gives [15.8, 25.1, 39.8, 63.1] wich is ugly. More
-// dramatic on sqrt or 1/x transforms.
+// The breaks are generated by the Transform of s in the untransformed
+// domain: A log10 transformed scale gets breaks at powers of ten and not
+// at ugly values like [15.8, 25.1, 39.8, 63.1].
 func (s *Scale) PrepareBreaks(min, max float64, num int) {
-	if s.Discrete || s.Time {
+	if s.Discrete {
 		panic("Shuld not happen")
 	}
 
-	if s.Time {
+	switch {
+	case s.Time:
 		s.PrepareTimeBreaks(min, max, num)
-	} else {
+	case s.Duration > 0:
+		s.PrepareDurationBreaks(min, max, num)
+	default:
 		s.PrepareContinousBreaks(min, max, num)
 	}
 }
 
-// TODO: Code below is suboptimal
+// TimeOf converts the value x of the time scale s to a time in s.Location.
+func (s *Scale) TimeOf(x float64) time.Time {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	sec, frac := math.Modf(x)
+	return time.Unix(s.Origin+int64(sec), int64(frac*1e9)).In(loc)
+}
+
+// valueOf is the inverse of TimeOf.
+func (s *Scale) valueOf(t time.Time) float64 {
+	return float64(t.Unix()-s.Origin) + float64(t.Nanosecond())/1e9
+}
+
+// PrepareTimeBreaks automatically populates s.Breaks (if empty) and
+// s.MinorBreaks (if empty) of the time scale s with about num breaks in
+// [min,max]. The breaks are aligned to round calendar units in s.Location,
+// e.g. to full quarter hours, to midnight or to the first of a month.
 func (s *Scale) PrepareTimeBreaks(min, max float64, num int) {
-	s.Breaks = []float64{min, max, (min + max) / 2}
+	tmin, tmax := s.TimeOf(min), s.TimeOf(max)
+	i := chooseTimeStep(tmax.Sub(tmin).Seconds(), num)
+	if i < 0 {
+		// Below one second.
+		if len(s.Breaks) == 0 {
+			s.Breaks = NiceBreaks(min, max, num)
+		}
+		if len(s.MinorBreaks) == 0 {
+			s.MinorBreaks = NiceMinorBreaks(s.Breaks, min, max)
+		}
+		return
+	}
+
+	step := timeSteps[i]
+	if len(s.Breaks) == 0 {
+		for _, t := range step.major.times(tmin, tmax) {
+			s.Breaks = append(s.Breaks, s.valueOf(t))
+		}
+	}
+	if len(s.MinorBreaks) == 0 && step.minor.n > 0 {
+		isMajor := make(map[float64]bool)
+		for _, b := range s.Breaks {
+			isMajor[b] = true
+		}
+		for _, t := range step.minor.times(tmin, tmax) {
+			if v := s.valueOf(t); !isMajor[v] {
+				s.MinorBreaks = append(s.MinorBreaks, v)
+			}
+		}
+	}
+	fmt.Printf("    PrepareTimeBreaks(%s, %s, %d) step=%d%s n=%d minor=%d\n",
+		tmin, tmax, num, step.major.n, step.major.unit, len(s.Breaks), len(s.MinorBreaks))
+}
+
+// timeUnit is a calendar unit used to place breaks on time scales.
+type timeUnit int
+
+const (
+	unitSecond timeUnit = iota
+	unitMinute
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+var timeUnitNames = []string{"s", "min", "h", "d", "w", "mon", "y"}
+
+// Approximate length of the time units in seconds.
+var timeUnitSeconds = []float64{1, 60, 3600, 86400, 7 * 86400, 30.44 * 86400, 365.25 * 86400}
+
+func (u timeUnit) String() string {
+	return timeUnitNames[u]
+}
+
+// timeStep is a step of n calendar units.
+type timeStep struct {
+	unit timeUnit
+	n    int
+}
+
+// floor returns the latest time not after t which is aligned to ts in the
+// location of t. Weeks start on Monday.
+func (ts timeStep) floor(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	h, mi, sec := t.Clock()
+	loc := t.Location()
+	switch ts.unit {
+	case unitSecond:
+		return time.Date(y, mo, d, h, mi, sec-sec%ts.n, 0, loc)
+	case unitMinute:
+		return time.Date(y, mo, d, h, mi-mi%ts.n, 0, 0, loc)
+	case unitHour:
+		return time.Date(y, mo, d, h-h%ts.n, 0, 0, 0, loc)
+	case unitDay:
+		return time.Date(y, mo, d-(d-1)%ts.n, 0, 0, 0, 0, loc)
+	case unitWeek:
+		wd := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, mo, d-wd, 0, 0, 0, 0, loc)
+	case unitMonth:
+		m := int(mo) - 1
+		return time.Date(y, time.Month(m-m%ts.n+1), 1, 0, 0, 0, 0, loc)
+	case unitYear:
+		return time.Date(y-((y%ts.n)+ts.n)%ts.n, 1, 1, 0, 0, 0, 0, loc)
+	}
+	panic("Unknown time unit")
 }
 
-// PrepepareContinousBreaks automatically populates s.Breaks
-// with suitable values.
+// next returns the time ts after the aligned time t. Days, months and
+// years are calendar-aware: A day is not always 24 hours long. Hours keep
+// their alignment to the local clock across daylight saving transitions.
+func (ts timeStep) next(t time.Time) time.Time {
+	switch ts.unit {
+	case unitSecond:
+		return t.Add(time.Duration(ts.n) * time.Second)
+	case unitMinute:
+		return t.Add(time.Duration(ts.n) * time.Minute)
+	case unitHour:
+		// Step on the local clock: t may have been moved off the
+		// alignment by a skipped hour.
+		y, mo, d := t.Date()
+		h := t.Hour()
+		n := time.Date(y, mo, d, h-h%ts.n+ts.n, 0, 0, 0, t.Location())
+		if !n.After(t) {
+			n = t.Add(time.Duration(ts.n) * time.Hour)
+		}
+		return n
+	case unitDay:
+		n := t.AddDate(0, 0, ts.n)
+		if ts.n > 1 && n.Month() != t.Month() {
+			// Restart the alignment on the first of the month.
+			n = time.Date(n.Year(), n.Month(), 1, 0, 0, 0, 0, n.Location())
+		}
+		return n
+	case unitWeek:
+		return t.AddDate(0, 0, 7*ts.n)
+	case unitMonth:
+		return t.AddDate(0, ts.n, 0)
+	case unitYear:
+		return t.AddDate(ts.n, 0, 0)
+	}
+	panic("Unknown time unit")
+}
+
+// times returns all times aligned to ts in [min,max].
+func (ts timeStep) times(min, max time.Time) []time.Time {
+	times := []time.Time{}
+	for t := ts.floor(min); !t.After(max); t = ts.next(t) {
+		if !t.Before(min) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// timeSteps are the possible major steps on a time scale, in increasing
+// order, together with the step used for the minor breaks.
+var timeSteps = []struct{ major, minor timeStep }{
+	{timeStep{unitSecond, 1}, timeStep{}},
+	{timeStep{unitSecond, 2}, timeStep{unitSecond, 1}},
+	{timeStep{unitSecond, 5}, timeStep{unitSecond, 1}},
+	{timeStep{unitSecond, 10}, timeStep{unitSecond, 5}},
+	{timeStep{unitSecond, 15}, timeStep{unitSecond, 5}},
+	{timeStep{unitSecond, 30}, timeStep{unitSecond, 10}},
+	{timeStep{unitMinute, 1}, timeStep{unitSecond, 15}},
+	{timeStep{unitMinute, 2}, timeStep{unitMinute, 1}},
+	{timeStep{unitMinute, 5}, timeStep{unitMinute, 1}},
+	{timeStep{unitMinute, 10}, timeStep{unitMinute, 5}},
+	{timeStep{unitMinute, 15}, timeStep{unitMinute, 5}},
+	{timeStep{unitMinute, 30}, timeStep{unitMinute, 10}},
+	{timeStep{unitHour, 1}, timeStep{unitMinute, 15}},
+	{timeStep{unitHour, 2}, timeStep{unitHour, 1}},
+	{timeStep{unitHour, 3}, timeStep{unitHour, 1}},
+	{timeStep{unitHour, 6}, timeStep{unitHour, 3}},
+	{timeStep{unitHour, 12}, timeStep{unitHour, 6}},
+	{timeStep{unitDay, 1}, timeStep{unitHour, 6}},
+	{timeStep{unitDay, 2}, timeStep{unitDay, 1}},
+	{timeStep{unitWeek, 1}, timeStep{unitDay, 1}},
+	{timeStep{unitWeek, 2}, timeStep{unitWeek, 1}},
+	{timeStep{unitMonth, 1}, timeStep{unitWeek, 1}},
+	{timeStep{unitMonth, 2}, timeStep{unitMonth, 1}},
+	{timeStep{unitMonth, 3}, timeStep{unitMonth, 1}},
+	{timeStep{unitMonth, 6}, timeStep{unitMonth, 3}},
+	{timeStep{unitYear, 1}, timeStep{unitMonth, 3}},
+	{timeStep{unitYear, 2}, timeStep{unitYear, 1}},
+	{timeStep{unitYear, 5}, timeStep{unitYear, 1}},
+	{timeStep{unitYear, 10}, timeStep{unitYear, 5}},
+	{timeStep{unitYear, 20}, timeStep{unitYear, 10}},
+	{timeStep{unitYear, 50}, timeStep{unitYear, 10}},
+	{timeStep{unitYear, 100}, timeStep{unitYear, 50}},
+	{timeStep{unitYear, 200}, timeStep{unitYear, 100}},
+	{timeStep{unitYear, 500}, timeStep{unitYear, 100}},
+	{timeStep{unitYear, 1000}, timeStep{unitYear, 500}},
+}
+
+// chooseTimeStep returns the index of the smallest step in timeSteps which
+// yields at most num intervals for a range of span seconds. It returns -1
+// if span is below a second.
+func chooseTimeStep(span float64, num int) int {
+	if span < 1 {
+		return -1
+	}
+	for i, step := range timeSteps {
+		if span/(timeUnitSeconds[step.major.unit]*float64(step.major.n)) <= float64(num) {
+			return i
+		}
+	}
+	return len(timeSteps) - 1
+}
+
+// PrepareDurationBreaks automatically populates s.Breaks (if empty) and
+// s.MinorBreaks (if empty) of the duration scale s with about num breaks
+// in [min,max] on round durations like 15 seconds, 10 minutes or 6 hours.
+func (s *Scale) PrepareDurationBreaks(min, max float64, num int) {
+	unit := float64(s.Duration)
+	dmin, dmax := min*unit, max*unit // in nanoseconds
+	step := chooseDurationStep((dmax-dmin)/float64(num)) / unit
+	if len(s.Breaks) == 0 {
+		for i := math.Ceil(min / step); i*step <= max+step*1e-9; i++ {
+			s.Breaks = append(s.Breaks, i*step)
+		}
+	}
+	if len(s.MinorBreaks) == 0 {
+		s.MinorBreaks = NiceMinorBreaks(s.Breaks, min, max)
+	}
+}
+
+// chooseDurationStep returns a round duration (in nanoseconds) close to
+// delta: Multiples of 1, 2 and 5 of a power of ten for
+// durations below a second or above a day and the usual clock steps
+// like 15 seconds, 10 minutes or 6 hours in between.
+func chooseDurationStep(delta float64) float64 {
+	if delta <= float64(500*time.Millisecond) {
+		return NiceBreaks(0, delta*5, 5)[1] // Use 1, 2 and 5 steps.
+	}
+	for _, d := range []time.Duration{1, 2, 5, 10, 15, 30} {
+		if step := float64(d * time.Second); step >= delta {
+			return step
+		}
+	}
+	for _, d := range []time.Duration{1, 2, 5, 10, 15, 30} {
+		if step := float64(d * time.Minute); step >= delta {
+			return step
+		}
+	}
+	for _, d := range []time.Duration{1, 2, 3, 6, 12, 24} {
+		if step := float64(d * time.Hour); step >= delta {
+			return step
+		}
+	}
+	day := float64(24 * time.Hour)
+	days := NiceBreaks(0, 5*delta/day, 5)
+	return math.Ceil(days[1]) * day
+}
+
+// PrepepareContinousBreaks automatically populates s.Breaks (if empty)
+// and s.MinorBreaks (if empty) with suitable values in the transformed
+// range [min,max].
 func (s *Scale) PrepareContinousBreaks(min, max float64, num int) {
+	t := s.Transform
+	if t == nil {
+		t = &IdentityScale
+	}
+	breaks, minor := t.Breaks, t.MinorBreaks
+	if breaks == nil {
+		// Nice breaks in the untransformed domain.
+		breaks = NiceBreaks
+		if minor == nil {
+			minor = NiceMinorBreaks
+		}
+	}
+
+	// Breaks are generated in the untransformed domain.
+	dmin, dmax := t.Inverse(min), t.Inverse(max)
+	if dmin > dmax {
+		dmin, dmax = dmax, dmin
+	}
+	transform := func(values []float64) []float64 {
+		tv := []float64{}
+		for _, v := range values {
+			x := t.Trans(v)
+			if math.IsNaN(x) || math.IsInf(x, 0) || x < min || x > max {
+				continue
+			}
+			tv = append(tv, x)
+		}
+		sort.Float64s(tv)
+		return tv
+	}
+
+	var major []float64
+	if len(s.Breaks) == 0 {
+		major = breaks(dmin, dmax, num)
+		s.Breaks = transform(major)
+	} else {
+		for _, b := range s.Breaks {
+			major = append(major, t.Inverse(b))
+		}
+		sort.Float64s(major)
+	}
+	if len(s.MinorBreaks) == 0 && minor != nil {
+		s.MinorBreaks = transform(minor(major, dmin, dmax))
+	}
+
+	fmt.Printf("    PrepareContinousBreaks(%.2f, %.2f, %d) transform=%s n=%d minor=%d\n",
+		min, max, num, t.Name, len(s.Breaks), len(s.MinorBreaks))
+}
+
+// NiceBreaks returns about num breaks in [min,max] which are
+// multiples of 1, 2, 2.5 or 5 times a power of ten.
+func NiceBreaks(min, max float64, num int) []float64 {
 	fullRange := max - min
+	if fullRange <= 0 || math.IsInf(fullRange, 0) || math.IsNaN(fullRange) {
+		return []float64{min}
+	}
 
 	// Decompose delta into the form delta = f * mag
 	// with mag a power of 10 and 0 < f < 10.
@@ -446,13 +1106,317 @@ func (s *Scale) PrepareContinousBreaks(min, max float64, num int) {
 	}
 	step *= mag
 
-	x := math.Ceil(min/step) * step
-	for x < s.DomainMax {
-		s.Breaks = append(s.Breaks, x)
-		x += step
+	breaks := []float64{}
+	for i := math.Ceil(min / step); i*step <= max+step*1e-9; i++ {
+		breaks = append(breaks, i*step)
+	}
+	return breaks
+}
+
+// NiceMinorBreaks returns the midpoints between the (equidistant) major
+// breaks which lie in [min,max].
+func NiceMinorBreaks(major []float64, min, max float64) []float64 {
+	if len(major) < 2 {
+		return nil
+	}
+	step := major[1] - major[0]
+	minor := []float64{}
+	for x := major[0] - step/2; x <= max; x += step {
+		if x >= min {
+			minor = append(minor, x)
+		}
 	}
+	return minor
+}
 
-	fmt.Printf("    PrepareContinousBreaks(%.2f, %.2f, %d) delta=%.3f mag=%.3f f=%.3f step=%.3f x0=%.3f n=%d\n", min, max, num, delta, mag, f, step, math.Ceil(min/step)*step, len(s.Breaks))
+// LogBreaks returns about num breaks in [min,max] for a log10 transformed
+// scale: Powers of ten, refined by 2 and 5 (or all multiples 1 to 9) if
+// there are too few decades in [min,max]. For ranges well below one decade
+// NiceBreaks are used.
+func LogBreaks(min, max float64, num int) []float64 {
+	if min <= 0 || max <= min {
+		return NiceBreaks(min, max, num)
+	}
+	lo, hi := int(math.Floor(math.Log10(min))), int(math.Ceil(math.Log10(max)))
+	for _, mult := range [][]float64{{1}, {1, 2, 5}, {1, 2, 3, 4, 5, 6, 7, 8, 9}} {
+		breaks := logMultiples(lo, hi, mult, min, max)
+		if n := len(breaks); n > 2*num && len(mult) == 1 {
+			// Too many decades: Use every k'th decade only.
+			k := (n + num - 1) / num
+			thinned := []float64{}
+			for i := 0; i < n; i += k {
+				thinned = append(thinned, breaks[i])
+			}
+			return thinned
+		} else if n >= num-2 && n >= 3 {
+			return breaks
+		}
+	}
+	return NiceBreaks(min, max, num)
+}
+
+// LogMinorBreaks returns minor breaks for a log10 scale: The 2 and 5
+// multiples if the major breaks are just powers of ten or else all
+// multiples 1 to 9 which are not major breaks.
+func LogMinorBreaks(major []float64, min, max float64) []float64 {
+	if min <= 0 || max <= min || len(major) < 2 {
+		return NiceMinorBreaks(major, min, max)
+	}
+	lo, hi := int(math.Floor(math.Log10(min))), int(math.Ceil(math.Log10(max)))
+	isMajor := make(map[float64]bool)
+	decadesOnly := true
+	for _, b := range major {
+		isMajor[b] = true
+		if e := math.Log10(b); math.Abs(e-math.Floor(e+0.5)) > 1e-9 {
+			decadesOnly = false
+		}
+	}
+	mult := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if decadesOnly {
+		if math.Log10(major[1]/major[0]) > 1.5 {
+			// Thinned decades: Every decade is a minor break.
+			mult = []float64{1}
+		} else {
+			mult = []float64{1, 2, 5}
+		}
+	}
+	minor := []float64{}
+	for _, x := range logMultiples(lo, hi, mult, min, max) {
+		if !isMajor[x] {
+			minor = append(minor, x)
+		}
+	}
+	return minor
+}
+
+// logMultiples returns all values m*10^e for m in mult and e in [lo,hi]
+// which lie in [min,max].
+func logMultiples(lo, hi int, mult []float64, min, max float64) []float64 {
+	values := []float64{}
+	for e := lo; e <= hi; e++ {
+		for _, m := range mult {
+			// Avoid rounding errors like 3*0.1 = 0.30000000000000004.
+			x, _ := strconv.ParseFloat(fmt.Sprintf("%ge%d", m, e), 64)
+			if x >= min*(1-1e-9) && x <= max*(1+1e-9) {
+				values = append(values, x)
+			}
+		}
+	}
+	return values
+}
+
+// Log2Breaks returns about num powers of two in [min,max] for a log2
+// transformed scale. For ranges below two doublings NiceBreaks are used.
+func Log2Breaks(min, max float64, num int) []float64 {
+	if min <= 0 || max <= min {
+		return NiceBreaks(min, max, num)
+	}
+	lo, hi := int(math.Ceil(math.Log2(min)-1e-9)), int(math.Floor(math.Log2(max)+1e-9))
+	if hi-lo < 2 {
+		return NiceBreaks(min, max, num)
+	}
+	k := 1
+	if n := hi - lo + 1; n > 2*num {
+		k = (n + num - 1) / num
+	}
+	breaks := []float64{}
+	for e := lo; e <= hi; e += k {
+		breaks = append(breaks, math.Exp2(float64(e)))
+	}
+	return breaks
+}
+
+// Log2MinorBreaks returns minor breaks for a log2 scale: The values
+// 1.5*2^e between consecutive powers of two or the skipped powers of two
+// if the major breaks are thinned. Major breaks which are not powers of
+// two get NiceMinorBreaks.
+func Log2MinorBreaks(major []float64, min, max float64) []float64 {
+	if min <= 0 || max <= min || len(major) < 2 {
+		return NiceMinorBreaks(major, min, max)
+	}
+	for _, b := range major {
+		if e := math.Log2(b); math.Abs(e-math.Floor(e+0.5)) > 1e-9 {
+			return NiceMinorBreaks(major, min, max)
+		}
+	}
+	isMajor := make(map[float64]bool)
+	for _, b := range major {
+		isMajor[b] = true
+	}
+	mult := 1.5
+	if math.Log2(major[1]/major[0]) > 1.5 {
+		// Thinned powers: Every power of two is a minor break.
+		mult = 1
+	}
+	lo, hi := int(math.Floor(math.Log2(min))), int(math.Ceil(math.Log2(max)))
+	minor := []float64{}
+	for e := lo; e <= hi; e++ {
+		x := mult * math.Exp2(float64(e))
+		if x >= min*(1-1e-9) && x <= max*(1+1e-9) && !isMajor[x] {
+			minor = append(minor, x)
+		}
+	}
+	return minor
+}
+
+// Log1pBreaks returns breaks for a log1p transformed scale: 0 (if in
+// [min,max]) and LogBreaks above 1. Ranges below 10 use NiceBreaks.
+func Log1pBreaks(min, max float64, num int) []float64 {
+	if max < 10 || max <= min {
+		return NiceBreaks(min, max, num)
+	}
+	breaks := []float64{}
+	if min <= 0 {
+		breaks = append(breaks, 0)
+	}
+	return append(breaks, LogBreaks(math.Max(min, 1), max, num)...)
+}
+
+// Log1pMinorBreaks returns minor breaks for a log1p scale: The
+// LogMinorBreaks above 1. Ranges below 10 use NiceMinorBreaks.
+func Log1pMinorBreaks(major []float64, min, max float64) []float64 {
+	if max < 10 || max <= min {
+		return NiceMinorBreaks(major, min, max)
+	}
+	positive := []float64{}
+	for _, b := range major {
+		if b > 0 {
+			positive = append(positive, b)
+		}
+	}
+	return LogMinorBreaks(positive, math.Max(min, 1), max)
+}
+
+// PseudoLogBreaks returns breaks for a pseudo-log scale: 0 and the powers
+// of ten with both signs which lie in [min,max]. Ranges not containing 0
+// use LogBreaks and small ranges NiceBreaks.
+func PseudoLogBreaks(min, max float64, num int) []float64 {
+	top := math.Max(math.Abs(min), math.Abs(max))
+	switch {
+	case max <= min || top < 10:
+		return NiceBreaks(min, max, num)
+	case min > 0:
+		return LogBreaks(min, max, num)
+	case max < 0:
+		neg := LogBreaks(-max, -min, num)
+		breaks := make([]float64, len(neg))
+		for i, b := range neg {
+			breaks[len(neg)-1-i] = -b
+		}
+		return breaks
+	}
+
+	// The pseudo-log is linear around 0: ±1 are too close to 0 to be
+	// labeled if the range spans several decades.
+	n := int(math.Floor(math.Log10(top) + 1e-9))
+	start := 0
+	if n >= 3 {
+		start = 1
+	}
+	k := 1
+	if n-start+1 > num {
+		k = (n - start + num) / num
+	}
+	powers := []float64{}
+	for e := start; e <= n; e += k {
+		powers = append(powers, math.Pow10(e))
+	}
+	breaks := []float64{}
+	for i := len(powers) - 1; i >= 0; i-- {
+		if -powers[i] >= min {
+			breaks = append(breaks, -powers[i])
+		}
+	}
+	breaks = append(breaks, 0)
+	for _, p := range powers {
+		if p <= max {
+			breaks = append(breaks, p)
+		}
+	}
+	return breaks
+}
+
+// PseudoLogMinorBreaks returns minor breaks for a pseudo-log scale: The
+// LogMinorBreaks between the positive and between the negative major
+// breaks. Small ranges use NiceMinorBreaks.
+func PseudoLogMinorBreaks(major []float64, min, max float64) []float64 {
+	top := math.Max(math.Abs(min), math.Abs(max))
+	switch {
+	case max <= min || top < 10:
+		return NiceMinorBreaks(major, min, max)
+	case min > 0:
+		return LogMinorBreaks(major, min, max)
+	}
+
+	positive, negative := []float64{}, []float64{}
+	for _, b := range major {
+		if b > 0 {
+			positive = append(positive, b)
+		} else if b < 0 {
+			negative = append([]float64{-b}, negative...)
+		}
+	}
+	minor := []float64{}
+	if len(negative) > 0 {
+		neg := LogMinorBreaks(negative, negative[0], -min)
+		for i := len(neg) - 1; i >= 0; i-- {
+			minor = append(minor, -neg[i])
+		}
+	}
+	if len(positive) > 0 {
+		minor = append(minor, LogMinorBreaks(positive, positive[0], max)...)
+	}
+	return minor
+}
+
+// ProbabilityBreaks returns breaks for logit and probit transformed
+// scales: Probabilities like 0.01, 0.1, 0.5, 0.9, 0.99 in [min,max],
+// refined by 0.05, 0.25, 0.75 and 0.95 if these are too few.
+func ProbabilityBreaks(min, max float64, num int) []float64 {
+	for _, candidates := range probabilityBreaks {
+		breaks := []float64{}
+		for _, p := range candidates {
+			if p >= min*(1-1e-9) && p <= max*(1+1e-9) {
+				breaks = append(breaks, p)
+			}
+		}
+		if len(breaks) >= 3 {
+			return breaks
+		}
+	}
+	return NiceBreaks(min, max, num)
+}
+
+// probabilityBreaks are the candidates for ProbabilityBreaks, the second
+// set refines the first one.
+var probabilityBreaks = [][]float64{
+	{1e-4, 1e-3, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999, 0.9999},
+	{1e-4, 1e-3, 0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 0.9999},
+}
+
+// ProbabilityMinorBreaks returns minor breaks for logit and probit scales:
+// The refining probabilities 0.05, 0.25, 0.75 and 0.95 in [min,max] which
+// are not major breaks. Other major breaks get NiceMinorBreaks.
+func ProbabilityMinorBreaks(major []float64, min, max float64) []float64 {
+	refined := probabilityBreaks[len(probabilityBreaks)-1]
+	isCandidate := make(map[float64]bool)
+	for _, p := range refined {
+		isCandidate[p] = true
+	}
+	isMajor := make(map[float64]bool)
+	for _, b := range major {
+		if !isCandidate[b] {
+			return NiceMinorBreaks(major, min, max)
+		}
+		isMajor[b] = true
+	}
+	minor := []float64{}
+	for _, p := range refined {
+		if p >= min*(1-1e-9) && p <= max*(1+1e-9) && !isMajor[p] {
+			minor = append(minor, p)
+		}
+	}
+	return minor
 }
 
 // PrepareLabels sets up s.Labels (if empty) by formating s.Breaks.
@@ -461,11 +1425,22 @@ func (s *Scale) PrepareLabels() {
 	if len(s.Breaks) == 0 {
 		return
 	}
-	if len(s.Labels) == 0 {
-		// Automatic label creation.
-		formatter := s.ChooseFloatFormatter()
+	if len(s.Labels) == 0 && s.Formatter != nil {
+		s.Labels = s.Formatter(s.untransformedBreaks())
+	} else if len(s.Labels) == 0 && s.Time {
+		s.Labels = s.timeLabels()
+	} else if len(s.Labels) == 0 && s.Duration > 0 {
 		for _, b := range s.Breaks {
-			s.Labels = append(s.Labels, formatter(b))
+			s.Labels = append(s.Labels, FormatDuration(time.Duration(b*float64(s.Duration))))
+		}
+	} else if len(s.Labels) == 0 {
+		// Automatic label creation. Breaks of transformed scales
+		// are labeled with the untransformed values.
+		s.Labels = NumberFormat{}.Format(s.untransformedBreaks())
+		if t := s.Transform; t != nil && t.Format != nil {
+			for i, b := range s.Breaks {
+				s.Labels[i] = t.Format(b, s.Labels[i])
+			}
 		}
 	} else {
 		// User provided labels. Sanitize them.
@@ -478,68 +1453,483 @@ func (s *Scale) PrepareLabels() {
 	}
 }
 
-// TODO: Much more logic needed
+// ChooseFloatFormatter returns a function which formats a single value
+// like the breaks of s: The breaks get the labels of NumberFormat, other
+// values are formated on their own.
+//
+// Deprecated: Use NumberFormat, which chooses a common precision for all
+// breaks, or set Formatter.
 func (s *Scale) ChooseFloatFormatter() func(x float64) string {
-	f := "%.1f"
-	if math.Abs(s.Breaks[0]) < 1 || math.Abs(s.Breaks[len(s.Breaks)-1]) < 1 {
-		f = "%.1f" // BUG
-	}
+	breaks := append([]float64(nil), s.Breaks...)
+	labels := NumberFormat{}.Format(breaks)
 	return func(x float64) string {
-		return fmt.Sprintf(f, x)
+		for i, b := range breaks {
+			if b == x {
+				return labels[i]
+			}
+		}
+		return NumberFormat{}.Format([]float64{x})[0]
+	}
+}
+
+// timeLabels formats the breaks of the time scale s with s.TimeFormat or
+// an automatic layout: The layout shows the precision of the breaks and
+// drops the parts common to all breaks, e.g. "15:04" for hourly breaks
+// on one day but "Jan 2 15:04" if the breaks span several days.
+func (s *Scale) timeLabels() []string {
+	times := make([]time.Time, len(s.Breaks))
+	for i, b := range s.Breaks {
+		times[i] = s.TimeOf(b)
+	}
+
+	layout := s.TimeFormat
+	if layout == "" {
+		layout = autoTimeLayout(times)
+	}
+	labels := make([]string, len(times))
+	for i, t := range times {
+		labels[i] = t.Format(layout)
+	}
+	return labels
+}
+
+// autoTimeLayout returns a layout for labeling the times.
+func autoTimeLayout(times []time.Time) string {
+	sameYear, sameDay := true, true
+	fraction, second, clock, day := false, false, false, false
+	for _, t := range times {
+		if t.Year() != times[0].Year() {
+			sameYear = false
+		}
+		if t.YearDay() != times[0].YearDay() || !sameYear {
+			sameDay = false
+		}
+		h, m, sec := t.Clock()
+		fraction = fraction || t.Nanosecond() != 0
+		second = second || sec != 0
+		clock = clock || h != 0 || m != 0
+		day = day || t.Day() != 1
+	}
+
+	switch {
+	case fraction || second || clock:
+		clk := "15:04"
+		if fraction {
+			clk = "15:04:05.999"
+		} else if second {
+			clk = "15:04:05"
+		}
+		if sameDay {
+			return clk
+		} else if sameYear {
+			return "Jan 2 " + clk
+		}
+		return "2006-01-02 " + clk
+	case day:
+		if sameYear {
+			return "Jan 2"
+		}
+		return "Jan 2, 2006"
+	}
+	for _, t := range times {
+		if t.Month() != time.January {
+			if sameYear {
+				return "Jan"
+			}
+			return "Jan 2006"
+		}
+	}
+	return "2006"
+}
+
+// FormatDuration formats d like time.Duration.String but without
+// trailing zero units: 90 minutes are formated as "1h30m" and not
+// as "1h30m0s".
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if d%time.Second != 0 {
+		return s
+	}
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// untransformedBreaks returns the breaks of s in the untransformed domain.
+func (s *Scale) untransformedBreaks() []float64 {
+	values := make([]float64, len(s.Breaks))
+	for i, b := range s.Breaks {
+		if t := s.Transform; t != nil {
+			b = t.Inverse(b)
+		}
+		values[i] = b
 	}
+	return values
 }
 
 // -------------------------------------------------------------------------
 // Scale Transformations
 
+// ScaleTransform is a transformation of the data of a continuous scale,
+// e.g. a logarithm. Scales and stats work on the transformed data, the
+// breaks are chosen and labeled in the untransformed domain.
 type ScaleTransform struct {
 	Name    string
 	Trans   func(float64) float64
 	Inverse func(float64) float64
-	Format  func(float64, string) string
+
+	// Domain reports whether x is a valid input to Trans, e.g. x > 0
+	// for logarithms. Rows with data outside the domain are removed.
+	// Nil means all values are valid.
+	Domain func(x float64) bool
+
+	// Format returns the label of the break y (in the transformed
+	// domain) given the label s of its untransformed value as produced
+	// by NumberFormat. Nil uses s.
+	Format func(y float64, s string) string
+
+	// Breaks returns about n major breaks in the untransformed range
+	// [min,max] and MinorBreaks the minor breaks for the given major
+	// ones. A nil Breaks uses NiceBreaks and NiceMinorBreaks.
+	Breaks      func(min, max float64, n int) []float64
+	MinorBreaks func(major []float64, min, max float64) []float64
+}
+
+// NewTransform returns a transformation with the given name from the
+// one-to-one function trans and its inverse. It uses NiceBreaks.
+func NewTransform(name string, trans, inverse func(float64) float64) *ScaleTransform {
+	return &ScaleTransform{
+		Name:        name,
+		Trans:       trans,
+		Inverse:     inverse,
+		Breaks:      NiceBreaks,
+		MinorBreaks: NiceMinorBreaks,
+	}
 }
 
+// Transforms contains the predefined transformations by name. Custom
+// transformations may be added.
+var Transforms = map[string]*ScaleTransform{
+	"identity":   &IdentityScale,
+	"log10":      &Log10Scale,
+	"log2":       &Log2Scale,
+	"log":        &LogScale,
+	"log1p":      &Log1pScale,
+	"pseudo_log": &PseudoLogScale,
+	"sqrt":       &SqrtScale,
+	"reciprocal": &InvScale,
+	"exp":        &ExpScale,
+	"logit":      &LogitScale,
+	"probit":     &ProbitScale,
+	"reverse":    &ReverseScale,
+}
+
+func positive(x float64) bool    { return x > 0 }
+func nonNegative(x float64) bool { return x >= 0 }
+func nonZero(x float64) bool     { return x != 0 }
+
+// probability reports whether p lies in the open interval (0,1).
+func probability(p float64) bool { return p > 0 && p < 1 }
+
 var IdentityScale = ScaleTransform{
-	Name:    "Identity",
-	Trans:   func(x float64) float64 { return x },
-	Inverse: func(y float64) float64 { return y },
-	Format:  func(y float64, s string) string { return s },
+	Name:        "Identity",
+	Trans:       func(x float64) float64 { return x },
+	Inverse:     func(y float64) float64 { return y },
+	Breaks:      NiceBreaks,
+	MinorBreaks: NiceMinorBreaks,
 }
 
 var Log10Scale = ScaleTransform{
-	Name:    "Log10",
-	Trans:   func(x float64) float64 { return math.Log10(x) },
-	Inverse: func(y float64) float64 { return math.Pow(10, y) },
-	Format:  func(y float64, s string) string { return fmt.Sprintf("10^{%s}", s) },
+	Name:        "Log10",
+	Trans:       math.Log10,
+	Inverse:     func(y float64) float64 { return math.Pow(10, y) },
+	Domain:      positive,
+	Breaks:      LogBreaks,
+	MinorBreaks: LogMinorBreaks,
+}
+
+// Log2Scale has breaks at powers of two.
+var Log2Scale = ScaleTransform{
+	Name:        "Log2",
+	Trans:       math.Log2,
+	Inverse:     math.Exp2,
+	Domain:      positive,
+	Breaks:      Log2Breaks,
+	MinorBreaks: Log2MinorBreaks,
+}
+
+// LogScale is the natural logarithm. Its breaks are the same as the
+// ones of Log10Scale.
+var LogScale = ScaleTransform{
+	Name:        "Log",
+	Trans:       math.Log,
+	Inverse:     math.Exp,
+	Domain:      positive,
+	Breaks:      LogBreaks,
+	MinorBreaks: LogMinorBreaks,
+}
+
+// Log1pScale is log(1+x) which is useful for counts including 0.
+var Log1pScale = ScaleTransform{
+	Name:        "Log1p",
+	Trans:       math.Log1p,
+	Inverse:     math.Expm1,
+	Domain:      func(x float64) bool { return x > -1 },
+	Breaks:      Log1pBreaks,
+	MinorBreaks: Log1pMinorBreaks,
+}
+
+// PseudoLogScale is asinh(x/2)/ln(10), a signed logarithm: It is linear
+// around 0 and approaches ±log10(|x|) for large |x|. It handles zero and
+// negative values.
+var PseudoLogScale = ScaleTransform{
+	Name:        "PseudoLog",
+	Trans:       func(x float64) float64 { return math.Asinh(x/2) / math.Ln10 },
+	Inverse:     func(y float64) float64 { return 2 * math.Sinh(y*math.Ln10) },
+	Breaks:      PseudoLogBreaks,
+	MinorBreaks: PseudoLogMinorBreaks,
 }
 
 var InvScale = ScaleTransform{
-	Name:    "1/x",
-	Trans:   func(x float64) float64 { return 1 / x },
-	Inverse: func(y float64) float64 { return 1 / y },
-	Format:  func(y float64, s string) string { return fmt.Sprintf("1/{%s}", s) },
+	Name:        "1/x",
+	Trans:       func(x float64) float64 { return 1 / x },
+	Inverse:     func(y float64) float64 { return 1 / y },
+	Domain:      nonZero,
+	Breaks:      NiceBreaks,
+	MinorBreaks: NiceMinorBreaks,
 }
 
 var SqrtScale = ScaleTransform{
-	Name:    "Sqrt",
-	Trans:   func(x float64) float64 { return math.Sqrt(x) },
-	Inverse: func(y float64) float64 { return y * y },
-	Format:  func(y float64, s string) string { return fmt.Sprintf("%.1f", y*y) },
+	Name:        "Sqrt",
+	Trans:       math.Sqrt,
+	Inverse:     func(y float64) float64 { return y * y },
+	Domain:      nonNegative,
+	Breaks:      NiceBreaks,
+	MinorBreaks: NiceMinorBreaks,
+}
+
+var ExpScale = ScaleTransform{
+	Name:        "Exp",
+	Trans:       math.Exp,
+	Inverse:     math.Log,
+	Breaks:      NiceBreaks,
+	MinorBreaks: NiceMinorBreaks,
+}
+
+// LogitScale is log(p/(1-p)) for probabilities p in (0,1).
+var LogitScale = ScaleTransform{
+	Name:        "Logit",
+	Trans:       func(p float64) float64 { return math.Log(p / (1 - p)) },
+	Inverse:     func(y float64) float64 { return 1 / (1 + math.Exp(-y)) },
+	Domain:      probability,
+	Breaks:      ProbabilityBreaks,
+	MinorBreaks: ProbabilityMinorBreaks,
+}
+
+// ProbitScale is the quantile function of the standard normal
+// distribution for probabilities p in (0,1).
+var ProbitScale = ScaleTransform{
+	Name:        "Probit",
+	Trans:       func(p float64) float64 { return math.Sqrt2 * math.Erfinv(2*p-1) },
+	Inverse:     func(y float64) float64 { return math.Erfc(-y/math.Sqrt2) / 2 },
+	Domain:      probability,
+	Breaks:      ProbabilityBreaks,
+	MinorBreaks: ProbabilityMinorBreaks,
+}
+
+// ReverseScale reverses the direction of a position scale: Large values
+// are drawn left respectively at the bottom. The breaks are labeled with
+// the original values.
+var ReverseScale = ScaleTransform{
+	Name:        "Reverse",
+	Trans:       func(x float64) float64 { return -x },
+	Inverse:     func(y float64) float64 { return -y },
+	Breaks:      NiceBreaks,
+	MinorBreaks: NiceMinorBreaks,
+}
+
+// BoxCoxScale returns the Box-Cox power transformation (x^λ - 1)/λ with
+// λ = lambda for positive x. Lambda 0 is the natural logarithm.
+func BoxCoxScale(lambda float64) *ScaleTransform {
+	t := NewTransform(fmt.Sprintf("Box-Cox(%g)", lambda),
+		func(x float64) float64 { return (math.Pow(x, lambda) - 1) / lambda },
+		func(y float64) float64 { return math.Pow(lambda*y+1, 1/lambda) })
+	if lambda == 0 {
+		t.Trans, t.Inverse = math.Log, math.Exp
+	}
+	t.Domain = positive
+	return t
+}
+
+// transform applies the transformation of s to the field aes of data.
+// Rows with data outside the domain of the transformation are removed,
+// their number is returned.
+func (s *Scale) transform(data *DataFrame, aes string) int {
+	t := s.Transform
+	if _, ok := data.Columns[aes]; !ok {
+		return 0
+	}
+	removed := 0
+	if t.Domain != nil {
+		d := data.Columns[aes].Data
+		removed = data.FilterRows(func(i int) bool {
+			return math.IsNaN(d[i]) || t.Domain(d[i])
+		})
+	}
+	data.Columns[aes].Apply(t.Trans)
+	return removed
+}
+
+// -------------------------------------------------------------------------
+// Secondary axes
+
+// SecondaryAxis is a second axis of a x or y scale. It shows the values of
+// the scale converted to a different unit, e.g. °F for a scale in °C.
+type SecondaryAxis struct {
+	// Name is the title of the secondary axis.
+	Name string
+
+	// Trans converts the (untransformed) values of the scale to the
+	// values shown on the secondary axis. Trans must be one-to-one,
+	// e.g. func(c float64) float64 { return 1.8*c + 32 }. Inverse is
+	// the inverse of Trans; if nil it is computed numerically.
+	// A nil Trans duplicates the scale, as do all secondary axes of
+	// discrete and time scales.
+	Trans, Inverse func(float64) float64
+
+	// Breaks are the tics in the units of the secondary axis and Labels
+	// their labels. Empty Breaks are generated automatically and empty
+	// Labels by Formatter or NumberFormat{}.
+	Breaks    []float64
+	Labels    []string
+	Formatter func(breaks []float64) []string
+}
+
+// DupAxis returns a secondary axis which duplicates its scale.
+func DupAxis() *SecondaryAxis {
+	return &SecondaryAxis{}
+}
+
+// secondaryName returns the title of the secondary axis of s.
+func (s *Scale) secondaryName() string {
+	sec := s.Secondary
+	if sec.Name == "" && sec.Trans == nil {
+		return s.Name
+	}
+	return sec.Name
+}
+
+// prepareSecondary sets up the tics of the secondary axis of the
+// finalized scale s.
+func (s *Scale) prepareSecondary() {
+	s.secBreaks, s.secLabels = nil, nil
+	sec := s.Secondary
+	if sec == nil {
+		return
+	}
+	if sec.Trans == nil || s.Discrete || s.Time {
+		// Duplicate the axis. Time and discrete values cannot be
+		// converted meaningfully.
+		s.secBreaks, s.secLabels = s.Breaks, s.Labels
+		if len(sec.Labels) == len(s.Breaks) {
+			s.secLabels = sec.Labels
+		}
+		return
+	}
+
+	t := s.Transform
+	if t == nil {
+		t = &IdentityScale
+	}
+	dmin, dmax := t.Inverse(s.Min), t.Inverse(s.Max)
+	if dmin > dmax {
+		dmin, dmax = dmax, dmin
+	}
+	lo, hi := sec.Trans(dmin), sec.Trans(dmax)
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	inverse := sec.Inverse
+	if inverse == nil {
+		inverse = func(y float64) float64 { return invert(sec.Trans, y, dmin, dmax) }
+	}
+
+	breaks := sec.Breaks
+	if len(breaks) == 0 {
+		breaks = NiceBreaks(lo, hi, 5)
+	}
+	shown := []float64{}
+	for _, b := range breaks {
+		if b < lo || b > hi {
+			continue
+		}
+		x := t.Trans(inverse(b))
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			continue
+		}
+		shown = append(shown, b)
+		s.secBreaks = append(s.secBreaks, x)
+	}
+
+	switch {
+	case len(sec.Labels) == len(shown):
+		s.secLabels = sec.Labels
+	case sec.Formatter != nil:
+		s.secLabels = sec.Formatter(shown)
+	default:
+		s.secLabels = NumberFormat{}.Format(shown)
+	}
+}
+
+// invert returns the x in [lo,hi] with f(x) = y for the monotonic f.
+func invert(f func(float64) float64, y, lo, hi float64) float64 {
+	increasing := f(hi) >= f(lo)
+	for i := 0; i < 100 && hi-lo > 1e-12*math.Max(1, math.Abs(hi)); i++ {
+		mid := (lo + hi) / 2
+		if (f(mid) < y) == increasing {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
 }
 
 // -------------------------------------------------------------------------
 // Rendering of scales
 
+// Render renders the legend of s with generic keys.
 func (s *Scale) Render() (grobs Grob, width vg.Length, height vg.Length) {
-	if !s.Discrete && (s.Aesthetic == "color" || s.Aesthetic == "fill") {
+	return s.render(nil)
+}
+
+// render renders the legend of s. The keys are drawn by the geoms in keys;
+// if keys is empty generic keys are used.
+func (s *Scale) render(keys []LegendKey) (grobs Grob, width vg.Length, height vg.Length) {
+	isColor := s.Aesthetic == "color" || s.Aesthetic == "fill"
+	switch {
+	case s.Binned && isColor:
+		return s.renderColorSteps()
+	case s.Binned:
+		return s.renderKeys(keys, s.binValues(), s.binLabels())
+	case !s.Discrete && isColor:
 		return s.renderColorContinuous()
 	}
-	return s.renderDiscrete()
+	return s.renderDiscrete(keys)
 }
 
 // renderOther renders all non-color scales.
 // TODO: combine with renderColorDiscrete
-func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
+func (s *Scale) renderDiscrete(keys []LegendKey) (g Grob, width vg.Length, height vg.Length) {
+	return s.renderKeys(keys, s.Breaks, s.Labels)
+}
+
+// renderKeys renders a legend with one key for each of the values.
+func (s *Scale) renderKeys(keys []LegendKey, values []float64, labels []string) (g Grob, width vg.Length, height vg.Length) {
 	size := float64(6 * vg.Millimeter)
 	dx := float64(2 * vg.Millimeter)
 	dy := float64(2 * vg.Millimeter)
@@ -548,7 +1938,7 @@ func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
 	bgCol := BuiltinColors["gray80"]
 
 	y := 0.0
-	for i, v := range s.Breaks {
+	for i, v := range values {
 		// Gray background and label.
 		rect := GrobRect{
 			xmin: 0, xmax: size,
@@ -558,7 +1948,7 @@ func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
 		label := GrobText{
 			x:     size + dx,
 			y:     y + size/2,
-			text:  s.Labels[i],
+			text:  labels[i],
 			color: BuiltinColors["black"],
 			vjust: 0.5, hjust: 0,
 		}
@@ -567,14 +1957,21 @@ func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
 			width = lw
 		}
 
-		// The actual key. TODO: think abut it at least a tiny bit, please!
-		// TODO: the non-mapped aestetics should be settable by the user/geom/theme/whatever.
+		// Keys drawn by the geoms themself.
+		var geomKeys []Grob
+		for _, k := range keys {
+			k.Value = v
+			geomKeys = append(geomKeys, k.Geom.Key(k, 0, y, size)...)
+		}
+
+		// Generic key if no geom draws its own key, e.g. for geoms
+		// outside of this package not implementing KeyGeom.
 		var key Grob
 		switch s.Aesthetic {
 		case "size":
 			key = GrobPoint{
 				x: size / 2, y: y + size/2,
-				size:  1 + 9*s.Pos(v), // must match values in GeomPoint!
+				size:  s.floatValue(v, 1, 10), // must match values in GeomPoint!
 				shape: SolidCirclePoint,
 				color: BuiltinColors["blue"],
 			}
@@ -603,7 +2000,11 @@ func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
 
 		y += size + dy
 		grobs = append(grobs, rect)
-		grobs = append(grobs, key)
+		if len(geomKeys) > 0 {
+			grobs = append(grobs, geomKeys...)
+		} else if key != nil {
+			grobs = append(grobs, key)
+		}
 		grobs = append(grobs, label)
 	}
 
@@ -625,6 +2026,75 @@ func (s *Scale) renderDiscrete() (g Grob, width vg.Length, height vg.Length) {
 	return GrobGroup{elements: grobs}, width, height
 }
 
+// floatValue maps x to [min,max] or returns the value of a manual or
+// identity scale.
+func (s *Scale) floatValue(x, min, max float64) float64 {
+	if s.Value != nil {
+		return String2Float(s.Value(x), math.Inf(-1), math.Inf(+1))
+	}
+	return s.Pos(x)*(max-min) + min
+}
+
+// renderColorSteps renders the legend of a binned color scale: One box
+// of equal height per bin with the breaks labeled between the boxes.
+func (s *Scale) renderColorSteps() (g Grob, width vg.Length, height vg.Length) {
+	sizeX := float64(6 * vg.Millimeter)
+	sizeY := float64(50 * vg.Millimeter)
+	sep := float64(2 * vg.Millimeter)
+
+	grobs := []Grob{}
+	values := s.binValues()
+	dy := sizeY / float64(len(values))
+	for i, v := range values {
+		grobs = append(grobs, GrobRect{
+			xmin: 0, xmax: sizeX,
+			ymin: float64(i) * dy, ymax: float64(i+1) * dy,
+			fill: s.Color(v),
+		})
+	}
+	for i, txt := range s.Labels {
+		y := float64(i+1) * dy
+		grobs = append(grobs, GrobLine{
+			x0: 0, x1: sizeX,
+			y0: y, y1: y,
+			size:     1,
+			linetype: SolidLine,
+			color:    BuiltinColors["white"],
+		})
+		label := GrobText{
+			x:     sizeX + sep,
+			y:     y,
+			text:  txt,
+			color: BuiltinColors["black"],
+			size:  12, // TODO: make configurable
+			vjust: 0.5, hjust: 0,
+		}
+		lw, _ := label.BoundingBox()
+		if width < lw {
+			width = lw
+		}
+		grobs = append(grobs, label)
+	}
+
+	title := GrobText{
+		x: 0, y: sizeY + sep,
+		text:  s.Name,
+		size:  12, // TODO: make configurable
+		color: BuiltinColors["black"],
+		vjust: 0, hjust: 0,
+	}
+	grobs = append(grobs, title)
+	tw, th := title.BoundingBox()
+	if width < tw {
+		width = tw
+	}
+
+	width += vg.Length(sizeX + sep)
+	height = vg.Length(sizeY+sep) + th
+
+	return GrobGroup{elements: grobs}, width, height
+}
+
 // renders a continuous color scale
 func (s *Scale) renderColorContinuous() (g Grob, width vg.Length, height vg.Length) {
 	sizeX := float64(6 * vg.Millimeter)
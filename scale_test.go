@@ -0,0 +1,318 @@
+package plot
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sameFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9*math.Max(1, math.Abs(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTransformBreaks(t *testing.T) {
+	for i, tc := range []struct {
+		breaks       func(min, max float64, n int) []float64
+		min, max     float64
+		major, minor []float64
+	}{
+		{NiceBreaks, 55, 125, []float64{60, 70, 80, 90, 100, 110, 120},
+			[]float64{55, 65, 75, 85, 95, 105, 115, 125}},
+		{LogBreaks, 0.01, 100, []float64{0.01, 0.1, 1, 10, 100},
+			[]float64{0.02, 0.05, 0.2, 0.5, 2, 5, 20, 50}},
+		{LogBreaks, 12, 88, []float64{20, 30, 40, 50, 60, 70, 80}, nil},
+		{LogBreaks, 3, 2000, []float64{10, 100, 1000},
+			[]float64{5, 20, 50, 200, 500, 2000}},
+		{LogBreaks, 1, 1e12, []float64{1, 1e3, 1e6, 1e9, 1e12}, nil},
+	} {
+		major := tc.breaks(tc.min, tc.max, 5)
+		if tc.major != nil && !sameFloats(major, tc.major) {
+			t.Errorf("%d: Got major breaks %v, want %v", i, major, tc.major)
+		}
+		minorFunc := NiceMinorBreaks
+		if i > 0 {
+			minorFunc = LogMinorBreaks
+		}
+		minor := minorFunc(major, tc.min, tc.max)
+		if tc.minor != nil && !sameFloats(minor, tc.minor) {
+			t.Errorf("%d: Got minor breaks %v, want %v", i, minor, tc.minor)
+		}
+	}
+
+	// A log10 scale on [12,88] gets nice untransformed labels.
+	s := NewScale("y", "y", Float)
+	s.Transform = &Log10Scale
+	s.DomainMin, s.DomainMax = math.Log10(12), math.Log10(88)
+	s.ExpandRel = 0
+	s.FinalizeContinous()
+	want := []string{"20", "30", "40", "50", "60", "70", "80"}
+	if len(s.Labels) != len(want) {
+		t.Fatalf("Got labels %v, want %v", s.Labels, want)
+	}
+	for i := range want {
+		if s.Labels[i] != want[i] {
+			t.Errorf("Got labels %v, want %v", s.Labels, want)
+			break
+		}
+	}
+
+	// Sqrt scales use nice values in the untransformed domain.
+	s = NewScale("y", "y", Float)
+	s.Transform = &SqrtScale
+	s.DomainMin, s.DomainMax = 0, 10
+	s.ExpandRel = 0
+	s.FinalizeContinous()
+	if !sameFloats(s.Breaks, []float64{0, 5, math.Sqrt(50), math.Sqrt(75), 10}) {
+		t.Errorf("Got sqrt breaks %v", s.Breaks)
+	}
+	if len(s.MinorBreaks) == 0 {
+		t.Errorf("No minor breaks on sqrt scale")
+	}
+
+	// Transforms without own breaks get nice untransformed ones too.
+	s = NewScale("y", "y", Float)
+	s.Transform = &ScaleTransform{Name: "Sqrt", Trans: math.Sqrt,
+		Inverse: func(y float64) float64 { return y * y }}
+	s.DomainMin, s.DomainMax = 0, 10
+	s.ExpandRel = 0
+	s.FinalizeContinous()
+	if !sameFloats(s.Breaks, []float64{0, 5, math.Sqrt(50), math.Sqrt(75), 10}) {
+		t.Errorf("Got breaks %v without Breaks function", s.Breaks)
+	}
+	if len(s.MinorBreaks) == 0 {
+		t.Errorf("No minor breaks without Breaks function")
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	// Trans and Inverse of all transformations are inverse.
+	for name, tr := range Transforms {
+		for _, x := range []float64{-20, -1, -0.5, 0, 0.001, 0.3, 0.5, 0.97, 1, 2.5, 77, 1e4} {
+			if tr.Domain != nil && !tr.Domain(x) {
+				continue
+			}
+			y := tr.Trans(x)
+			if math.IsInf(y, 0) {
+				continue // exp overflow
+			}
+			if got := tr.Inverse(y); math.Abs(got-x) > 1e-9*math.Max(1, math.Abs(x)) {
+				t.Errorf("%s: Inverse(Trans(%g)) = %g", name, x, got)
+			}
+		}
+	}
+	for _, lambda := range []float64{0, 0.5, 2} {
+		bc := BoxCoxScale(lambda)
+		if got := bc.Inverse(bc.Trans(3)); math.Abs(got-3) > 1e-9 {
+			t.Errorf("%s: Inverse(Trans(3)) = %g", bc.Name, got)
+		}
+	}
+
+	// Domains.
+	for _, tc := range []struct {
+		name  string
+		x     float64
+		valid bool
+	}{
+		{"log10", 0, false}, {"log2", -1, false}, {"log", 1e-9, true},
+		{"log1p", 0, true}, {"log1p", -1, false}, {"logit", 1, false},
+		{"probit", 0.5, true}, {"sqrt", 0, true}, {"reciprocal", 0, false},
+	} {
+		if got := Transforms[tc.name].Domain(tc.x); got != tc.valid {
+			t.Errorf("%s: Domain(%g) = %t", tc.name, tc.x, got)
+		}
+	}
+	if Transforms["pseudo_log"].Domain != nil {
+		t.Errorf("Pseudo-log must handle all values")
+	}
+
+	// Breaks.
+	for i, tc := range []struct {
+		breaks       func(min, max float64, n int) []float64
+		minorBreaks  func(major []float64, min, max float64) []float64
+		min, max     float64
+		major, minor []float64
+	}{
+		{Log2Breaks, Log2MinorBreaks, 1, 64, []float64{1, 2, 4, 8, 16, 32, 64},
+			[]float64{1.5, 3, 6, 12, 24, 48}},
+		{Log2Breaks, Log2MinorBreaks, 3, 5000, []float64{4, 32, 256, 2048},
+			[]float64{8, 16, 64, 128, 512, 1024, 4096}},
+		{Log2Breaks, Log2MinorBreaks, 3, 200, []float64{4, 8, 16, 32, 64, 128},
+			[]float64{3, 6, 12, 24, 48, 96, 192}},
+		{Log1pBreaks, Log1pMinorBreaks, 0, 1000, []float64{0, 1, 10, 100, 1000},
+			[]float64{2, 5, 20, 50, 200, 500}},
+		{PseudoLogBreaks, PseudoLogMinorBreaks, -150, 2000,
+			[]float64{-100, -10, 0, 10, 100, 1000},
+			[]float64{-50, -20, 20, 50, 200, 500, 2000}},
+		{PseudoLogBreaks, PseudoLogMinorBreaks, -3, 4,
+			[]float64{-3, -2, -1, 0, 1, 2, 3, 4},
+			[]float64{-2.5, -1.5, -0.5, 0.5, 1.5, 2.5, 3.5}},
+		{ProbabilityBreaks, ProbabilityMinorBreaks, 0.005, 0.995,
+			[]float64{0.01, 0.1, 0.5, 0.9, 0.99},
+			[]float64{0.05, 0.25, 0.75, 0.95}},
+		{ProbabilityBreaks, ProbabilityMinorBreaks, 0.2, 0.8,
+			[]float64{0.25, 0.5, 0.75}, []float64{}},
+	} {
+		major := tc.breaks(tc.min, tc.max, 5)
+		if !sameFloats(major, tc.major) {
+			t.Errorf("%d: Got breaks %v, want %v", i, major, tc.major)
+		}
+		if got := tc.minorBreaks(major, tc.min, tc.max); !sameFloats(got, tc.minor) {
+			t.Errorf("%d: Got minor breaks %v, want %v", i, got, tc.minor)
+		}
+	}
+
+	// All builtin logarithmic and probability scales have minor breaks.
+	for _, name := range []string{"log2", "log1p", "pseudo_log", "logit", "probit"} {
+		if Transforms[name].MinorBreaks == nil {
+			t.Errorf("Transform %s has no minor breaks", name)
+		}
+	}
+
+	// Labels show the untransformed values.
+	s := NewScale("y", "y", Float)
+	s.Transform = &LogitScale
+	s.DomainMin, s.DomainMax = LogitScale.Trans(0.005), LogitScale.Trans(0.995)
+	s.ExpandRel = 0
+	s.FinalizeContinous()
+	if got := strings.Join(s.Labels, " "); got != "0.01 0.10 0.50 0.90 0.99" {
+		t.Errorf("Got logit labels %s", got)
+	}
+}
+
+func TestTimeBreaks(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("No time zone data: %s", err)
+	}
+	date := func(y int, mo time.Month, d, h, m int) time.Time {
+		return time.Date(y, mo, d, h, m, 0, 0, berlin)
+	}
+
+	for i, tc := range []struct {
+		min, max time.Time
+		format   string
+		labels   []string
+		minor    int
+	}{
+		{date(2013, 3, 4, 9, 10), date(2013, 3, 4, 10, 5), "",
+			[]string{"09:15", "09:30", "09:45", "10:00"}, 8},
+		{date(2013, 3, 4, 20, 0), date(2013, 3, 5, 15, 0), "",
+			[]string{"00:00", "06:00", "12:00"}, 4},
+		{date(2013, 3, 4, 20, 0), date(2013, 3, 6, 15, 0), "",
+			[]string{"Mar 5 00:00", "Mar 5 12:00", "Mar 6 00:00", "Mar 6 12:00"}, -1},
+		{date(2013, 1, 3, 0, 0), date(2013, 5, 20, 0, 0), "",
+			[]string{"Feb", "Mar", "Apr", "May"}, -1},
+		{date(2012, 11, 3, 0, 0), date(2013, 5, 20, 0, 0), "",
+			[]string{"Jan", "Mar", "May"}, -1},
+		{date(2011, 11, 3, 0, 0), date(2013, 5, 20, 0, 0), "",
+			[]string{"Jan 2012", "Jul 2012", "Jan 2013"}, -1},
+		{date(1998, 1, 1, 0, 0), date(2013, 5, 20, 0, 0), "",
+			[]string{"2000", "2005", "2010"}, 13},
+		{date(2013, 3, 4, 9, 10), date(2013, 3, 4, 10, 5), "3:04PM",
+			[]string{"9:15AM", "9:30AM", "9:45AM", "10:00AM"}, 8},
+		// Daylight saving time started on March 31 2013 in Berlin.
+		{date(2013, 3, 29, 0, 0), date(2013, 4, 2, 0, 0), "",
+			[]string{"Mar 29", "Mar 30", "Mar 31", "Apr 1", "Apr 2"}, -1},
+	} {
+		s := NewScale("x", "x", Time)
+		s.Location = berlin
+		s.TimeFormat = tc.format
+		s.Origin = tc.min.Unix()
+		s.DomainMin, s.DomainMax = 0, float64(tc.max.Unix()-s.Origin)
+		s.ExpandRel = 0
+		s.FinalizeContinous()
+		if strings.Join(s.Labels, "|") != strings.Join(tc.labels, "|") {
+			t.Errorf("%d: Got labels %q, want %q", i, s.Labels, tc.labels)
+		}
+		if tc.minor >= 0 && len(s.MinorBreaks) != tc.minor {
+			t.Errorf("%d: Got %d minor breaks, want %d", i, len(s.MinorBreaks), tc.minor)
+		}
+		for _, b := range s.Breaks {
+			if bt := s.TimeOf(b); bt.Minute() != 0 && bt.Minute()%15 != 0 {
+				t.Errorf("%d: Unaligned break %s", i, bt)
+			}
+		}
+	}
+
+	// Multi-hour steps stay aligned to the local clock across the
+	// start and the end of daylight saving time.
+	for _, day := range []time.Time{date(2013, 3, 31, 0, 0), date(2013, 10, 27, 0, 0)} {
+		for _, n := range []int{2, 3, 6} {
+			ts := timeStep{unitHour, n}
+			times := ts.times(day, day.AddDate(0, 0, 2))
+			for _, bt := range times {
+				// A skipped hour is represented by the next one.
+				if !ts.floor(bt).Equal(bt) {
+					t.Errorf("%s, %d hours: Unaligned break %s", day.Format("Jan 2"), n, bt)
+				}
+			}
+			if len(times) < 2*24/n {
+				t.Errorf("%s, %d hours: Got only %d breaks", day.Format("Jan 2"), n, len(times))
+			}
+		}
+	}
+}
+
+func TestDurationBreaks(t *testing.T) {
+	for i, tc := range []struct {
+		unit     time.Duration
+		min, max float64
+		labels   []string
+	}{
+		{time.Second, 0, 3600, []string{"0s", "15m", "30m", "45m", "1h"}},
+		{time.Second, 0, 5 * 3600, []string{"0s", "1h", "2h", "3h", "4h", "5h"}},
+		{time.Minute, 20, 140, []string{"30m", "1h", "1h30m", "2h"}},
+		{time.Nanosecond, 0, 2e6, []string{"0s", "500µs", "1ms", "1.5ms", "2ms"}},
+	} {
+		s := NewScale("y", "y", Int)
+		s.Duration = tc.unit
+		s.DomainMin, s.DomainMax = tc.min, tc.max
+		s.ExpandRel = 0
+		s.FinalizeContinous()
+		if strings.Join(s.Labels, "|") != strings.Join(tc.labels, "|") {
+			t.Errorf("%d: Got labels %q, want %q", i, s.Labels, tc.labels)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[string]string{
+		"90m": "1h30m", "2h": "2h", "3m": "3m", "30s": "30s", "1m30s": "1m30s",
+		"0s": "0s", "1.5s": "1.5s", "250ms": "250ms", "26h": "26h",
+	} {
+		dur, _ := time.ParseDuration(d)
+		if got := FormatDuration(dur); got != want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestManualScaleErrors(t *testing.T) {
+	pool := NewStringPool()
+	s := NewScale("color", "c", String)
+	s.Values = map[string]string{"a": "red"}
+	f := NewField(3, String, pool)
+	for i, level := range []string{"a", "b", "c"} {
+		f.Data[i] = float64(pool.Add(level))
+	}
+	s.Train(f)
+	err := s.Finalize(pool)
+	if err == nil || !strings.Contains(err.Error(), "levels b, c") {
+		t.Errorf("Got error %v", err)
+	}
+
+	s = NewScale("x", "x", String)
+	s.Identity = true
+	if err := s.Finalize(pool); err == nil {
+		t.Errorf("Missing error for identity x scale")
+	}
+}
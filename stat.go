@@ -110,16 +110,23 @@ func (s StatBin) Apply(data *DataFrame, _ *Panel) *DataFrame {
 		origin = math.Floor(min/binWidth) * binWidth // round origin TODO: might overflow
 	}
 
-	x2bin := func(x float64) int { return int((x - origin) / binWidth) }
+	x2bin := func(x float64) int { return int(math.Floor((x - origin) / binWidth)) }
 	bin2x := func(b int) float64 { return float64(b)*binWidth + binWidth/2 + origin }
 
-	counts := make([]int64, numBins+1) // TODO: Buggy here?
+	// The rounded origin may shift the last values into an extra bin.
+	if n := x2bin(max) + 1; n > numBins {
+		numBins = n
+	}
+	counts := make([]int64, numBins)
 	// println("StatBin, made counts", len(counts), min, max, origin, binWidth)
 	column := data.Columns["x"].Data
 	maxcount := int64(0)
 	for i := 0; i < data.N; i++ {
 		bin := x2bin(column[i])
 		// println("  StatBin ", i, column[i], bin)
+		if bin < 0 || bin >= numBins {
+			continue // Left of a user supplied Origin.
+		}
 		counts[bin]++
 		if counts[bin] > maxcount {
 			maxcount = counts[bin]
@@ -180,6 +187,126 @@ func (s StatBin) Apply(data *DataFrame, _ *Panel) *DataFrame {
 
 }
 
+// -------------------------------------------------------------------------
+// StatCount
+
+// StatCount counts the number of occurences of each value of a discrete x.
+// It is the discrete analog of StatBin and produces the fields x, count
+// and prop, the proportion of the counts.
+type StatCount struct{}
+
+var _ Stat = StatCount{}
+
+func (StatCount) Name() string { return "StatCount" }
+
+func (StatCount) Info() StatInfo {
+	return StatInfo{
+		NeededAes:          []string{"x"},
+		OptionalAes:        []string{"weight"},
+		ExtraFieldHandling: GroupOnExtraFields,
+	}
+}
+
+func (s StatCount) Apply(data *DataFrame, _ *Panel) *DataFrame {
+	if data == nil || data.N == 0 {
+		return nil
+	}
+
+	xd := data.Columns["x"].Data
+	weight := func(int) float64 { return 1 }
+	if data.Has("weight") {
+		wd := data.Columns["weight"].Data
+		weight = func(i int) float64 { return wd[i] }
+	}
+	counts := make(map[float64]float64)
+	total := 0.0
+	for i := 0; i < data.N; i++ {
+		w := weight(i)
+		counts[xd[i]] += w
+		total += w
+	}
+
+	xs := make([]float64, 0, len(counts))
+	for x := range counts {
+		xs = append(xs, x)
+	}
+	sort.Float64s(xs)
+	n := len(xs)
+	pool := data.Pool
+	xf := data.Columns["x"].CopyMeta()
+	xf.Data = xs
+	countf, propf := NewField(n, Float, pool), NewField(n, Float, pool)
+	for i, x := range xs {
+		countf.Data[i] = counts[x]
+		propf.Data[i] = counts[x] / total
+	}
+
+	result := NewDataFrame(fmt.Sprintf("%s counted by x", data.Name), pool)
+	result.N = n
+	result.Columns["x"] = xf
+	result.Columns["count"] = countf
+	result.Columns["prop"] = propf
+
+	return result
+}
+
+// flippedStat applies the stat Stat to the y instead of the x values:
+// The fields x and y are swapped before and after applying Stat.
+type flippedStat struct {
+	Stat Stat
+}
+
+func (f flippedStat) Name() string { return f.Stat.Name() }
+
+func (f flippedStat) Info() StatInfo {
+	info := f.Stat.Info()
+	info.NeededAes = flipXY(info.NeededAes)
+	info.OptionalAes = flipXY(info.OptionalAes)
+	return info
+}
+
+func (f flippedStat) Apply(data *DataFrame, panel *Panel) *DataFrame {
+	if data == nil {
+		return nil
+	}
+	data = data.Copy()
+	swapXY(data)
+	result := f.Stat.Apply(data, panel)
+	if result != nil {
+		swapXY(result)
+	}
+	return result
+}
+
+// flipXY returns a copy of aes with x and y exchanged.
+func flipXY(aes []string) []string {
+	flipped := make([]string, len(aes))
+	for i, a := range aes {
+		switch a {
+		case "x":
+			a = "y"
+		case "y":
+			a = "x"
+		}
+		flipped[i] = a
+	}
+	return flipped
+}
+
+// swapXY exchanges the fields x and y in df.
+func swapXY(df *DataFrame) {
+	x, hasX := df.Columns["x"]
+	y, hasY := df.Columns["y"]
+	delete(df.Columns, "x")
+	delete(df.Columns, "y")
+	if hasX {
+		df.Columns["y"] = x
+	}
+	if hasY {
+		df.Columns["x"] = y
+	}
+}
+
 // -------------------------------------------------------------------------
 // StatLinReg
 
@@ -412,7 +539,22 @@ func (s StatFunction) Apply(data *DataFrame, panel *Panel) *DataFrame {
 // -------------------------------------------------------------------------
 // StatBoxplot
 
+// StatBoxplot computes the five numbers of a boxplot of y for each x.
+// The whiskers extend to the most extreme values within Coef times the
+// interquartile range from the box or, if Percentile is set, to the
+// Percentile and 100-Percentile percentiles; all values outside are
+// outliers. The notches span median ± 1.58·IQR/√n.
 type StatBoxplot struct {
+	// Coef is the length of the whiskers in multiples of the
+	// interquartile range. Zero means 1.5.
+	Coef float64
+
+	// Percentile in (0,50) to use percentile based whiskers instead.
+	Percentile float64
+
+	// Horizontal computes boxplots of x for each y. A layer with a
+	// GeomBoxplot is horizontal if either its stat or its geom is.
+	Horizontal bool
 }
 
 var _ Stat = StatBoxplot{}
@@ -431,11 +573,23 @@ type boxplot struct {
 	n                      int
 	min, max               float64
 	low, q1, med, q3, high float64
+	notchlow, notchhigh    float64
 	outliers               []float64
 }
 
+// quantile returns the p-quantile of the sorted values d by linear
+// interpolation.
+func quantile(d []float64, p float64) float64 {
+	h := p * float64(len(d)-1)
+	i := int(math.Floor(h))
+	if i >= len(d)-1 {
+		return d[len(d)-1]
+	}
+	return d[i] + (h-float64(i))*(d[i+1]-d[i])
+}
+
 // TODO: handle corner cases
-func computeBoxplot(d []float64) (b boxplot) {
+func (s StatBoxplot) compute(d []float64) (b boxplot) {
 	n := len(d)
 	b.n = n
 	sort.Float64s(d)
@@ -450,7 +604,19 @@ func computeBoxplot(d []float64) (b boxplot) {
 	b.q1, b.q3 = d[n/4], d[3*n/4]
 
 	iqr := b.q3 - b.q1
-	lo, hi := b.q1-1.5*iqr, b.q3+1.5*iqr
+	b.notchlow = b.med - 1.58*iqr/math.Sqrt(float64(n))
+	b.notchhigh = b.med + 1.58*iqr/math.Sqrt(float64(n))
+
+	var lo, hi float64
+	if p := s.Percentile; p > 0 && p < 50 {
+		lo, hi = quantile(d, p/100), quantile(d, 1-p/100)
+	} else {
+		coef := s.Coef
+		if coef <= 0 {
+			coef = 1.5
+		}
+		lo, hi = b.q1-coef*iqr, b.q3+coef*iqr
+	}
 	b.low, b.high = b.max, b.min
 
 	// Compute low, high and outliers.
@@ -469,10 +635,14 @@ func computeBoxplot(d []float64) (b boxplot) {
 	return b
 }
 
-func (s StatBoxplot) Apply(data *DataFrame, _ *Panel) *DataFrame {
+func (s StatBoxplot) Apply(data *DataFrame, panel *Panel) *DataFrame {
 	if data == nil || data.N == 0 {
 		return nil
 	}
+	if s.Horizontal {
+		s.Horizontal = false
+		return flippedStat{s}.Apply(data, panel)
+	}
 	xd, yd := data.Columns["x"].Data, data.Columns["y"].Data
 
 	xs := Levels(data, "x").Elements()
@@ -486,15 +656,15 @@ func (s StatBoxplot) Apply(data *DataFrame, _ *Panel) *DataFrame {
 	minf, maxf := NewField(n, Float, pool), NewField(n, Float, pool)
 	lowf, highf := NewField(n, Float, pool), NewField(n, Float, pool)
 	q1f, q3f := NewField(n, Float, pool), NewField(n, Float, pool)
+	notchlowf, notchhighf := NewField(n, Float, pool), NewField(n, Float, pool)
 	outf := NewField(n, Vector, pool)
 
 	for i := 0; i < data.N; i++ {
 		x, y := xd[i], yd[i]
 		ys[x] = append(ys[x], y)
 	}
-	i := 0
-	for x, y := range ys {
-		b := computeBoxplot(y)
+	for i, x := range xs {
+		b := s.compute(ys[x])
 		xf.Data[i] = x
 		numf.Data[i] = float64(b.n)
 		minf.Data[i] = b.min
@@ -504,8 +674,9 @@ func (s StatBoxplot) Apply(data *DataFrame, _ *Panel) *DataFrame {
 		q3f.Data[i] = b.q3
 		highf.Data[i] = b.high
 		maxf.Data[i] = b.max
+		notchlowf.Data[i] = b.notchlow
+		notchhighf.Data[i] = b.notchhigh
 		outf.SetVec(i, b.outliers)
-		i++
 	}
 
 	result := NewDataFrame(fmt.Sprintf("boxplot of %s", data.Name), pool)
@@ -519,6 +690,8 @@ func (s StatBoxplot) Apply(data *DataFrame, _ *Panel) *DataFrame {
 	result.Columns["q3"] = q3f
 	result.Columns["high"] = highf
 	result.Columns["max"] = maxf
+	result.Columns["notchlow"] = notchlowf
+	result.Columns["notchhigh"] = notchhighf
 	result.Columns["outliers"] = outf
 
 	return result
@@ -159,9 +159,12 @@ var BuiltinColors = map[string]color.NRGBA{
 	"white":   color.NRGBA{0xff, 0xff, 0xff, 0xff},
 	"gray10":  color.NRGBA{0x1a, 0x1a, 0x1a, 0xff},
 	"gray20":  color.NRGBA{0x33, 0x33, 0x33, 0xff},
+	"gray30":  color.NRGBA{0x4d, 0x4d, 0x4d, 0xff},
 	"gray40":  color.NRGBA{0x66, 0x66, 0x66, 0xff},
 	"gray":    color.NRGBA{0x7f, 0x7f, 0x7f, 0xff},
+	"gray50":  color.NRGBA{0x7f, 0x7f, 0x7f, 0xff},
 	"gray60":  color.NRGBA{0x99, 0x99, 0x99, 0xff},
+	"gray70":  color.NRGBA{0xb3, 0xb3, 0xb3, 0xff},
 	"gray80":  color.NRGBA{0xcc, 0xcc, 0xcc, 0xff},
 	"gray90":  color.NRGBA{0xe5, 0xe5, 0xe5, 0xff},
 	"black":   color.NRGBA{0x00, 0x00, 0x00, 0xff},
@@ -231,9 +234,9 @@ func makePosFunc(aes string, data *DataFrame, panel *Panel, style AesMapping, mi
 	var f func(i int) float64
 	if data.Has(aes) {
 		d := data.Columns[aes].Data
+		scale := panel.Scales[aes]
 		f = func(i int) float64 {
-			orig := panel.Scales[aes].Pos(d[i])
-			return orig*(max-min) + min
+			return scale.floatValue(d[i], min, max)
 		}
 	} else {
 		x := String2Float(style[aes], math.Inf(-1), math.Inf(+1))
@@ -268,6 +271,61 @@ func makeStyleFunc(aes string, data *DataFrame, panel *Panel, style AesMapping)
 	return f
 }
 
+// -------------------------------------------------------------------------
+// Legend Keys
+
+// KeyGeom is implemented by geoms which draw their own keys in legends.
+type KeyGeom interface {
+	// Key returns the grobs of the legend key k drawn in the square
+	// of side length size with lower left corner (x,y). All values
+	// are canvas lengths.
+	Key(k LegendKey, x, y, size float64) []Grob
+}
+
+// LegendKey describes one key in the legend of the scale Scale: The
+// aesthetic Aes has the value Value, all other aesthetics are taken from
+// the fixed Style of the Geom.
+type LegendKey struct {
+	Geom  KeyGeom
+	Style AesMapping
+	Aes   string
+	Scale *Scale
+	Value float64
+}
+
+// Color returns the color of the aesthetic aes in k.
+// It works like the function returned by makeColorFunc.
+func (k LegendKey) Color(aes string) color.Color {
+	if aes == k.Aes {
+		return k.Scale.Color(k.Value)
+	}
+	return String2Color(k.Style[aes])
+}
+
+// Float returns the continuous value of the aesthetic aes in k.
+// It works like the function returned by makePosFunc.
+func (k LegendKey) Float(aes string, min, max float64) float64 {
+	if aes == k.Aes {
+		return k.Scale.floatValue(k.Value, min, max)
+	}
+	return String2Float(k.Style[aes], math.Inf(-1), math.Inf(+1))
+}
+
+// Int returns the value of the shape or linetype aesthetic aes in k.
+// It works like the function returned by makeStyleFunc.
+func (k LegendKey) Int(aes string) int {
+	if aes == k.Aes {
+		return k.Scale.Style(k.Value)
+	}
+	switch aes {
+	case "shape":
+		return int(String2PointShape(k.Style[aes]))
+	case "linetype":
+		return int(String2LineType(k.Style[aes]))
+	}
+	return 0
+}
+
 func MergeStyles(aes ...AesMapping) AesMapping {
 	result := make(AesMapping)
 	for _, a := range aes {
@@ -3,10 +3,12 @@ package plot
 // Theme contains the stylable parameters of a plot.
 type Theme struct {
 	PointStyle, LineStyle, BarStyle AesMapping
-	TextStyle, RectStyle            AesMapping
+	TextStyle, RectStyle, TileStyle AesMapping
 	PanelBG, GridMajor, GridMinor   AesMapping
 	Strip, TicLabel, Tic            AesMapping
 	Title, Label                    AesMapping
+	Marginal                        AesMapping
+	TotalBG, TotalStrip             AesMapping // differing from PanelBG and Strip
 }
 
 var DefaultTheme = Theme{
@@ -44,6 +46,13 @@ var DefaultTheme = Theme{
 		"fill":     "gray50",
 		"alpha":    "1",
 	},
+	TileStyle: AesMapping{
+		"linetype": "blank",
+		"color":    "gray20",
+		"fill":     "gray50",
+		"size":     "0.5",
+		"alpha":    "1",
+	},
 	PanelBG: AesMapping{
 		"linetype": "blank",
 		"color":    "#00000000",
@@ -93,4 +102,18 @@ var DefaultTheme = Theme{
 		"size":  "14 pt",
 		"alpha": "1",
 	},
+	Marginal: AesMapping{
+		"linetype": "solid",
+		"color":    "gray20",
+		"size":     "1",
+		"fill":     "gray60",
+		"alpha":    "1",
+	},
+	TotalBG: AesMapping{
+		"fill": "gray80",
+	},
+	TotalStrip: AesMapping{
+		"color": "white",
+		"fill":  "gray40",
+	},
 }